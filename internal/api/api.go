@@ -16,10 +16,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/bluenviron/mediamtx/internal/audit"
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
 	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
+	"github.com/bluenviron/mediamtx/internal/hooks"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
@@ -89,24 +92,26 @@ type apiParent interface {
 
 // API is an API server.
 type API struct {
-	Address        string
-	Encryption     bool
-	ServerKey      string
-	ServerCert     string
-	AllowOrigin    string
-	TrustedProxies conf.IPNetworks
-	ReadTimeout    conf.Duration
-	Conf           *conf.Conf
-	AuthManager    apiAuthManager
-	PathManager    defs.APIPathManager
-	RTSPServer     defs.APIRTSPServer
-	RTSPSServer    defs.APIRTSPServer
-	RTMPServer     defs.APIRTMPServer
-	RTMPSServer    defs.APIRTMPServer
-	HLSServer      defs.APIHLSServer
-	WebRTCServer   defs.APIWebRTCServer
-	SRTServer      defs.APISRTServer
-	Parent         apiParent
+	Address         string
+	Encryption      bool
+	ServerKey       string
+	ServerCert      string
+	AllowOrigin     string
+	TrustedProxies  conf.IPNetworks
+	ReadTimeout     conf.Duration
+	Conf            *conf.Conf
+	AuthManager     apiAuthManager
+	PathManager     defs.APIPathManager
+	RTSPServer      defs.APIRTSPServer
+	RTSPSServer     defs.APIRTSPServer
+	RTMPServer      defs.APIRTMPServer
+	RTMPSServer     defs.APIRTMPServer
+	HLSServer       defs.APIHLSServer
+	WebRTCServer    defs.APIWebRTCServer
+	SRTServer       defs.APISRTServer
+	AuditLog        *audit.Log
+	ExternalCmdPool *externalcmd.Pool
+	Parent          apiParent
 
 	httpServer *httpp.Server
 	mutex      sync.RWMutex
@@ -187,7 +192,7 @@ func (a *API) Initialize() error {
 
 	group.GET("/recordings/list", a.onRecordingsList)
 	group.GET("/recordings/get/*name", a.onRecordingsGet)
-	group.DELETE("/recordings/deletesegment", a.onRecordingDeleteSegment)
+	group.DELETE("/recordings/deletesegment", a.middlewareAudit(audit.ActionDelete), a.onRecordingDeleteSegment)
 
 	network, address := restrictnetwork.Restrict("tcp", a.Address)
 
@@ -226,7 +231,8 @@ func (a *API) writeError(ctx *gin.Context, status int, err error) {
 	// show error in logs
 	a.Log(logger.Error, err.Error())
 
-	// add error to response
+	// add error to response and to the audit log
+	ctx.Error(err) //nolint:errcheck
 	ctx.JSON(status, &defs.APIError{
 		Error: err.Error(),
 	})
@@ -270,6 +276,22 @@ func (a *API) middlewareAuth(ctx *gin.Context) {
 	}
 }
 
+// middlewareAudit writes an entry into the audit log once a request has been served.
+func (a *API) middlewareAudit(action audit.Action) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if a.AuditLog == nil {
+			return
+		}
+
+		ctx.Next()
+
+		err := a.AuditLog.Write(audit.RequestEntry(ctx, action))
+		if err != nil {
+			a.Log(logger.Error, "unable to write audit log: %v", err)
+		}
+	}
+}
+
 func (a *API) onConfigGlobalGet(ctx *gin.Context) {
 	a.mutex.RLock()
 	c := a.Conf
@@ -1111,6 +1133,12 @@ func (a *API) onRecordingDeleteSegment(ctx *gin.Context) {
 		return
 	}
 
+	// segments are managed by an external tool
+	if pathConf.RecordLayout != "" {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("deletion cannot be used with 'recordLayout'"))
+		return
+	}
+
 	pathFormat := recordstore.PathAddExtension(
 		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
 		pathConf.RecordFormat,
@@ -1120,12 +1148,43 @@ func (a *API) onRecordingDeleteSegment(ctx *gin.Context) {
 		Start: start,
 	}.Encode(pathFormat)
 
+	holds, err := recordstore.FindHolds(pathConf, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the segment lasts until the beginning of the next one
+	var segmentEnd time.Time
+	segments, _ := recordstore.FindSegments(pathConf, pathName, &start, nil)
+	if len(segments) >= 2 {
+		segmentEnd = segments[1].Start
+	}
+
+	// the segment may be in the archive path
+	if len(segments) != 0 && segments[0].Start.Equal(start) {
+		segmentPath = segments[0].Fpath
+	}
+
+	if recordstore.HoldsOverlap(holds, start, segmentEnd) {
+		a.writeError(ctx, http.StatusConflict, fmt.Errorf("segment is protected by a hold"))
+		return
+	}
+
 	err = os.Remove(segmentPath)
 	if err != nil {
 		a.writeError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
+	hooks.OnRecordSegmentDelete(hooks.OnRecordSegmentDeleteParams{
+		Logger:          a,
+		ExternalCmdPool: a.ExternalCmdPool,
+		Conf:            pathConf,
+		PathName:        pathName,
+		SegmentPath:     segmentPath,
+	})
+
 	ctx.Status(http.StatusOK)
 }
 
@@ -13,6 +13,8 @@ import (
 
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/test"
 	"github.com/stretchr/testify/require"
@@ -720,6 +722,121 @@ func TestRecordingsDeleteSegment(t *testing.T) {
 	require.Equal(t, http.StatusOK, res.StatusCode)
 }
 
+func TestRecordingsDeleteSegmentRunOnRecordSegmentDelete(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cnf := tempConf(t, "pathDefaults:\n"+
+		"  recordPath: "+filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")+"\n"+
+		"paths:\n"+
+		"  all_others:\n")
+
+	cnf.Paths["all_others"].RunOnRecordSegmentDelete = "sh -c 'echo \"$MTX_PATH $MTX_SEGMENT_PATH\" > " +
+		filepath.Join(dir, "out") + "'"
+
+	externalCmdPool := &externalcmd.Pool{}
+	externalCmdPool.Initialize()
+
+	api := API{
+		Address:         "localhost:9997",
+		ReadTimeout:     conf.Duration(10 * time.Second),
+		Conf:            cnf,
+		AuthManager:     test.NilAuthManager,
+		ExternalCmdPool: externalCmdPool,
+		Parent:          &testParent{},
+	}
+	err = api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	err = os.Mkdir(filepath.Join(dir, "mypath1"), 0o755)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "mypath1", "2008-11-07_11-22-00-900000.mp4")
+
+	err = os.WriteFile(fpath, []byte(""), 0o644)
+	require.NoError(t, err)
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	v := url.Values{}
+	v.Set("path", "mypath1")
+	v.Set("start", time.Date(2008, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano))
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:9997/v3/recordings/deletesegment?"+v.Encode(), nil)
+	require.NoError(t, err)
+
+	res, err := hc.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	externalCmdPool.Close()
+
+	byts, err := os.ReadFile(filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	require.Equal(t, "mypath1 "+fpath+"\n", string(byts))
+}
+
+func TestRecordingsDeleteSegmentLayout(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cnf := tempConf(t, "pathDefaults:\n"+
+		"  recordPath: "+filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")+"\n"+
+		"paths:\n"+
+		"  all_others:\n")
+
+	cnf.Paths["all_others"].RecordLayout = "mynvr"
+
+	api := API{
+		Address:     "localhost:9997",
+		ReadTimeout: conf.Duration(10 * time.Second),
+		Conf:        cnf,
+		AuthManager: test.NilAuthManager,
+		Parent:      &testParent{},
+	}
+	err = api.Initialize()
+	require.NoError(t, err)
+	defer api.Close()
+
+	err = os.Mkdir(filepath.Join(dir, "mypath1"), 0o755)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "mypath1", "2008-11-07_11-22-00-900000.mp4")
+
+	err = os.WriteFile(fpath, []byte(""), 0o644)
+	require.NoError(t, err)
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	v := url.Values{}
+	v.Set("path", "mypath1")
+	v.Set("start", time.Date(2008, 11, 0o7, 11, 22, 0, 900000000, time.Local).Format(time.RFC3339Nano))
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:9997/v3/recordings/deletesegment?"+v.Encode(), nil)
+	require.NoError(t, err)
+
+	res, err := hc.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	var out defs.APIError
+	err = json.NewDecoder(res.Body).Decode(&out)
+	require.NoError(t, err)
+	require.Equal(t, "deletion cannot be used with 'recordLayout'", out.Error)
+
+	_, err = os.Stat(fpath)
+	require.NoError(t, err)
+}
+
 func TestAuthJWKSRefresh(t *testing.T) {
 	ok := false
 
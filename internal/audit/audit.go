@@ -0,0 +1,153 @@
+// Package audit contains the audit log.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Action is an audited action.
+type Action string
+
+// audited actions.
+const (
+	ActionExport         Action = "export"
+	ActionImport         Action = "import"
+	ActionDelete         Action = "delete"
+	ActionCompact        Action = "compact"
+	ActionHoldAdd        Action = "holdAdd"
+	ActionHoldRemove     Action = "holdRemove"
+	ActionTagAdd         Action = "tagAdd"
+	ActionTagRemove      Action = "tagRemove"
+	ActionRetentionPurge Action = "retentionPurge"
+)
+
+// Outcome is the outcome of an action.
+type Outcome string
+
+// outcomes.
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Entry is an entry of the audit log.
+type Entry struct {
+	Time       time.Time           `json:"time"`
+	Action     Action              `json:"action"`
+	Actor      string              `json:"actor"`
+	IP         string              `json:"ip"`
+	Path       string              `json:"path"`
+	Parameters map[string][]string `json:"parameters"`
+	Outcome    Outcome             `json:"outcome"`
+	Status     int                 `json:"status,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// Filter selects entries of the audit log.
+// Empty fields are ignored.
+type Filter struct {
+	Action Action
+	Actor  string
+	Path   string
+	Start  *time.Time
+	End    *time.Time
+	Limit  int
+}
+
+func (f *Filter) matches(e *Entry) bool {
+	return (f.Action == "" || e.Action == f.Action) &&
+		(f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Path == "" || e.Path == f.Path) &&
+		(f.Start == nil || !e.Time.Before(*f.Start)) &&
+		(f.End == nil || e.Time.Before(*f.End))
+}
+
+// Log is an append-only audit log, stored on disk in JSON lines format.
+// Methods can be called on a nil Log, in which case they do nothing.
+type Log struct {
+	FilePath string
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// Initialize initializes a Log.
+func (l *Log) Initialize() error {
+	var err error
+	l.file, err = os.OpenFile(l.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	return err
+}
+
+// Close closes a Log.
+func (l *Log) Close() {
+	if l == nil {
+		return
+	}
+	l.file.Close()
+}
+
+// Write appends an entry to the log.
+func (l *Log) Write(e *Entry) error {
+	if l == nil {
+		return nil
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// entries are written with a single call in order to be appended atomically
+	_, err = l.file.Write(buf)
+	return err
+}
+
+// Query returns entries that match the filter, in chronological order.
+// When a limit is set, the most recent entries are returned.
+func (l *Log) Query(f *Filter) ([]*Entry, error) {
+	out := []*Entry{}
+
+	if l == nil {
+		return out, nil
+	}
+
+	file, err := os.Open(l.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		var e Entry
+		// the last line may be in the process of being written
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			continue
+		}
+
+		if f.matches(&e) {
+			out = append(out, &e)
+
+			if f.Limit != 0 && len(out) > f.Limit {
+				out = out[1:]
+			}
+		}
+	}
+
+	return out, scanner.Err()
+}
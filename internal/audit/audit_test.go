@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := &Log{FilePath: filepath.Join(dir, "audit.log")}
+	err = l.Initialize()
+	require.NoError(t, err)
+	defer l.Close()
+
+	for i, e := range []*Entry{
+		{Action: ActionExport, Actor: "user1", Path: "cam1"},
+		{Action: ActionDelete, Actor: "user2", Path: "cam1"},
+		{Action: ActionExport, Actor: "user2", Path: "cam2"},
+		{Action: ActionRetentionPurge, Path: "cam2"},
+	} {
+		e.Time = time.Date(2008, 11, 0o7, 11, 22, i, 0, time.UTC)
+		e.Outcome = OutcomeSuccess
+		err = l.Write(e)
+		require.NoError(t, err)
+	}
+
+	// a partially written line is skipped
+	f, err := os.OpenFile(l.FilePath, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(`{"time":`))
+	require.NoError(t, err)
+	f.Close()
+
+	start := time.Date(2008, 11, 0o7, 11, 22, 1, 0, time.UTC)
+
+	for _, ca := range []struct {
+		name   string
+		filter Filter
+		actors []string
+	}{
+		{"all", Filter{}, []string{"user1", "user2", "user2", ""}},
+		{"action", Filter{Action: ActionExport}, []string{"user1", "user2"}},
+		{"actor", Filter{Actor: "user2"}, []string{"user2", "user2"}},
+		{"path", Filter{Path: "cam2"}, []string{"user2", ""}},
+		{"start", Filter{Start: &start}, []string{"user2", "user2", ""}},
+		{"limit", Filter{Limit: 1}, []string{""}},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			entries, err := l.Query(&ca.filter)
+			require.NoError(t, err)
+
+			actors := make([]string, len(entries))
+			for i, e := range entries {
+				actors[i] = e.Actor
+			}
+			require.Equal(t, ca.actors, actors)
+		})
+	}
+}
+
+func TestLogNil(t *testing.T) {
+	var l *Log
+
+	err := l.Write(&Entry{Action: ActionExport})
+	require.NoError(t, err)
+
+	entries, err := l.Query(&Filter{})
+	require.NoError(t, err)
+	require.Equal(t, []*Entry{}, entries)
+}
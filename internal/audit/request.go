@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
+	"github.com/gin-gonic/gin"
+)
+
+const actorKey = "auditActor"
+
+// SetActor sets the actor of a request, when it is not the user that
+// is provided with the request credentials, for instance with API keys.
+func SetActor(ctx *gin.Context, actor string) {
+	ctx.Set(actorKey, actor)
+}
+
+// RequestEntry returns an entry that describes an HTTP request that has been served.
+// Errors must be attached to the request with ctx.Error().
+func RequestEntry(ctx *gin.Context, action Action) *Entry {
+	params := ctx.Request.URL.Query()
+	params.Del("jwt")
+
+	actor := ctx.GetString(actorKey)
+	if actor == "" {
+		actor = httpp.Credentials(ctx.Request).User
+	}
+
+	e := &Entry{
+		Action:     action,
+		Actor:      actor,
+		IP:         httpp.ClientIP(ctx),
+		Path:       ctx.Query("path"),
+		Parameters: params,
+		Status:     ctx.Writer.Status(),
+	}
+
+	switch {
+	case len(ctx.Errors) != 0:
+		e.Outcome = OutcomeFailure
+		e.Error = ctx.Errors.Last().Error()
+
+	// the client went away before the response was complete
+	case ctx.Request.Context().Err() != nil:
+		e.Outcome = OutcomeFailure
+		e.Error = "request aborted"
+
+	case e.Status >= 400:
+		e.Outcome = OutcomeFailure
+
+	default:
+		e.Outcome = OutcomeSuccess
+	}
+
+	return e
+}
@@ -1,8 +1,9 @@
 package auth
 
-// Credentials is a set of credentials (either user+pass or a token).
+// Credentials is a set of credentials (either user+pass, a token or an API key).
 type Credentials struct {
-	User  string
-	Pass  string
-	Token string
+	User   string
+	Pass   string
+	Token  string
+	APIKey string
 }
@@ -28,9 +28,12 @@ const (
 )
 
 func isHTTPRequest(r *Request) bool {
+	if r.Action.IsPlayback() {
+		return true
+	}
+
 	switch r.Action {
-	case conf.AuthActionPlayback, conf.AuthActionAPI,
-		conf.AuthActionMetrics, conf.AuthActionPprof:
+	case conf.AuthActionAPI, conf.AuthActionMetrics, conf.AuthActionPprof:
 		return true
 	}
 
@@ -56,10 +59,10 @@ func (e Error) Error() string {
 
 func matchesPermission(perms []conf.AuthInternalUserPermission, req *Request) bool {
 	for _, perm := range perms {
-		if perm.Action == req.Action {
+		if perm.Action.Includes(req.Action) {
 			if perm.Action == conf.AuthActionPublish ||
 				perm.Action == conf.AuthActionRead ||
-				perm.Action == conf.AuthActionPlayback {
+				perm.Action.IsPlayback() {
 				switch {
 				case perm.Path == "":
 					return true
@@ -93,6 +96,7 @@ type Manager struct {
 	JWTClaimKey        string
 	JWTExclude         []conf.AuthInternalUserPermission
 	JWTInHTTPQuery     bool
+	APIKeys            []conf.AuthAPIKey
 	ReadTimeout        time.Duration
 
 	mutex           sync.RWMutex
@@ -107,8 +111,24 @@ func (m *Manager) ReloadInternalUsers(u []conf.AuthInternalUser) {
 	m.InternalUsers = u
 }
 
+// ReloadAPIKeys reloads APIKeys.
+func (m *Manager) ReloadAPIKeys(k []conf.AuthAPIKey) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.APIKeys = k
+}
+
 // Authenticate authenticates a request.
 func (m *Manager) Authenticate(req *Request) error {
+	// API keys are accepted regardless of the authentication method
+	if req.Credentials != nil && req.Credentials.APIKey != "" {
+		err := m.authenticateAPIKey(req)
+		if err != nil {
+			return Error{Wrapped: err}
+		}
+		return nil
+	}
+
 	var err error
 
 	switch m.Method {
@@ -132,6 +152,27 @@ func (m *Manager) Authenticate(req *Request) error {
 	return nil
 }
 
+func (m *Manager) authenticateAPIKey(req *Request) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if !req.Action.IsPlayback() {
+		return fmt.Errorf("API keys can only be used with the playback server")
+	}
+
+	for _, k := range m.APIKeys {
+		if k.Key.Check(req.Credentials.APIKey) {
+			if !matchesPermission(k.Permissions, req) {
+				return fmt.Errorf("API key '%s' doesn't have the permission to perform action '%s'", k.Name, req.Action)
+			}
+			req.APIKeyName = k.Name
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid API key")
+}
+
 func (m *Manager) authenticateInternal(req *Request) error {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
@@ -177,26 +218,37 @@ func (m *Manager) authenticateHTTP(req *Request) error {
 		return nil
 	}
 
+	// playback actions are sent as "playback", in order not to break
+	// existing servers, while the specific action is sent separately.
+	action := req.Action
+	var playbackAction conf.AuthAction
+	if action.IsPlayback() {
+		action = conf.AuthActionPlayback
+		playbackAction = req.Action
+	}
+
 	enc, _ := json.Marshal(struct {
-		IP       string     `json:"ip"`
-		User     string     `json:"user"`
-		Password string     `json:"password"`
-		Token    string     `json:"token"`
-		Action   string     `json:"action"`
-		Path     string     `json:"path"`
-		Protocol string     `json:"protocol"`
-		ID       *uuid.UUID `json:"id"`
-		Query    string     `json:"query"`
+		IP             string     `json:"ip"`
+		User           string     `json:"user"`
+		Password       string     `json:"password"`
+		Token          string     `json:"token"`
+		Action         string     `json:"action"`
+		PlaybackAction string     `json:"playbackAction,omitempty"`
+		Path           string     `json:"path"`
+		Protocol       string     `json:"protocol"`
+		ID             *uuid.UUID `json:"id"`
+		Query          string     `json:"query"`
 	}{
-		IP:       req.IP.String(),
-		User:     req.Credentials.User,
-		Password: req.Credentials.Pass,
-		Token:    req.Credentials.Token,
-		Action:   string(req.Action),
-		Path:     req.Path,
-		Protocol: string(req.Protocol),
-		ID:       req.ID,
-		Query:    req.Query,
+		IP:             req.IP.String(),
+		User:           req.Credentials.User,
+		Password:       req.Credentials.Pass,
+		Token:          req.Credentials.Token,
+		Action:         string(action),
+		PlaybackAction: string(playbackAction),
+		Path:           req.Path,
+		Protocol:       string(req.Protocol),
+		ID:             req.ID,
+		Query:          req.Query,
 	})
 
 	res, err := http.Post(m.HTTPAddress, "application/json", bytes.NewReader(enc))
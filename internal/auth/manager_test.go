@@ -189,6 +189,125 @@ func TestAuthInternalCustomVerifyFunc(t *testing.T) {
 	}
 }
 
+func TestAuthInternalPlaybackActions(t *testing.T) {
+	for _, ca := range []struct {
+		name      string
+		permitted conf.AuthAction
+		requested conf.AuthAction
+		ok        bool
+	}{
+		{"playback list", conf.AuthActionPlayback, conf.AuthActionPlaybackList, true},
+		{"playback download", conf.AuthActionPlayback, conf.AuthActionPlaybackDownload, true},
+		{"playback export", conf.AuthActionPlayback, conf.AuthActionPlaybackExport, true},
+		{"playback delete", conf.AuthActionPlayback, conf.AuthActionPlaybackDelete, false},
+		{"list download", conf.AuthActionPlaybackList, conf.AuthActionPlaybackDownload, false},
+		{"delete delete", conf.AuthActionPlaybackDelete, conf.AuthActionPlaybackDelete, true},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			m := Manager{
+				Method: conf.AuthMethodInternal,
+				InternalUsers: []conf.AuthInternalUser{
+					{
+						User: "any",
+						Permissions: []conf.AuthInternalUserPermission{{
+							Action: ca.permitted,
+							Path:   "mypath",
+						}},
+					},
+				},
+			}
+
+			err := m.Authenticate(&Request{
+				Action:      ca.requested,
+				Path:        "mypath",
+				Credentials: &Credentials{},
+				IP:          net.ParseIP("127.0.0.1"),
+			})
+
+			if ca.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+
+			err = m.Authenticate(&Request{
+				Action:      ca.requested,
+				Path:        "otherpath",
+				Credentials: &Credentials{},
+				IP:          net.ParseIP("127.0.0.1"),
+			})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAuthAPIKey(t *testing.T) {
+	m := Manager{
+		Method: conf.AuthMethodInternal,
+		APIKeys: []conf.AuthAPIKey{
+			{
+				Name: "old",
+				Key:  "oldkey",
+				Permissions: []conf.AuthInternalUserPermission{{
+					Action: conf.AuthActionPlaybackList,
+				}},
+			},
+			{
+				Name: "new",
+				Key:  "sha256:z4DNiu1ILV0VJ9fccvzv+E5jJlkoSER9LcCw6H38mpA=",
+				Permissions: []conf.AuthInternalUserPermission{{
+					Action: conf.AuthActionPlayback,
+					Path:   "~^cam",
+				}},
+			},
+		},
+	}
+
+	for _, ca := range []struct {
+		name    string
+		key     string
+		action  conf.AuthAction
+		path    string
+		keyName string
+	}{
+		{"plain", "oldkey", conf.AuthActionPlaybackList, "mypath", "old"},
+		{"hashed", "testing", conf.AuthActionPlaybackDownload, "cam1", "new"},
+		{"wrong action", "oldkey", conf.AuthActionPlaybackDownload, "mypath", ""},
+		{"wrong path", "testing", conf.AuthActionPlaybackDownload, "mypath", ""},
+		{"wrong key", "otherkey", conf.AuthActionPlaybackList, "mypath", ""},
+		{"not playback", "oldkey", conf.AuthActionRead, "mypath", ""},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			req := &Request{
+				Action:      ca.action,
+				Path:        ca.path,
+				Credentials: &Credentials{APIKey: ca.key},
+				IP:          net.ParseIP("127.0.0.1"),
+			}
+			err := m.Authenticate(req)
+
+			if ca.keyName != "" {
+				require.NoError(t, err)
+				require.Equal(t, ca.keyName, req.APIKeyName)
+			} else {
+				require.Error(t, err)
+				require.False(t, err.(Error).AskCredentials) //nolint:errorlint
+			}
+		})
+	}
+
+	// revoke a key
+	m.ReloadAPIKeys(m.APIKeys[1:])
+
+	err := m.Authenticate(&Request{
+		Action:      conf.AuthActionPlaybackList,
+		Path:        "mypath",
+		Credentials: &Credentials{APIKey: "oldkey"},
+		IP:          net.ParseIP("127.0.0.1"),
+	})
+	require.Error(t, err)
+}
+
 func TestAuthHTTP(t *testing.T) {
 	for _, outcome := range []string{"ok", "fail"} {
 		t.Run(outcome, func(t *testing.T) {
@@ -272,6 +391,54 @@ func TestAuthHTTP(t *testing.T) {
 	}
 }
 
+func TestAuthHTTPPlayback(t *testing.T) {
+	var actions [][2]string
+
+	httpServ := &http.Server{
+		Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			var in struct {
+				Action         string `json:"action"`
+				PlaybackAction string `json:"playbackAction"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&in)
+			require.NoError(t, err)
+
+			actions = append(actions, [2]string{in.Action, in.PlaybackAction})
+		}),
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:9120")
+	require.NoError(t, err)
+
+	go httpServ.Serve(ln)
+	defer httpServ.Shutdown(context.Background())
+
+	m := Manager{
+		Method:      conf.AuthMethodHTTP,
+		HTTPAddress: "http://127.0.0.1:9120/auth",
+	}
+
+	for _, action := range []conf.AuthAction{
+		conf.AuthActionPlaybackList,
+		conf.AuthActionPlaybackDelete,
+		conf.AuthActionRead,
+	} {
+		err = m.Authenticate(&Request{
+			Action:      action,
+			Path:        "teststream",
+			Credentials: &Credentials{},
+			IP:          net.ParseIP("127.0.0.1"),
+		})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, [][2]string{
+		{"playback", "playbackList"},
+		{"playback", "playbackDelete"},
+		{"read", ""},
+	}, actions)
+}
+
 func TestAuthHTTPExclude(t *testing.T) {
 	m := Manager{
 		Method:      conf.AuthMethodHTTP,
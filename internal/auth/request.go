@@ -29,4 +29,7 @@ type Request struct {
 	Credentials      *Credentials
 	IP               net.IP
 	CustomVerifyFunc func(expectedUser string, expectedPass string) bool
+
+	// filled by Authenticate when the request is authenticated with an API key
+	APIKeyName string
 }
@@ -18,8 +18,45 @@ const (
 	AuthActionAPI      AuthAction = "api"
 	AuthActionMetrics  AuthAction = "metrics"
 	AuthActionPprof    AuthAction = "pprof"
+
+	AuthActionPlaybackList     AuthAction = "playbackList"
+	AuthActionPlaybackDownload AuthAction = "playbackDownload"
+	AuthActionPlaybackExport   AuthAction = "playbackExport"
+	AuthActionPlaybackDelete   AuthAction = "playbackDelete"
 )
 
+// IsPlayback checks whether the action is a playback action.
+func (d AuthAction) IsPlayback() bool {
+	switch d {
+	case AuthActionPlayback,
+		AuthActionPlaybackList,
+		AuthActionPlaybackDownload,
+		AuthActionPlaybackExport,
+		AuthActionPlaybackDelete:
+		return true
+	}
+	return false
+}
+
+// Includes checks whether a permission with this action grants the given action.
+// The playback action grants all playback actions that don't alter recordings.
+func (d AuthAction) Includes(action AuthAction) bool {
+	if d == action {
+		return true
+	}
+
+	if d == AuthActionPlayback {
+		switch action {
+		case AuthActionPlaybackList,
+			AuthActionPlaybackDownload,
+			AuthActionPlaybackExport:
+			return true
+		}
+	}
+
+	return false
+}
+
 // MarshalJSON implements json.Marshaler.
 func (d AuthAction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(string(d))
@@ -38,7 +75,11 @@ func (d *AuthAction) UnmarshalJSON(b []byte) error {
 		string(AuthActionPlayback),
 		string(AuthActionAPI),
 		string(AuthActionMetrics),
-		string(AuthActionPprof):
+		string(AuthActionPprof),
+		string(AuthActionPlaybackList),
+		string(AuthActionPlaybackDownload),
+		string(AuthActionPlaybackExport),
+		string(AuthActionPlaybackDelete):
 		*d = AuthAction(in)
 
 	default:
@@ -0,0 +1,62 @@
+package conf
+
+import (
+	"fmt"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// AuthAPIKey is an API key that grants access to the playback server.
+type AuthAPIKey struct {
+	Name        string                       `json:"name"`
+	Key         Credential                   `json:"key"`
+	Permissions []AuthInternalUserPermission `json:"permissions"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *AuthAPIKey) UnmarshalJSON(b []byte) error {
+	type alias AuthAPIKey
+	if err := jsonwrapper.Unmarshal(b, (*alias)(d)); err != nil {
+		return err
+	}
+
+	if d.Name == "" {
+		return fmt.Errorf("API keys must have a name")
+	}
+
+	if d.Key == "" {
+		return fmt.Errorf("API key '%s' is empty", d.Name)
+	}
+
+	for _, perm := range d.Permissions {
+		if !perm.Action.IsPlayback() {
+			return fmt.Errorf("API key '%s' can't be used with action '%s', only playback actions are supported",
+				d.Name, perm.Action)
+		}
+	}
+
+	return nil
+}
+
+// AuthAPIKeys is a list of AuthAPIKey.
+type AuthAPIKeys []AuthAPIKey
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *AuthAPIKeys) UnmarshalJSON(b []byte) error {
+	// remove default value before loading new value
+	// https://github.com/golang/go/issues/21092
+	*s = nil
+	if err := jsonwrapper.Unmarshal(b, (*[]AuthAPIKey)(s)); err != nil {
+		return err
+	}
+
+	names := make(map[string]struct{})
+	for _, k := range *s {
+		if _, ok := names[k.Name]; ok {
+			return fmt.Errorf("there are multiple API keys named '%s'", k.Name)
+		}
+		names[k.Name] = struct{}{}
+	}
+
+	return nil
+}
@@ -64,6 +64,13 @@ func copyStructFields(dest interface{}, source interface{}) {
 
 	for i := 0; i < nf; i++ {
 		fnew := rvsource.Field(i)
+
+		// fields of embedded structs are copied one by one
+		if rvsource.Type().Field(i).Anonymous {
+			copyStructFields(dest, fnew.Addr().Interface())
+			continue
+		}
+
 		f := rvdest.Elem().FieldByName(rvsource.Type().Field(i).Name)
 		if f == zero {
 			continue
@@ -162,6 +169,7 @@ type Conf struct {
 	LogDestinations     LogDestinations `json:"logDestinations"`
 	LogFile             string          `json:"logFile"`
 	SysLogPrefix        string          `json:"sysLogPrefix"`
+	AuditLogFile        string          `json:"auditLogFile"`
 	ReadTimeout         Duration        `json:"readTimeout"`
 	WriteTimeout        Duration        `json:"writeTimeout"`
 	ReadBufferCount     *int            `json:"readBufferCount,omitempty"` // deprecated
@@ -182,6 +190,7 @@ type Conf struct {
 	AuthJWTClaimKey           string                      `json:"authJWTClaimKey"`
 	AuthJWTExclude            AuthInternalUserPermissions `json:"authJWTExclude"`
 	AuthJWTInHTTPQuery        bool                        `json:"authJWTInHTTPQuery"`
+	AuthAPIKeys               AuthAPIKeys                 `json:"authAPIKeys"`
 
 	// Control API
 	API               bool       `json:"api"`
@@ -211,20 +220,14 @@ type Conf struct {
 	PPROFTrustedProxies IPNetworks `json:"pprofTrustedProxies"`
 
 	// Playback
-	Playback               bool       `json:"playback"`
-	PlaybackAddress        string     `json:"playbackAddress"`
-	PlaybackEncryption     bool       `json:"playbackEncryption"`
-	PlaybackServerKey      string     `json:"playbackServerKey"`
-	PlaybackServerCert     string     `json:"playbackServerCert"`
-	PlaybackAllowOrigin    string     `json:"playbackAllowOrigin"`
-	PlaybackTrustedProxies IPNetworks `json:"playbackTrustedProxies"`
+	Playback
 
 	// RTSP server
 	RTSP               bool             `json:"rtsp"`
 	RTSPDisable        *bool            `json:"rtspDisable,omitempty"` // deprecated
 	Protocols          *RTSPTransports  `json:"protocols,omitempty"`   // deprecated
 	RTSPTransports     RTSPTransports   `json:"rtspTransports"`
-	Encryption         *Encryption      `json:"encryption,omitempty"` // deprecated
+	LegacyEncryption   *Encryption      `json:"encryption,omitempty"` // deprecated
 	RTSPEncryption     Encryption       `json:"rtspEncryption"`
 	RTSPAddress        string           `json:"rtspAddress"`
 	RTSPSAddress       string           `json:"rtspsAddress"`
@@ -237,8 +240,8 @@ type Conf struct {
 	SRTCPAddress       string           `json:"srtcpAddress"`
 	MulticastSRTPPort  int              `json:"multicastSRTPPort"`
 	MulticastSRTCPPort int              `json:"multicastSRTCPPort"`
-	ServerKey          *string          `json:"serverKey,omitempty"`
-	ServerCert         *string          `json:"serverCert,omitempty"`
+	LegacyServerKey    *string          `json:"serverKey,omitempty"`  // deprecated
+	LegacyServerCert   *string          `json:"serverCert,omitempty"` // deprecated
 	RTSPServerKey      string           `json:"rtspServerKey"`
 	RTSPServerCert     string           `json:"rtspServerCert"`
 	AuthMethods        *RTSPAuthMethods `json:"authMethods,omitempty"` // deprecated
@@ -341,6 +344,7 @@ func (conf *Conf) setDefaults() {
 	conf.AuthJWTClaimKey = "mediamtx_permissions"
 	conf.AuthJWTExclude = []AuthInternalUserPermission{}
 	conf.AuthJWTInHTTPQuery = true
+	conf.AuthAPIKeys = AuthAPIKeys{}
 
 	// Control API
 	conf.APIAddress = ":9997"
@@ -361,10 +365,20 @@ func (conf *Conf) setDefaults() {
 	conf.PPROFAllowOrigin = "*"
 
 	// Playback server
-	conf.PlaybackAddress = ":9996"
-	conf.PlaybackServerKey = "server.key"
-	conf.PlaybackServerCert = "server.crt"
-	conf.PlaybackAllowOrigin = "*"
+	conf.Playback.Address = ":9996"
+	conf.Playback.ServerKey = "server.key"
+	conf.Playback.ServerCert = "server.crt"
+	conf.Playback.AllowOrigins = []string{"*"}
+	conf.Playback.AllowHeaders = []string{"Authorization", "Content-Type", "X-API-Key"}
+	conf.Playback.AllowMethods = []string{"OPTIONS", "GET", "POST", "DELETE"}
+	conf.Playback.HTTP2 = true
+	conf.Playback.StallAction = PlaybackStallActionAbort
+	conf.Playback.MemoryBudget = 512 * 1024 * 1024
+	conf.Playback.WriteBuffer = 64 * 1024
+	conf.Playback.Peers = []string{}
+	conf.Playback.LoadPolicy = PlaybackLoadPolicyQueue
+	conf.Playback.BulkDuration = 5 * Duration(time.Minute)
+	conf.Playback.MaxClockSkew = 10 * Duration(time.Second)
 
 	// RTSP server
 	conf.RTSP = true
@@ -623,9 +637,9 @@ func (conf *Conf) Validate(l logger.Writer) error {
 		l.Log(logger.Warn, "parameter 'protocols' is deprecated and has been replaced with 'rtspTransports'")
 		conf.RTSPTransports = *conf.Protocols
 	}
-	if conf.Encryption != nil {
+	if conf.LegacyEncryption != nil {
 		l.Log(logger.Warn, "parameter 'encryption' is deprecated and has been replaced with 'rtspEncryption'")
-		conf.RTSPEncryption = *conf.Encryption
+		conf.RTSPEncryption = *conf.LegacyEncryption
 	}
 	if conf.AuthMethods != nil {
 		l.Log(logger.Warn, "parameter 'authMethods' is deprecated and has been replaced with 'rtspAuthMethods'")
@@ -641,13 +655,13 @@ func (conf *Conf) Validate(l logger.Writer) error {
 			}
 		}
 	}
-	if conf.ServerCert != nil {
+	if conf.LegacyServerCert != nil {
 		l.Log(logger.Warn, "parameter 'serverCert' is deprecated and has been replaced with 'rtspServerCert'")
-		conf.RTSPServerCert = *conf.ServerCert
+		conf.RTSPServerCert = *conf.LegacyServerCert
 	}
-	if conf.ServerKey != nil {
+	if conf.LegacyServerKey != nil {
 		l.Log(logger.Warn, "parameter 'serverKey' is deprecated and has been replaced with 'rtspServerKey'")
-		conf.RTSPServerKey = *conf.ServerKey
+		conf.RTSPServerKey = *conf.LegacyServerKey
 	}
 	if len(conf.RTSPAuthMethods) == 0 {
 		return fmt.Errorf("at least one 'rtspAuthMethods' must be provided")
@@ -667,6 +681,13 @@ func (conf *Conf) Validate(l logger.Writer) error {
 		conf.HLS = !*conf.HLSDisable
 	}
 
+	// Playback
+
+	err := conf.Playback.Validate(l)
+	if err != nil {
+		return err
+	}
+
 	// WebRTC
 
 	if conf.WebRTCDisable != nil {
@@ -791,7 +812,7 @@ func (conf *Conf) Validate(l logger.Writer) error {
 		}
 	}
 
-	return nil
+	return conf.validatePlaybackPaths()
 }
 
 // UnmarshalJSON implements json.Unmarshaler.
@@ -3,6 +3,7 @@ package conf
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"io"
 	"os"
 	"testing"
@@ -57,6 +58,7 @@ func TestConfFromFile(t *testing.T) {
 			RecordPartDuration:         Duration(1 * time.Second),
 			RecordSegmentDuration:      3600000000000,
 			RecordDeleteAfter:          86400000000000,
+			RecordAliases:              []string{},
 			OverridePublisher:          true,
 			RPICameraWidth:             1920,
 			RPICameraHeight:            1080,
@@ -124,6 +126,9 @@ func TestConfFromFileAndEnv(t *testing.T) {
 	// deprecated global parameter
 	t.Setenv("MTX_RTMPDISABLE", "yes")
 
+	// playback parameter
+	t.Setenv("MTX_PLAYBACKADDRESS", ":9000")
+
 	// deprecated path parameter
 	t.Setenv("MTX_PATHS_CAM2_DISABLEPUBLISHEROVERRIDE", "yes")
 
@@ -137,6 +142,7 @@ func TestConfFromFileAndEnv(t *testing.T) {
 
 	require.Equal(t, RTSPTransports{gortsplib.TransportTCP: {}}, conf.RTSPTransports)
 	require.Equal(t, false, conf.RTMP)
+	require.Equal(t, ":9000", conf.Playback.Address)
 
 	pa, ok := conf.Paths["cam1"]
 	require.Equal(t, true, ok)
@@ -291,6 +297,102 @@ func TestConfErrors(t *testing.T) {
 			"udpMaxPayloadSize: 5000\n",
 			"'udpMaxPayloadSize' must be less than 1472",
 		},
+		{
+			"invalid playbackAllowMethods",
+			"playbackAllowMethods: [get]\n",
+			"invalid method in 'playbackAllowMethods': 'get'",
+		},
+		{
+			"invalid playbackExternalURL",
+			"playbackExternalURL: ftp://myserver\n",
+			"invalid 'playbackExternalURL': 'ftp://myserver'",
+		},
+		{
+			"invalid playbackPeers",
+			"playbackPeers: [http://peer:9996, http://peer:9996]\n",
+			"'playbackPeers' contains 'http://peer:9996' twice",
+		},
+		{
+			"invalid playbackAddress",
+			"playbackAddress: ':9996,'\n",
+			"'playbackAddress' contains an empty entry",
+		},
+		{
+			"invalid playbackAuthCacheTTL",
+			"playbackAuthCacheTTL: -1s\n",
+			"'playbackAuthCacheTTL' cannot be negative",
+		},
+		{
+			"invalid playbackMaxBulk",
+			"playbackMaxBulk: -1\n",
+			"'playbackMaxBulk' cannot be negative",
+		},
+		{
+			"invalid playbackRateLimit",
+			"playbackRateLimit: -1\n",
+			"'playbackRateLimit' cannot be negative",
+		},
+		{
+			"invalid playbackRangePolicy",
+			"playbackRangePolicy: other\n",
+			"invalid playbackRangePolicy: 'other'",
+		},
+		{
+			"invalid playbackLogIPs",
+			"playbackLogIPs: other\n",
+			"invalid playbackLogIPs: 'other'",
+		},
+		{
+			"invalid playbackMaxClockSkew",
+			"playbackMaxClockSkew: -1s\n",
+			"'playbackMaxClockSkew' cannot be negative",
+		},
+		{
+			"invalid playbackFaultFailures",
+			"playbackFaultFailures: 101\n",
+			"'playbackFaultFailures' must be a percentage between 0 and 100",
+		},
+		{
+			"invalid playbackWriteBuffer",
+			"playbackWriteBuffer: 1G\n",
+			"'playbackWriteBuffer' cannot be greater than 16M",
+		},
+		{
+			"invalid playbackOffloadHeader",
+			"playbackOffloadHeader: X-Other\n",
+			"invalid 'playbackOffloadHeader': 'X-Other' (available values are X-Accel-Redirect and X-Sendfile)",
+		},
+		{
+			"invalid playbackProxyProtocol",
+			"playbackProxyProtocol: yes\n",
+			"'playbackProxyProtocol' requires the load balancer to be in 'playbackTrustedProxies'",
+		},
+		{
+			"invalid playbackOffloadPrefix",
+			"playbackOffloadHeader: X-Sendfile\n" +
+				"playbackOffloadPrefix: /internal\n",
+			"'playbackOffloadPrefix' cannot be used with X-Sendfile, that uses paths of files",
+		},
+		{
+			"invalid playbackReadOnly",
+			"playback: true\n" +
+				"playbackReadOnly: true\n" +
+				"paths:\n" +
+				"  my_path:\n" +
+				"    recordDeleteAfter: 1d\n",
+			"'playbackReadOnly' requires 'recordDeleteAfter' to be 0 (path 'my_path')",
+		},
+		{
+			"invalid playbackReadOnly archive",
+			"playback: true\n" +
+				"playbackReadOnly: true\n" +
+				"paths:\n" +
+				"  my_path:\n" +
+				"    recordDeleteAfter: 0s\n" +
+				"    recordArchivePath: /archive/%path/%Y-%m-%d_%H-%M-%S-%f\n" +
+				"    recordArchiveAfter: 2h\n",
+			"'playbackReadOnly' requires 'recordArchiveAfter' to be 0 (path 'my_path')",
+		},
 		{
 			"invalid ICE server",
 			"webrtcICEServers: [testing]\n",
@@ -405,6 +507,24 @@ func TestConfErrors(t *testing.T) {
 				"  - action: publish\n",
 			`using a password with 'any' user is not supported`,
 		},
+		{
+			"API key with non-playback action",
+			"authAPIKeys:\n" +
+				"- name: mykey\n" +
+				"  key: test\n" +
+				"  permissions:\n" +
+				"  - action: publish\n",
+			"API key 'mykey' can't be used with action 'publish', only playback actions are supported",
+		},
+		{
+			"duplicate API keys",
+			"authAPIKeys:\n" +
+				"- name: mykey\n" +
+				"  key: test1\n" +
+				"- name: mykey\n" +
+				"  key: test2\n",
+			"there are multiple API keys named 'mykey'",
+		},
 		{
 			"invalid record path 1",
 			"paths:\n" +
@@ -435,6 +555,58 @@ func TestConfErrors(t *testing.T) {
 				"    recordDeleteAfter: 20m\n",
 			`'recordDeleteAfter' cannot be lower than 'recordSegmentDuration'`,
 		},
+		{
+			"record layout with record",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    record: yes\n" +
+				"    recordLayout: custom\n" +
+				"    recordDeleteAfter: 0s\n",
+			`'recordLayout' cannot be used together with 'record'`,
+		},
+		{
+			"record layout with record delete after",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordLayout: custom\n",
+			`'recordLayout' requires 'recordDeleteAfter' and 'recordArchiveAfter' to be 0`,
+		},
+		{
+			"record aliases with regexp path",
+			"paths:\n" +
+				"  '~^cam[0-9]$':\n" +
+				"    recordAliases: [cam]\n",
+			`a path with a regular expression (or path 'all') does not support option 'recordAliases'`,
+		},
+		{
+			"record aliases with existing path",
+			"paths:\n" +
+				"  cam1:\n" +
+				"    recordAliases: [cam2]\n" +
+				"  cam2:\n",
+			`'recordAliases' cannot contain the name of a path ('cam2')`,
+		},
+		{
+			"invalid record encryption key",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordEncryptionKey: abcd\n",
+			`'recordEncryptionKey' must be a 256-bit key in hexadecimal format`,
+		},
+		{
+			"record allow plaintext without encryption key",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordAllowPlaintext: yes\n",
+			`'recordAllowPlaintext' requires 'recordEncryptionKey'`,
+		},
+		{
+			"record archive after without archive path",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    recordArchiveAfter: 2h\n",
+			`'recordArchiveAfter' requires 'recordArchivePath'`,
+		},
 	} {
 		t.Run(ca.name, func(t *testing.T) {
 			tmpf, err := createTempFile([]byte(ca.conf))
@@ -479,6 +651,40 @@ func TestSampleConfFile(t *testing.T) {
 	}()
 }
 
+func TestConfGlobal(t *testing.T) {
+	conf, _, err := Load("", nil, nil)
+	require.NoError(t, err)
+
+	enc, err := json.Marshal(conf)
+	require.NoError(t, err)
+
+	var expected map[string]interface{}
+	err = json.Unmarshal(enc, &expected)
+	require.NoError(t, err)
+	delete(expected, "pathDefaults")
+	delete(expected, "paths")
+
+	enc, err = json.Marshal(conf.Global())
+	require.NoError(t, err)
+
+	var global map[string]interface{}
+	err = json.Unmarshal(enc, &global)
+	require.NoError(t, err)
+
+	// fields of embedded structs are part of the global configuration
+	require.Equal(t, expected, global)
+
+	var optional OptionalGlobal
+	err = json.Unmarshal([]byte(`{"playbackAddress":":9000","playbackEncryption":true,"encryption":"strict"}`),
+		&optional)
+	require.NoError(t, err)
+
+	conf.PatchGlobal(&optional)
+	require.Equal(t, ":9000", conf.Playback.Address)
+	require.Equal(t, true, conf.Playback.Encryption)
+	require.Equal(t, EncryptionStrict, *conf.LegacyEncryption)
+}
+
 // needed due to https://github.com/golang/go/issues/21092
 func TestConfOverrideDefaultSlices(t *testing.T) {
 	tmpf, err := createTempFile([]byte(
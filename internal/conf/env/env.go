@@ -165,6 +165,15 @@ func loadEnvInternal(env map[string]string, prefix string, prv reflect.Value) er
 				continue
 			}
 
+			// fields of embedded structs have the same prefix of the other fields
+			if f.Anonymous {
+				err := loadEnvInternal(env, prefix, prv.Elem().Field(i))
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
 			err := loadEnvInternal(env, prefix+"_"+
 				strings.ToUpper(strings.TrimSuffix(jsonTag, ",omitempty")), prv.Elem().Field(i))
 			if err != nil {
@@ -70,7 +70,13 @@ type mySubStruct struct {
 	MyInt2   int    `json:"myInt2"`
 }
 
+// MyEmbedded is exported since fields of unexported embedded structs can't be set.
+type MyEmbedded struct {
+	MyEmbeddedString string `json:"myEmbeddedString"`
+}
+
 type testStruct struct {
+	MyEmbedded
 	MyString                 string               `json:"myString"`
 	MyStringOpt              *string              `json:"myStringOpt"`
 	MyInt                    int                  `json:"myInt"`
@@ -125,6 +131,7 @@ func TestLoad(t *testing.T) {
 		"MYPREFIX_MYSLICESUBSTRUCT_1_PASSWORD":    "pass2",
 		"MYPREFIX_MYSLICESUBSTRUCTEMPTY":          "",
 		"MYPREFIX_MYSLICESUBSTRUCTOPT_1_PASSWORD": "pwd",
+		"MYPREFIX_MYEMBEDDEDSTRING":               "embedded",
 	}
 
 	for key, val := range env {
@@ -136,6 +143,9 @@ func TestLoad(t *testing.T) {
 	require.NoError(t, err)
 
 	require.Equal(t, testStruct{
+		MyEmbedded: MyEmbedded{
+			MyEmbeddedString: "embedded",
+		},
 		MyString:      "testcontent",
 		MyStringOpt:   stringPtr("testcontent2"),
 		MyInt:         123,
@@ -10,11 +10,12 @@ import (
 
 var optionalGlobalValuesType = func() reflect.Type {
 	var fields []reflect.StructField
-	rt := reflect.TypeOf(Conf{})
-	nf := rt.NumField()
+	// fields of embedded structs are promoted to the top level
+	for _, f := range reflect.VisibleFields(reflect.TypeOf(Conf{})) {
+		if f.Anonymous {
+			continue
+		}
 
-	for i := 0; i < nf; i++ {
-		f := rt.Field(i)
 		j := f.Tag.Get("json")
 
 		if j != "-" && j != "pathDefaults" && j != "paths" {
@@ -1,6 +1,7 @@
 package conf
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -130,6 +131,12 @@ type Path struct {
 	RecordPartDuration    Duration     `json:"recordPartDuration"`
 	RecordSegmentDuration Duration     `json:"recordSegmentDuration"`
 	RecordDeleteAfter     Duration     `json:"recordDeleteAfter"`
+	RecordEncryptionKey   string       `json:"recordEncryptionKey"`
+	RecordAllowPlaintext  bool         `json:"recordAllowPlaintext"`
+	RecordArchivePath     string       `json:"recordArchivePath"`
+	RecordArchiveAfter    Duration     `json:"recordArchiveAfter"`
+	RecordLayout          string       `json:"recordLayout"`
+	RecordAliases         []string     `json:"recordAliases"`
 
 	// Authentication (deprecated)
 	PublishUser *Credential `json:"publishUser,omitempty"` // deprecated
@@ -215,6 +222,7 @@ type Path struct {
 	RunOnUnread                string   `json:"runOnUnread"`
 	RunOnRecordSegmentCreate   string   `json:"runOnRecordSegmentCreate"`
 	RunOnRecordSegmentComplete string   `json:"runOnRecordSegmentComplete"`
+	RunOnRecordSegmentDelete   string   `json:"runOnRecordSegmentDelete"`
 }
 
 func (pconf *Path) setDefaults() {
@@ -229,6 +237,7 @@ func (pconf *Path) setDefaults() {
 	pconf.RecordPartDuration = Duration(1 * time.Second)
 	pconf.RecordSegmentDuration = 3600 * Duration(time.Second)
 	pconf.RecordDeleteAfter = 24 * 3600 * Duration(time.Second)
+	pconf.RecordAliases = []string{}
 
 	// Publisher source
 	pconf.OverridePublisher = true
@@ -546,6 +555,8 @@ func (pconf *Path) validate(
 			primary.RPICameraSecondaryJPEGQuality = pconf.RPICameraJPEGQuality
 		}
 
+	case pconf.Source == "testPattern":
+
 	default:
 		return fmt.Errorf("invalid source: '%s'", pconf.Source)
 	}
@@ -570,22 +581,33 @@ func (pconf *Path) validate(
 		l.Log(logger.Warn, "parameter 'playback' is deprecated and has no effect")
 	}
 
-	if !strings.Contains(pconf.RecordPath, "%path") {
-		return fmt.Errorf("'recordPath' must contain %%path")
-	}
+	if pconf.RecordLayout != "" {
+		// segments are written and managed by an external tool
+		if pconf.Record {
+			return fmt.Errorf("'recordLayout' cannot be used together with 'record'")
+		}
 
-	if !strings.Contains(pconf.RecordPath, "%s") &&
-		(!strings.Contains(pconf.RecordPath, "%Y") ||
-			!strings.Contains(pconf.RecordPath, "%m") ||
-			!strings.Contains(pconf.RecordPath, "%d") ||
-			!strings.Contains(pconf.RecordPath, "%H") ||
-			!strings.Contains(pconf.RecordPath, "%M") ||
-			!strings.Contains(pconf.RecordPath, "%S")) {
-		return fmt.Errorf("'recordPath' must contain either %%s or %%Y %%m %%d %%H %%M %%S")
-	}
+		if pconf.RecordDeleteAfter != 0 || pconf.RecordArchiveAfter != 0 {
+			return fmt.Errorf("'recordLayout' requires 'recordDeleteAfter' and 'recordArchiveAfter' to be 0")
+		}
+	} else {
+		if !strings.Contains(pconf.RecordPath, "%path") {
+			return fmt.Errorf("'recordPath' must contain %%path")
+		}
+
+		if !strings.Contains(pconf.RecordPath, "%s") &&
+			(!strings.Contains(pconf.RecordPath, "%Y") ||
+				!strings.Contains(pconf.RecordPath, "%m") ||
+				!strings.Contains(pconf.RecordPath, "%d") ||
+				!strings.Contains(pconf.RecordPath, "%H") ||
+				!strings.Contains(pconf.RecordPath, "%M") ||
+				!strings.Contains(pconf.RecordPath, "%S")) {
+			return fmt.Errorf("'recordPath' must contain either %%s or %%Y %%m %%d %%H %%M %%S")
+		}
 
-	if conf.Playback && !strings.Contains(pconf.RecordPath, "%f") {
-		return fmt.Errorf("'recordPath' must contain %%f")
+		if conf.Playback.Enable && !strings.Contains(pconf.RecordPath, "%f") {
+			return fmt.Errorf("'recordPath' must contain %%f")
+		}
 	}
 
 	if pconf.RecordSegmentDuration > Duration(24*time.Hour) { // avoid overflowing DurationV0 of mvhd
@@ -596,6 +618,54 @@ func (pconf *Path) validate(
 		return fmt.Errorf("'recordDeleteAfter' cannot be lower than 'recordSegmentDuration'")
 	}
 
+	if pconf.RecordEncryptionKey != "" {
+		if key, err := hex.DecodeString(pconf.RecordEncryptionKey); err != nil || len(key) != 32 {
+			return fmt.Errorf("'recordEncryptionKey' must be a 256-bit key in hexadecimal format")
+		}
+	}
+
+	if pconf.RecordAllowPlaintext && pconf.RecordEncryptionKey == "" {
+		return fmt.Errorf("'recordAllowPlaintext' requires 'recordEncryptionKey'")
+	}
+
+	if pconf.RecordArchivePath != "" {
+		if !strings.Contains(pconf.RecordArchivePath, "%path") {
+			return fmt.Errorf("'recordArchivePath' must contain %%path")
+		}
+
+		if pconf.RecordArchivePath == pconf.RecordPath {
+			return fmt.Errorf("'recordArchivePath' must be different from 'recordPath'")
+		}
+	}
+
+	if len(pconf.RecordAliases) != 0 {
+		if pconf.Regexp != nil {
+			return fmt.Errorf("a path with a regular expression (or path 'all')" +
+				" does not support option 'recordAliases'")
+		}
+
+		for _, alias := range pconf.RecordAliases {
+			if err := IsValidPathName(alias); err != nil {
+				return fmt.Errorf("invalid alias in 'recordAliases': '%s'", alias)
+			}
+
+			// recordings of different paths would be mixed
+			if _, ok := conf.OptionalPaths[alias]; ok {
+				return fmt.Errorf("'recordAliases' cannot contain the name of a path ('%s')", alias)
+			}
+		}
+	}
+
+	if pconf.RecordArchiveAfter != 0 {
+		if pconf.RecordArchivePath == "" {
+			return fmt.Errorf("'recordArchiveAfter' requires 'recordArchivePath'")
+		}
+
+		if pconf.RecordArchiveAfter < pconf.RecordSegmentDuration {
+			return fmt.Errorf("'recordArchiveAfter' cannot be lower than 'recordSegmentDuration'")
+		}
+	}
+
 	// Authentication (deprecated)
 
 	if deprecatedCredentialsMode {
@@ -0,0 +1,188 @@
+package conf
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+)
+
+// Playback contains playback server settings.
+type Playback struct {
+	Enable         bool                `json:"playback"`
+	Address        string              `json:"playbackAddress"`
+	Encryption     bool                `json:"playbackEncryption"`
+	ServerKey      string              `json:"playbackServerKey"`
+	ServerCert     string              `json:"playbackServerCert"`
+	AllowOrigin    *string             `json:"playbackAllowOrigin,omitempty"` // deprecated
+	AllowOrigins   []string            `json:"playbackAllowOrigins"`
+	AllowHeaders   []string            `json:"playbackAllowHeaders"`
+	AllowMethods   []string            `json:"playbackAllowMethods"`
+	TrustedProxies IPNetworks          `json:"playbackTrustedProxies"`
+	ProxyProtocol  bool                `json:"playbackProxyProtocol"`
+	ExternalURL    string              `json:"playbackExternalURL"`
+	HTTP2          bool                `json:"playbackHTTP2"`
+	StallAction    PlaybackStallAction `json:"playbackStallAction"`
+	MemoryBudget   StringSize          `json:"playbackMemoryBudget"`
+	WriteBuffer    StringSize          `json:"playbackWriteBuffer"`
+	Peers          []string            `json:"playbackPeers"`
+	ReadOnly       bool                `json:"playbackReadOnly"`
+	AuthCacheTTL   Duration            `json:"playbackAuthCacheTTL"`
+	LoadThreshold  StringSize          `json:"playbackLoadThreshold"`
+	LoadPolicy     PlaybackLoadPolicy  `json:"playbackLoadPolicy"`
+	BulkDuration   Duration            `json:"playbackBulkDuration"`
+	MaxInteractive int                 `json:"playbackMaxInteractive"`
+	MaxBulk        int                 `json:"playbackMaxBulk"`
+	RateLimit      int                 `json:"playbackRateLimit"`
+	RangePolicy    PlaybackRangePolicy `json:"playbackRangePolicy"`
+	MaxClockSkew   Duration            `json:"playbackMaxClockSkew"`
+	OffloadHeader  string              `json:"playbackOffloadHeader"`
+	OffloadPrefix  string              `json:"playbackOffloadPrefix"`
+	Compat         PlaybackCompat      `json:"playbackCompat"`
+	CompatDrop     bool                `json:"playbackCompatDrop"`
+	LogIPs         PlaybackLogIPs      `json:"playbackLogIPs"`
+	FaultDelay     Duration            `json:"playbackFaultDelay"`
+	FaultFailures  float64             `json:"playbackFaultFailures"`
+	FaultCorrupt   float64             `json:"playbackFaultCorrupt"`
+}
+
+// Validate checks the settings for errors.
+func (p *Playback) Validate(l logger.Writer) error {
+	if p.AllowOrigin != nil {
+		l.Log(logger.Warn, "parameter 'playbackAllowOrigin' is deprecated "+
+			"and has been replaced with 'playbackAllowOrigins'")
+		p.AllowOrigins = []string{*p.AllowOrigin}
+	}
+
+	for _, entry := range strings.Split(p.Address, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || entry == "unix://" {
+			return fmt.Errorf("'playbackAddress' contains an empty entry")
+		}
+	}
+
+	for _, origin := range p.AllowOrigins {
+		if origin == "" {
+			return fmt.Errorf("'playbackAllowOrigins' contains an empty entry")
+		}
+	}
+
+	for _, method := range p.AllowMethods {
+		if method == "" || strings.ToUpper(method) != method {
+			return fmt.Errorf("invalid method in 'playbackAllowMethods': '%s'", method)
+		}
+	}
+
+	peers := make(map[string]struct{})
+	for _, peer := range p.Peers {
+		u, err := url.Parse(peer)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid peer in 'playbackPeers': '%s'", peer)
+		}
+
+		if _, ok := peers[peer]; ok {
+			return fmt.Errorf("'playbackPeers' contains '%s' twice", peer)
+		}
+		peers[peer] = struct{}{}
+	}
+
+	if p.ExternalURL != "" {
+		u, err := url.Parse(p.ExternalURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+			return fmt.Errorf("invalid 'playbackExternalURL': '%s'", p.ExternalURL)
+		}
+	}
+
+	if p.ProxyProtocol && len(p.TrustedProxies) == 0 {
+		for _, entry := range strings.Split(p.Address, ",") {
+			// Unix sockets can be reached by local peers only
+			if !strings.HasPrefix(strings.TrimSpace(entry), "unix://") {
+				return fmt.Errorf("'playbackProxyProtocol' requires the load balancer to be in 'playbackTrustedProxies'")
+			}
+		}
+	}
+
+	if p.AuthCacheTTL < 0 {
+		return fmt.Errorf("'playbackAuthCacheTTL' cannot be negative")
+	}
+
+	if p.BulkDuration < 0 {
+		return fmt.Errorf("'playbackBulkDuration' cannot be negative")
+	}
+
+	if p.MaxInteractive < 0 {
+		return fmt.Errorf("'playbackMaxInteractive' cannot be negative")
+	}
+
+	if p.MaxBulk < 0 {
+		return fmt.Errorf("'playbackMaxBulk' cannot be negative")
+	}
+
+	if p.RateLimit < 0 {
+		return fmt.Errorf("'playbackRateLimit' cannot be negative")
+	}
+
+	if p.MaxClockSkew < 0 {
+		return fmt.Errorf("'playbackMaxClockSkew' cannot be negative")
+	}
+
+	if p.FaultDelay < 0 {
+		return fmt.Errorf("'playbackFaultDelay' cannot be negative")
+	}
+
+	if p.FaultFailures < 0 || p.FaultFailures > 100 {
+		return fmt.Errorf("'playbackFaultFailures' must be a percentage between 0 and 100")
+	}
+
+	if p.FaultCorrupt < 0 || p.FaultCorrupt > 100 {
+		return fmt.Errorf("'playbackFaultCorrupt' must be a percentage between 0 and 100")
+	}
+
+	if p.WriteBuffer > 16*1024*1024 {
+		return fmt.Errorf("'playbackWriteBuffer' cannot be greater than 16M")
+	}
+
+	switch p.OffloadHeader {
+	case "":
+		if p.OffloadPrefix != "" {
+			return fmt.Errorf("'playbackOffloadPrefix' requires 'playbackOffloadHeader' to be X-Accel-Redirect")
+		}
+
+	case "X-Sendfile":
+		if p.OffloadPrefix != "" {
+			return fmt.Errorf("'playbackOffloadPrefix' cannot be used with X-Sendfile, " +
+				"that uses paths of files")
+		}
+
+	case "X-Accel-Redirect":
+		if !strings.HasPrefix(p.OffloadPrefix, "/") {
+			return fmt.Errorf("'playbackOffloadPrefix' must be an absolute URI when using X-Accel-Redirect")
+		}
+
+	default:
+		return fmt.Errorf("invalid 'playbackOffloadHeader': '%s' "+
+			"(available values are X-Accel-Redirect and X-Sendfile)", p.OffloadHeader)
+	}
+
+	return nil
+}
+
+// validatePlaybackPaths validates playback settings that depend on path settings.
+func (conf *Conf) validatePlaybackPaths() error {
+	if !conf.Playback.Enable || !conf.Playback.ReadOnly {
+		return nil
+	}
+
+	for _, name := range sortedKeys(conf.OptionalPaths) {
+		// the record cleaner would delete or move recordings written by another instance
+		if conf.Paths[name].RecordDeleteAfter != 0 {
+			return fmt.Errorf("'playbackReadOnly' requires 'recordDeleteAfter' to be 0 (path '%s')", name)
+		}
+		if conf.Paths[name].RecordArchiveAfter != 0 {
+			return fmt.Errorf("'playbackReadOnly' requires 'recordArchiveAfter' to be 0 (path '%s')", name)
+		}
+	}
+
+	return nil
+}
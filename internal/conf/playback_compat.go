@@ -0,0 +1,72 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// PlaybackCompat is the compatibility profile of playback exports.
+type PlaybackCompat int
+
+// playback compatibility profiles.
+const (
+	PlaybackCompatDefault PlaybackCompat = iota
+	PlaybackCompatApple
+	PlaybackCompatLegacy
+	PlaybackCompatInband
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d PlaybackCompat) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case PlaybackCompatDefault:
+		out = "default"
+
+	case PlaybackCompatApple:
+		out = "apple"
+
+	case PlaybackCompatLegacy:
+		out = "legacy"
+
+	default:
+		out = "inband"
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *PlaybackCompat) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "default":
+		*d = PlaybackCompatDefault
+
+	case "apple":
+		*d = PlaybackCompatApple
+
+	case "legacy":
+		*d = PlaybackCompatLegacy
+
+	case "inband":
+		*d = PlaybackCompatInband
+
+	default:
+		return fmt.Errorf("invalid playbackCompat: '%s'", in)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (d *PlaybackCompat) UnmarshalEnv(_ string, v string) error {
+	return d.UnmarshalJSON([]byte(`"` + v + `"`))
+}
@@ -0,0 +1,65 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// PlaybackLoadPolicy is the policy applied to playback exports when live streaming is busy.
+type PlaybackLoadPolicy int
+
+// playback load policies.
+const (
+	PlaybackLoadPolicyReject PlaybackLoadPolicy = iota
+	PlaybackLoadPolicyQueue
+	PlaybackLoadPolicyThrottle
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d PlaybackLoadPolicy) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case PlaybackLoadPolicyReject:
+		out = "reject"
+
+	case PlaybackLoadPolicyQueue:
+		out = "queue"
+
+	default:
+		out = "throttle"
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *PlaybackLoadPolicy) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "reject":
+		*d = PlaybackLoadPolicyReject
+
+	case "queue":
+		*d = PlaybackLoadPolicyQueue
+
+	case "throttle":
+		*d = PlaybackLoadPolicyThrottle
+
+	default:
+		return fmt.Errorf("invalid playbackLoadPolicy: '%s'", in)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (d *PlaybackLoadPolicy) UnmarshalEnv(_ string, v string) error {
+	return d.UnmarshalJSON([]byte(`"` + v + `"`))
+}
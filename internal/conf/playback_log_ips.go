@@ -0,0 +1,65 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// PlaybackLogIPs is the way in which IPs of playback clients are written into logs.
+type PlaybackLogIPs int
+
+// playback log IP modes.
+const (
+	PlaybackLogIPsFull PlaybackLogIPs = iota
+	PlaybackLogIPsTruncate
+	PlaybackLogIPsHash
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d PlaybackLogIPs) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case PlaybackLogIPsFull:
+		out = "full"
+
+	case PlaybackLogIPsTruncate:
+		out = "truncate"
+
+	default:
+		out = "hash"
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *PlaybackLogIPs) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "full":
+		*d = PlaybackLogIPsFull
+
+	case "truncate":
+		*d = PlaybackLogIPsTruncate
+
+	case "hash":
+		*d = PlaybackLogIPsHash
+
+	default:
+		return fmt.Errorf("invalid playbackLogIPs: '%s'", in)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (d *PlaybackLogIPs) UnmarshalEnv(_ string, v string) error {
+	return d.UnmarshalJSON([]byte(`"` + v + `"`))
+}
@@ -0,0 +1,65 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// PlaybackRangePolicy is the policy applied to playback requests whose window is outside of recordings.
+type PlaybackRangePolicy int
+
+// playback range policies.
+const (
+	PlaybackRangePolicyReject PlaybackRangePolicy = iota
+	PlaybackRangePolicyClamp
+	PlaybackRangePolicyWait
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d PlaybackRangePolicy) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case PlaybackRangePolicyReject:
+		out = "reject"
+
+	case PlaybackRangePolicyClamp:
+		out = "clamp"
+
+	default:
+		out = "wait"
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *PlaybackRangePolicy) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "reject":
+		*d = PlaybackRangePolicyReject
+
+	case "clamp":
+		*d = PlaybackRangePolicyClamp
+
+	case "wait":
+		*d = PlaybackRangePolicyWait
+
+	default:
+		return fmt.Errorf("invalid playbackRangePolicy: '%s'", in)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (d *PlaybackRangePolicy) UnmarshalEnv(_ string, v string) error {
+	return d.UnmarshalJSON([]byte(`"` + v + `"`))
+}
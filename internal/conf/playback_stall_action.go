@@ -0,0 +1,58 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// PlaybackStallAction is the action performed when a playback client stalls.
+type PlaybackStallAction int
+
+// playback stall actions.
+const (
+	PlaybackStallActionAbort PlaybackStallAction = iota
+	PlaybackStallActionThrottle
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d PlaybackStallAction) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case PlaybackStallActionAbort:
+		out = "abort"
+
+	default:
+		out = "throttle"
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *PlaybackStallAction) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "abort":
+		*d = PlaybackStallActionAbort
+
+	case "throttle":
+		*d = PlaybackStallActionThrottle
+
+	default:
+		return fmt.Errorf("invalid playbackStallAction: '%s'", in)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (d *PlaybackStallAction) UnmarshalEnv(_ string, v string) error {
+	return d.UnmarshalJSON([]byte(`"` + v + `"`))
+}
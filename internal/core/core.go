@@ -17,6 +17,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/bluenviron/mediamtx/internal/api"
+	"github.com/bluenviron/mediamtx/internal/audit"
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/confwatcher"
@@ -26,6 +27,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/playback"
 	"github.com/bluenviron/mediamtx/internal/pprof"
 	"github.com/bluenviron/mediamtx/internal/recordcleaner"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/rlimit"
 	"github.com/bluenviron/mediamtx/internal/servers/hls"
 	"github.com/bluenviron/mediamtx/internal/servers/rtmp"
@@ -47,14 +49,22 @@ var defaultConfPaths = []string{
 	"/etc/mediamtx/mediamtx.yml",
 }
 
+// can be replaced in tests.
+var osExit = os.Exit
+
 var cli struct {
-	Version  bool   `help:"print version"`
-	Confpath string `arg:"" default:""`
+	Version bool `help:"print version"`
+
+	Run struct {
+		Confpath string `arg:"" default:""`
+	} `cmd:"" default:"withargs" help:"run the server (default)"`
+
+	PlaybackCheck playbackCheckCmd `cmd:"" help:"check whether a range of recordings is playable"`
 }
 
-func atLeastOneRecordDeleteAfter(pathConfs map[string]*conf.Path) bool {
+func atLeastOneRecordDeleteOrArchiveAfter(pathConfs map[string]*conf.Path) bool {
 	for _, e := range pathConfs {
-		if e.RecordDeleteAfter != 0 {
+		if e.RecordDeleteAfter != 0 || e.RecordArchiveAfter != 0 {
 			return true
 		}
 	}
@@ -82,9 +92,11 @@ type Core struct {
 	logger          *logger.Logger
 	externalCmdPool *externalcmd.Pool
 	authManager     *auth.Manager
+	auditLog        *audit.Log
 	metrics         *metrics.Metrics
 	pprof           *pprof.PPROF
 	recordCleaner   *recordcleaner.Cleaner
+	recordWatcher   *recordstore.Watcher
 	playbackServer  *playback.Server
 	pathManager     *pathManager
 	rtspServer      *rtsp.Server
@@ -122,7 +134,7 @@ func New(args []string) (*Core, bool) {
 		panic(err)
 	}
 
-	_, err = parser.Parse(args)
+	kctx, err := parser.Parse(args)
 	parser.FatalIfErrorf(err)
 
 	if cli.Version {
@@ -130,6 +142,12 @@ func New(args []string) (*Core, bool) {
 		os.Exit(0)
 	}
 
+	// the command is "playback-check <confpath>" when the confpath is provided
+	if strings.HasPrefix(kctx.Command(), "playback-check") {
+		osExit(cli.PlaybackCheck.run())
+		return nil, false
+	}
+
 	ctx, ctxCancel := context.WithCancel(context.Background())
 
 	p := &Core{
@@ -141,7 +159,7 @@ func New(args []string) (*Core, bool) {
 
 	tempLogger, _ := logger.New(logger.Warn, []logger.Destination{logger.DestinationStdout}, "", "")
 
-	p.conf, p.confPath, err = conf.Load(cli.Confpath, defaultConfPaths, tempLogger)
+	p.conf, p.confPath, err = conf.Load(cli.Run.Confpath, defaultConfPaths, tempLogger)
 	if err != nil {
 		fmt.Printf("ERR: %s\n", err)
 		return nil, false
@@ -274,6 +292,12 @@ func (p *Core) createResources(initial bool) error {
 
 		p.externalCmdPool = &externalcmd.Pool{}
 		p.externalCmdPool.Initialize()
+
+		p.recordWatcher = &recordstore.Watcher{
+			PathConfs: p.conf.Paths,
+			Parent:    p,
+		}
+		p.recordWatcher.Initialize()
 	}
 
 	if p.authManager == nil {
@@ -287,10 +311,23 @@ func (p *Core) createResources(initial bool) error {
 			JWTClaimKey:        p.conf.AuthJWTClaimKey,
 			JWTExclude:         p.conf.AuthJWTExclude,
 			JWTInHTTPQuery:     p.conf.AuthJWTInHTTPQuery,
+			APIKeys:            p.conf.AuthAPIKeys,
 			ReadTimeout:        time.Duration(p.conf.ReadTimeout),
 		}
 	}
 
+	if p.conf.AuditLogFile != "" &&
+		p.auditLog == nil {
+		i := &audit.Log{
+			FilePath: p.conf.AuditLogFile,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.auditLog = i
+	}
+
 	if p.conf.Metrics &&
 		p.metrics == nil {
 		i := &metrics.Metrics{
@@ -332,35 +369,16 @@ func (p *Core) createResources(initial bool) error {
 	}
 
 	if p.recordCleaner == nil &&
-		atLeastOneRecordDeleteAfter(p.conf.Paths) {
+		atLeastOneRecordDeleteOrArchiveAfter(p.conf.Paths) {
 		p.recordCleaner = &recordcleaner.Cleaner{
-			PathConfs: p.conf.Paths,
-			Parent:    p,
+			PathConfs:       p.conf.Paths,
+			AuditLog:        p.auditLog,
+			ExternalCmdPool: p.externalCmdPool,
+			Parent:          p,
 		}
 		p.recordCleaner.Initialize()
 	}
 
-	if p.conf.Playback &&
-		p.playbackServer == nil {
-		i := &playback.Server{
-			Address:        p.conf.PlaybackAddress,
-			Encryption:     p.conf.PlaybackEncryption,
-			ServerKey:      p.conf.PlaybackServerKey,
-			ServerCert:     p.conf.PlaybackServerCert,
-			AllowOrigin:    p.conf.PlaybackAllowOrigin,
-			TrustedProxies: p.conf.PlaybackTrustedProxies,
-			ReadTimeout:    p.conf.ReadTimeout,
-			PathConfs:      p.conf.Paths,
-			AuthManager:    p.authManager,
-			Parent:         p,
-		}
-		err = i.Initialize()
-		if err != nil {
-			return err
-		}
-		p.playbackServer = i
-	}
-
 	if p.pathManager == nil {
 		rtpMaxPayloadSize := getRTPMaxPayloadSize(p.conf.UDPMaxPayloadSize, p.conf.RTSPEncryption)
 
@@ -374,12 +392,35 @@ func (p *Core) createResources(initial bool) error {
 			rtpMaxPayloadSize: rtpMaxPayloadSize,
 			pathConfs:         p.conf.Paths,
 			externalCmdPool:   p.externalCmdPool,
+			recordWatcher:     p.recordWatcher,
 			metrics:           p.metrics,
 			parent:            p,
 		}
 		p.pathManager.initialize()
 	}
 
+	if p.conf.Playback.Enable &&
+		p.playbackServer == nil {
+		i := &playback.Server{
+			Playback:        p.conf.Playback,
+			AuthMethod:      p.conf.AuthMethod,
+			ReadTimeout:     p.conf.ReadTimeout,
+			WriteTimeout:    p.conf.WriteTimeout,
+			PathConfs:       p.conf.Paths,
+			AuthManager:     p.authManager,
+			PathManager:     p.pathManager,
+			AuditLog:        p.auditLog,
+			ExternalCmdPool: p.externalCmdPool,
+			Version:         string(version),
+			Parent:          p,
+		}
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.playbackServer = i
+	}
+
 	if p.conf.RTSP &&
 		(p.conf.RTSPEncryption == conf.EncryptionNo ||
 			p.conf.RTSPEncryption == conf.EncryptionOptional) &&
@@ -600,24 +641,26 @@ func (p *Core) createResources(initial bool) error {
 	if p.conf.API &&
 		p.api == nil {
 		i := &api.API{
-			Address:        p.conf.APIAddress,
-			Encryption:     p.conf.APIEncryption,
-			ServerKey:      p.conf.APIServerKey,
-			ServerCert:     p.conf.APIServerCert,
-			AllowOrigin:    p.conf.APIAllowOrigin,
-			TrustedProxies: p.conf.APITrustedProxies,
-			ReadTimeout:    p.conf.ReadTimeout,
-			Conf:           p.conf,
-			AuthManager:    p.authManager,
-			PathManager:    p.pathManager,
-			RTSPServer:     p.rtspServer,
-			RTSPSServer:    p.rtspsServer,
-			RTMPServer:     p.rtmpServer,
-			RTMPSServer:    p.rtmpsServer,
-			HLSServer:      p.hlsServer,
-			WebRTCServer:   p.webRTCServer,
-			SRTServer:      p.srtServer,
-			Parent:         p,
+			Address:         p.conf.APIAddress,
+			Encryption:      p.conf.APIEncryption,
+			ServerKey:       p.conf.APIServerKey,
+			ServerCert:      p.conf.APIServerCert,
+			AllowOrigin:     p.conf.APIAllowOrigin,
+			TrustedProxies:  p.conf.APITrustedProxies,
+			ReadTimeout:     p.conf.ReadTimeout,
+			Conf:            p.conf,
+			AuthManager:     p.authManager,
+			PathManager:     p.pathManager,
+			RTSPServer:      p.rtspServer,
+			RTSPSServer:     p.rtspsServer,
+			RTMPServer:      p.rtmpServer,
+			RTMPSServer:     p.rtmpsServer,
+			HLSServer:       p.hlsServer,
+			WebRTCServer:    p.webRTCServer,
+			SRTServer:       p.srtServer,
+			AuditLog:        p.auditLog,
+			ExternalCmdPool: p.externalCmdPool,
+			Parent:          p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -659,6 +702,13 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.authManager.ReloadInternalUsers(newConf.AuthInternalUsers)
 	}
 
+	if !closeAuthManager && !reflect.DeepEqual(newConf.AuthAPIKeys, p.conf.AuthAPIKeys) {
+		p.authManager.ReloadAPIKeys(newConf.AuthAPIKeys)
+	}
+
+	closeAuditLog := newConf == nil ||
+		newConf.AuditLogFile != p.conf.AuditLogFile
+
 	closeMetrics := newConf == nil ||
 		newConf.Metrics != p.conf.Metrics ||
 		newConf.MetricsAddress != p.conf.MetricsAddress ||
@@ -684,25 +734,14 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeLogger
 
 	closeRecorderCleaner := newConf == nil ||
-		atLeastOneRecordDeleteAfter(newConf.Paths) != atLeastOneRecordDeleteAfter(p.conf.Paths) ||
+		atLeastOneRecordDeleteOrArchiveAfter(newConf.Paths) != atLeastOneRecordDeleteOrArchiveAfter(p.conf.Paths) ||
+		closeAuditLog ||
 		closeLogger
 	if !closeRecorderCleaner && p.recordCleaner != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
 		p.recordCleaner.ReloadPathConfs(newConf.Paths)
 	}
-
-	closePlaybackServer := newConf == nil ||
-		newConf.Playback != p.conf.Playback ||
-		newConf.PlaybackAddress != p.conf.PlaybackAddress ||
-		newConf.PlaybackEncryption != p.conf.PlaybackEncryption ||
-		newConf.PlaybackServerKey != p.conf.PlaybackServerKey ||
-		newConf.PlaybackServerCert != p.conf.PlaybackServerCert ||
-		newConf.PlaybackAllowOrigin != p.conf.PlaybackAllowOrigin ||
-		!reflect.DeepEqual(newConf.PlaybackTrustedProxies, p.conf.PlaybackTrustedProxies) ||
-		newConf.ReadTimeout != p.conf.ReadTimeout ||
-		closeAuthManager ||
-		closeLogger
-	if !closePlaybackServer && p.playbackServer != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
-		p.playbackServer.ReloadPathConfs(newConf.Paths)
+	if newConf != nil && p.recordWatcher != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
+		p.recordWatcher.ReloadPathConfs(newConf.Paths)
 	}
 
 	closePathManager := newConf == nil ||
@@ -720,6 +759,24 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.pathManager.ReloadPathConfs(newConf.Paths)
 	}
 
+	closePlaybackServer := newConf == nil ||
+		!reflect.DeepEqual(newConf.Playback, p.conf.Playback) ||
+		newConf.ReadTimeout != p.conf.ReadTimeout ||
+		newConf.WriteTimeout != p.conf.WriteTimeout ||
+		closePathManager ||
+		closeAuthManager ||
+		closeAuditLog ||
+		closeLogger
+	if !closePlaybackServer && p.playbackServer != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
+		p.playbackServer.ReloadPathConfs(newConf.Paths)
+	}
+
+	if !closePlaybackServer && p.playbackServer != nil &&
+		(!reflect.DeepEqual(newConf.AuthInternalUsers, p.conf.AuthInternalUsers) ||
+			!reflect.DeepEqual(newConf.AuthAPIKeys, p.conf.AuthAPIKeys)) {
+		p.playbackServer.ReloadCredentials()
+	}
+
 	closeRTSPServer := newConf == nil ||
 		newConf.RTSP != p.conf.RTSP ||
 		newConf.RTSPEncryption != p.conf.RTSPEncryption ||
@@ -864,6 +921,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeHLSServer ||
 		closeWebRTCServer ||
 		closeSRTServer ||
+		closeAuditLog ||
 		closeLogger
 
 	if newConf == nil && p.confWatcher != nil {
@@ -915,16 +973,16 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.rtspServer = nil
 	}
 
-	if closePathManager && p.pathManager != nil {
-		p.pathManager.close()
-		p.pathManager = nil
-	}
-
 	if closePlaybackServer && p.playbackServer != nil {
 		p.playbackServer.Close()
 		p.playbackServer = nil
 	}
 
+	if closePathManager && p.pathManager != nil {
+		p.pathManager.close()
+		p.pathManager = nil
+	}
+
 	if closeRecorderCleaner && p.recordCleaner != nil {
 		p.recordCleaner.Close()
 		p.recordCleaner = nil
@@ -940,6 +998,11 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.metrics = nil
 	}
 
+	if closeAuditLog && p.auditLog != nil {
+		p.auditLog.Close()
+		p.auditLog = nil
+	}
+
 	if closeAuthManager && p.authManager != nil {
 		p.authManager = nil
 	}
@@ -949,6 +1012,10 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.externalCmdPool.Close()
 	}
 
+	if newConf == nil && p.recordWatcher != nil {
+		p.recordWatcher.Close()
+	}
+
 	if closeLogger && p.logger != nil {
 		p.logger.Close()
 		p.logger = nil
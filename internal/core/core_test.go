@@ -95,6 +95,37 @@ func TestCoreErrors(t *testing.T) {
 	}
 }
 
+func TestCoreRecordCleaner(t *testing.T) {
+	for _, ca := range []string{"delete", "archive"} {
+		t.Run(ca, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mediamtx-core")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			var conf string
+			if ca == "delete" {
+				conf = "pathDefaults:\n" +
+					"  recordDeleteAfter: 2h\n" +
+					"paths:\n" +
+					"  mypath:\n"
+			} else {
+				conf = "pathDefaults:\n" +
+					"  recordDeleteAfter: 0s\n" +
+					"  recordArchivePath: " + filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f") + "\n" +
+					"  recordArchiveAfter: 2h\n" +
+					"paths:\n" +
+					"  mypath:\n"
+			}
+
+			p, ok := newInstance(conf)
+			require.Equal(t, true, ok)
+			defer p.Close()
+
+			require.NotNil(t, p.recordCleaner)
+		})
+	}
+}
+
 func TestCoreHotReloading(t *testing.T) {
 	confPath := filepath.Join(os.TempDir(), "rtsp-conf")
 
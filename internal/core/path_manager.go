@@ -12,6 +12,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/metrics"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/servers/hls"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
@@ -73,6 +74,7 @@ type pathManager struct {
 	rtpMaxPayloadSize int
 	pathConfs         map[string]*conf.Path
 	externalCmdPool   *externalcmd.Pool
+	recordWatcher     *recordstore.Watcher
 	metrics           *metrics.Metrics
 	parent            pathManagerParent
 
@@ -434,6 +436,7 @@ func (pm *pathManager) createPath(
 		matches:           matches,
 		wg:                &pm.wg,
 		externalCmdPool:   pm.externalCmdPool,
+		recordWatcher:     pm.recordWatcher,
 		parent:            pm,
 	}
 	pa.initialize()
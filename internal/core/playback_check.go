@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/playback"
+)
+
+type playbackCheckCmd struct {
+	Confpath string    `arg:"" default:""`
+	Path     string    `required:"" help:"path name"`
+	Start    time.Time `required:"" help:"start date in RFC3339 format"`
+	Duration string    `required:"" help:"duration, in seconds, with units (i.e. 1h30m) or in ISO 8601 format"`
+	Format   string    `default:"fmp4" help:"output format (fmp4, mp4 or ts)"`
+}
+
+// run simulates a download and prints a report. It returns the exit code.
+func (c *playbackCheckCmd) run() int {
+	tempLogger, _ := logger.New(logger.Warn, []logger.Destination{logger.DestinationStdout}, "", "")
+
+	cnf, _, err := conf.Load(c.Confpath, defaultConfPaths, tempLogger)
+	if err != nil {
+		fmt.Printf("ERR: %s\n", err)
+		return 1
+	}
+
+	pathConf, _, err := conf.FindPathConf(cnf.Paths, c.Path)
+	if err != nil {
+		fmt.Printf("ERR: %s\n", err)
+		return 1
+	}
+
+	duration, err := playback.ParseDuration(c.Duration)
+	if err != nil {
+		fmt.Printf("ERR: invalid duration: %s\n", err)
+		return 1
+	}
+	if duration <= 0 {
+		fmt.Printf("ERR: invalid duration: must be greater than zero\n")
+		return 1
+	}
+
+	r, err := playback.Check(pathConf, c.Path, c.Start, duration, c.Format)
+	if err != nil {
+		fmt.Printf("ERR: %s\n", err)
+		return 1
+	}
+
+	for _, seg := range r.Segments {
+		if seg.Err != nil {
+			fmt.Printf("segment %s: corrupted: %s\n", seg.Fpath, seg.Err)
+		} else {
+			fmt.Printf("segment %s: start %s, duration %v\n",
+				seg.Fpath, seg.Start.Format(time.RFC3339Nano), seg.Duration)
+		}
+	}
+
+	for _, gap := range r.Gaps {
+		if gap.TracksChanged {
+			fmt.Printf("tracks change at %s\n", gap.Start.Format(time.RFC3339Nano))
+		} else {
+			fmt.Printf("gap of %v at %s\n", gap.Duration, gap.Start.Format(time.RFC3339Nano))
+		}
+	}
+
+	if !r.Playable() {
+		if r.Err != nil {
+			fmt.Printf("range is not playable: %s\n", r.Err)
+		} else {
+			fmt.Printf("range is not playable: no samples found\n")
+		}
+		return 1
+	}
+
+	fmt.Printf("range is playable: %v of media, %d bytes\n",
+		r.MediaTime, r.BytesWritten)
+	return 0
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaybackCheckNoConfpath(t *testing.T) {
+	exitCode := -1
+	osExit = func(code int) {
+		exitCode = code
+	}
+	defer func() { osExit = os.Exit }()
+
+	// the path is not configured since there's no configuration file
+	p, ok := New([]string{
+		"playback-check",
+		"--path=mypath",
+		"--start=2008-11-07T11:22:00Z",
+		"--duration=1m",
+	})
+	require.False(t, ok)
+	require.Nil(t, p)
+	require.Equal(t, 1, exitCode)
+}
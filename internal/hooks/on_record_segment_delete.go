@@ -0,0 +1,49 @@
+package hooks
+
+import (
+	"strconv"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
+	"github.com/bluenviron/mediamtx/internal/logger"
+)
+
+// OnRecordSegmentDeleteParams are the parameters of OnRecordSegmentDelete.
+type OnRecordSegmentDeleteParams struct {
+	Logger          logger.Writer
+	ExternalCmdPool *externalcmd.Pool
+	Conf            *conf.Path
+	PathName        string
+	SegmentPath     string
+}
+
+// OnRecordSegmentDelete is the OnRecordSegmentDelete hook.
+// Segments are deleted by the record cleaner, the playback server and the API,
+// that are not tied to a path, therefore the environment is built here.
+func OnRecordSegmentDelete(params OnRecordSegmentDeleteParams) {
+	if params.Conf.RunOnRecordSegmentDelete == "" {
+		return
+	}
+
+	env := externalcmd.Environment{
+		"MTX_PATH":         params.PathName,
+		"MTX_SEGMENT_PATH": params.SegmentPath,
+	}
+
+	if params.Conf.Regexp != nil {
+		matches := params.Conf.Regexp.FindStringSubmatch(params.PathName)
+		if len(matches) > 1 {
+			for i, ma := range matches[1:] {
+				env["G"+strconv.FormatInt(int64(i+1), 10)] = ma
+			}
+		}
+	}
+
+	params.Logger.Log(logger.Info, "runOnRecordSegmentDelete command launched")
+	externalcmd.NewCmd(
+		params.ExternalCmdPool,
+		params.Conf.RunOnRecordSegmentDelete,
+		false,
+		env,
+		nil)
+}
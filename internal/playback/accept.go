@@ -0,0 +1,142 @@
+package playback
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+var errNotAcceptable = errors.New("none of the accepted media types can be produced")
+
+// acceptableFormats are the output formats that can be selected with the Accept header,
+// in order of preference. The mp4 format shares its media type with fmp4,
+// therefore it can be selected with the format parameter only.
+var acceptableFormats = []struct {
+	mediaType string
+	format    string
+}{
+	{"video/mp4", "fmp4"},
+	{"video/mp2t", "ts"},
+}
+
+type notAcceptableResponse struct {
+	Error     string   `json:"error"`
+	Supported []string `json:"supported"`
+}
+
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var out []acceptRange
+
+	for _, entry := range strings.Split(header, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mt, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(raw, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		out = append(out, acceptRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	return out
+}
+
+// acceptQuality returns the quality of a media type, that is the one of
+// the most specific range that matches it, or -1 if no range matches.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	specificity := -1
+	q := -1.0
+
+	for _, r := range ranges {
+		var cur int
+
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			cur = 2
+
+		case r.typ == typ && r.subtype == "*":
+			cur = 1
+
+		case r.typ == "*" && r.subtype == "*":
+			cur = 0
+
+		default:
+			continue
+		}
+
+		if cur > specificity {
+			specificity = cur
+			q = r.q
+		}
+	}
+
+	return q
+}
+
+// negotiateFormat returns the output format of a request.
+// The format parameter has precedence, otherwise the format is selected with the Accept header.
+// When no format is acceptable, it writes a 406 response and returns false.
+func (s *Server) negotiateFormat(ctx *gin.Context) (string, bool) {
+	if format := ctx.Query("format"); format != "" {
+		return format, true
+	}
+
+	ctx.Writer.Header().Add("Vary", "Accept")
+
+	header := ctx.Request.Header.Get("Accept")
+	if header == "" {
+		return "", true
+	}
+
+	ranges := parseAccept(header)
+	best := ""
+	bestQ := 0.0
+
+	for _, f := range acceptableFormats {
+		if q := acceptQuality(ranges, f.mediaType); q > bestQ {
+			best = f.format
+			bestQ = q
+		}
+	}
+
+	if best == "" {
+		s.logRequest(ctx, logger.Error, errNotAcceptable.Error())
+		ctx.Error(errNotAcceptable) //nolint:errcheck
+
+		supported := make([]string, len(acceptableFormats))
+		for i, f := range acceptableFormats {
+			supported[i] = f.mediaType
+		}
+
+		ctx.JSON(http.StatusNotAcceptable, notAcceptableResponse{
+			Error:     errNotAcceptable.Error(),
+			Supported: supported,
+		})
+		return "", false
+	}
+
+	return best, true
+}
@@ -0,0 +1,107 @@
+package playback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptQuality(t *testing.T) {
+	ranges := parseAccept("video/*;q=0.5, video/mp2t, */*;q=0.1, text/html;q=invalid")
+
+	require.Equal(t, 1.0, acceptQuality(ranges, "video/mp2t"))
+	require.Equal(t, 0.5, acceptQuality(ranges, "video/mp4"))
+	require.Equal(t, 0.1, acceptQuality(ranges, "audio/mp4"))
+	require.Equal(t, -1.0, acceptQuality(parseAccept("text/html"), "video/mp4"))
+}
+
+func TestOnGetAccept(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegmentAVCC(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	for _, ca := range []struct {
+		name        string
+		format      string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"no header", "", "", http.StatusOK, "video/mp4"},
+		{"any", "", "*/*", http.StatusOK, "video/mp4"},
+		{"mp4", "", "video/mp4", http.StatusOK, "video/mp4"},
+		{"ts", "", "video/MP2T", http.StatusOK, "video/mp2t"},
+		{"quality", "", "video/mp4;q=0.5, video/mp2t;q=0.8", http.StatusOK, "video/mp2t"},
+		{"excluded", "", "video/*, video/mp4;q=0", http.StatusOK, "video/mp2t"},
+		{"parameter", "ts", "video/mp4", http.StatusOK, "video/mp2t"},
+		{"not acceptable", "", "application/vnd.apple.mpegurl", http.StatusNotAcceptable, ""},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			tr := &http.Transport{}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			v := url.Values{}
+			v.Set("path", "mypath")
+			v.Set("start", time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano))
+			v.Set("duration", "3")
+			if ca.format != "" {
+				v.Set("format", ca.format)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:9996/get?"+v.Encode(), nil)
+			require.NoError(t, err)
+
+			if ca.accept != "" {
+				req.Header.Set("Accept", ca.accept)
+			}
+
+			res, err := hc.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, ca.status, res.StatusCode)
+
+			if ca.status != http.StatusOK {
+				var out notAcceptableResponse
+				err = json.NewDecoder(res.Body).Decode(&out)
+				require.NoError(t, err)
+				require.Equal(t, []string{"video/mp4", "video/mp2t"}, out.Supported)
+				return
+			}
+
+			require.Equal(t, ca.contentType, res.Header.Get("Content-Type"))
+		})
+	}
+}
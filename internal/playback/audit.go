@@ -0,0 +1,83 @@
+package playback
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/audit"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// middlewareAudit writes an entry into the audit log once a request has been served.
+func (s *Server) middlewareAudit(action audit.Action) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if s.AuditLog == nil {
+			return
+		}
+
+		ctx.Next()
+
+		err := s.AuditLog.Write(audit.RequestEntry(ctx, action))
+		if err != nil {
+			s.logRequest(ctx, logger.Error, "unable to write audit log: %v", err)
+		}
+	}
+}
+
+func (s *Server) onAuditList(ctx *gin.Context) {
+	if !s.doAuth(ctx, conf.AuthActionAPI, "") {
+		return
+	}
+
+	if s.AuditLog == nil {
+		s.writeError(ctx, http.StatusNotFound, fmt.Errorf("audit log is disabled"))
+		return
+	}
+
+	f := &audit.Filter{
+		Action: audit.Action(ctx.Query("action")),
+		Actor:  ctx.Query("actor"),
+		Path:   ctx.Query("path"),
+	}
+
+	rawStart := ctx.Query("start")
+	if rawStart != "" {
+		tmp, err := time.Parse(time.RFC3339, rawStart)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+			return
+		}
+		f.Start = &tmp
+	}
+
+	rawEnd := ctx.Query("end")
+	if rawEnd != "" {
+		tmp, err := time.Parse(time.RFC3339, rawEnd)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
+			return
+		}
+		f.End = &tmp
+	}
+
+	if raw := ctx.Query("limit"); raw != "" {
+		tmp, err := strconv.ParseUint(raw, 10, 31)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid limit"))
+			return
+		}
+		f.Limit = int(tmp)
+	}
+
+	entries, err := s.AuditLog.Query(f)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,115 @@
+package playback
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/auth"
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// the query usually changes between requests (start, duration),
+// therefore only the JWT passed in the query is part of the key.
+// The HTTP authentication method receives the whole query, that is part of the key too.
+type authCacheKey struct {
+	action conf.AuthAction
+	path   string
+	user   string
+	pass   string
+	token  string
+	apiKey string
+	jwt    string
+	query  string
+	ip     string
+}
+
+func newAuthCacheKey(req *auth.Request, withQuery bool) authCacheKey {
+	k := authCacheKey{
+		action: req.Action,
+		path:   req.Path,
+		user:   req.Credentials.User,
+		pass:   req.Credentials.Pass,
+		token:  req.Credentials.Token,
+		apiKey: req.Credentials.APIKey,
+		ip:     req.IP.String(),
+	}
+
+	if withQuery {
+		k.query = req.Query
+	} else if v, err := url.ParseQuery(req.Query); err == nil {
+		k.jwt = v.Get("jwt")
+	} else {
+		k.query = req.Query
+	}
+
+	return k
+}
+
+type authCacheEntry struct {
+	expiration time.Time
+	apiKeyName string
+}
+
+// authCache stores successful authentications for a short time,
+// in order to avoid querying the authentication backend at every request.
+// Failed authentications are never stored.
+// The cache is flushed when credentials are reloaded, in order not to accept revoked ones.
+type authCache struct {
+	ttl       time.Duration
+	withQuery bool
+
+	mutex   sync.Mutex
+	entries map[authCacheKey]authCacheEntry
+}
+
+func (c *authCache) initialize() {
+	c.entries = make(map[authCacheKey]authCacheEntry)
+}
+
+func (c *authCache) flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[authCacheKey]authCacheEntry)
+}
+
+func (c *authCache) get(req *auth.Request) bool {
+	if c.ttl == 0 {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[newAuthCacheKey(req, c.withQuery)]
+	if !ok || !time.Now().Before(entry.expiration) {
+		return false
+	}
+
+	req.APIKeyName = entry.apiKeyName
+	return true
+}
+
+func (c *authCache) add(req *auth.Request) {
+	if c.ttl == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	// remove expired entries
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiration) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[newAuthCacheKey(req, c.withQuery)] = authCacheEntry{
+		expiration: now.Add(c.ttl),
+		apiKeyName: req.APIKeyName,
+	}
+}
@@ -0,0 +1,164 @@
+package playback
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/auth"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthCache(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment1(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-500000.mp4"))
+
+	var count atomic.Int32
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address:      "127.0.0.1:9996",
+			AuthCacheTTL: conf.Duration(500 * time.Millisecond),
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: &test.AuthManager{
+			AuthenticateImpl: func(req *auth.Request) error {
+				count.Add(1)
+				if req.Credentials.User != "myuser" || req.Credentials.Pass != "mypass" {
+					return auth.Error{AskCredentials: true}
+				}
+				return nil
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	list := func(user string) int {
+		res, err2 := hc.Get("http://" + user + ":mypass@localhost:9996/list?path=mypath")
+		require.NoError(t, err2)
+		defer res.Body.Close()
+		return res.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, list("myuser"))
+	require.Equal(t, http.StatusOK, list("myuser"))
+	require.Equal(t, int32(1), count.Load())
+
+	// failed authentications are not cached
+	require.Equal(t, http.StatusUnauthorized, list("other"))
+	require.Equal(t, http.StatusUnauthorized, list("other"))
+	require.Equal(t, int32(3), count.Load())
+
+	time.Sleep(600 * time.Millisecond)
+
+	require.Equal(t, http.StatusOK, list("myuser"))
+	require.Equal(t, int32(4), count.Load())
+
+	// reloading credentials flushes the cache
+	s.ReloadCredentials()
+
+	require.Equal(t, http.StatusOK, list("myuser"))
+	require.Equal(t, int32(5), count.Load())
+}
+
+func TestAuthCacheQuery(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment1(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-500000.mp4"))
+
+	for _, ca := range []string{"jwt", "http"} {
+		t.Run(ca, func(t *testing.T) {
+			var count atomic.Int32
+
+			s := &Server{
+				Playback: conf.Playback{
+					Address:      "127.0.0.1:9996",
+					AuthCacheTTL: conf.Duration(10 * time.Second),
+				},
+				ReadTimeout: conf.Duration(10 * time.Second),
+				PathConfs: map[string]*conf.Path{
+					"mypath": {
+						Name:       "mypath",
+						RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+					},
+				},
+				AuthManager: &test.AuthManager{
+					AuthenticateImpl: func(req *auth.Request) error {
+						count.Add(1)
+						if !strings.Contains(req.Query, "jwt=good") && !strings.Contains(req.Query, "key=good") {
+							return auth.Error{}
+						}
+						return nil
+					},
+				},
+				Parent: test.NilLogger,
+			}
+			if ca == "http" {
+				s.AuthMethod = conf.AuthMethodHTTP
+			}
+			err = s.Initialize()
+			require.NoError(t, err)
+			defer s.Close()
+
+			tr := &http.Transport{}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			list := func(query string) int {
+				res, err2 := hc.Get("http://localhost:9996/list?path=mypath&" + query)
+				require.NoError(t, err2)
+				defer res.Body.Close()
+				return res.StatusCode
+			}
+
+			if ca == "jwt" {
+				require.Equal(t, http.StatusOK, list("jwt=good"))
+				require.Equal(t, http.StatusOK, list("jwt=good"))
+				require.Equal(t, int32(1), count.Load())
+
+				// a different token is authenticated again
+				require.Equal(t, http.StatusUnauthorized, list("jwt=bad"))
+				require.Equal(t, http.StatusUnauthorized, list(""))
+				require.Equal(t, int32(3), count.Load())
+			} else {
+				require.Equal(t, http.StatusOK, list("key=good"))
+				require.Equal(t, http.StatusOK, list("key=good"))
+				require.Equal(t, int32(1), count.Load())
+
+				// the whole query is sent to the authorizer and is part of the key
+				require.Equal(t, http.StatusUnauthorized, list("key=bad"))
+				require.Equal(t, int32(2), count.Load())
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package playback
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// number of failed authentications that are tolerated before banning an IP.
+	banMaxFailures = 3
+
+	// duration of the first ban. It doubles at every further failure.
+	banBaseDuration = 2 * time.Second
+
+	banMaxDuration = 1 * time.Hour
+
+	// failures are forgotten after this period without failures.
+	banForgetAfter = 1 * time.Hour
+)
+
+type banEntry struct {
+	failures    int
+	lastFailure time.Time
+	until       time.Time
+}
+
+type banListEntry struct {
+	IP       string    `json:"ip"`
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+}
+
+// banList keeps track of failed authentications of each IP.
+// IPs that fail too many times are temporarily banned, with a duration that
+// increases exponentially. Differently from pausing after every failure,
+// this does not keep handlers busy.
+type banList struct {
+	mutex   sync.Mutex
+	entries map[string]*banEntry
+}
+
+func (b *banList) initialize() {
+	b.entries = make(map[string]*banEntry)
+}
+
+// bannedFor returns the remaining duration of the ban of an IP.
+func (b *banList) bannedFor(ip string) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	e, ok := b.entries[ip]
+	if !ok {
+		return 0
+	}
+
+	if d := time.Until(e.until); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (b *banList) addFailure(ip string) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+
+	for key, e := range b.entries {
+		if now.Sub(e.lastFailure) >= banForgetAfter && !now.Before(e.until) {
+			delete(b.entries, key)
+		}
+	}
+
+	e, ok := b.entries[ip]
+	if !ok {
+		e = &banEntry{}
+		b.entries[ip] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	if e.failures < banMaxFailures {
+		return 0
+	}
+
+	d := banBaseDuration
+	for i := banMaxFailures; i < e.failures && d < banMaxDuration; i++ {
+		d *= 2
+	}
+	if d > banMaxDuration {
+		d = banMaxDuration
+	}
+
+	e.until = now.Add(d)
+	return d
+}
+
+func (b *banList) resetFailures(ip string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.entries, ip)
+}
+
+func (b *banList) list() []banListEntry {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	out := []banListEntry{}
+	now := time.Now()
+
+	for ip, e := range b.entries {
+		if now.Before(e.until) {
+			out = append(out, banListEntry{
+				IP:       ip,
+				Failures: e.failures,
+				Until:    e.until,
+			})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].IP < out[j].IP
+	})
+
+	return out
+}
+
+// writeBanned replies to requests of banned IPs.
+func writeBanned(ctx *gin.Context, d time.Duration) {
+	ctx.Header("Retry-After", strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10))
+	ctx.Writer.WriteHeader(http.StatusTooManyRequests)
+}
+
+func (s *Server) onBansList(ctx *gin.Context) {
+	if !s.doAuth(ctx, conf.AuthActionAPI, "") {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, s.bans.list())
+}
+
+func (s *Server) onBansRemove(ctx *gin.Context) {
+	if !s.doAuth(ctx, conf.AuthActionAPI, "") {
+		return
+	}
+
+	ip := ctx.Query("ip")
+	if ip == "" {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("missing ip"))
+		return
+	}
+
+	s.bans.resetFailures(ip)
+
+	s.logRequest(ctx, logger.Info, "removed ban of %s", ip)
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,108 @@
+package playback
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+// CheckSegment is a segment inspected by Check.
+type CheckSegment struct {
+	Fpath    string
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// CheckGap is a discontinuity between two segments.
+// Downloads stop at the first discontinuity.
+type CheckGap struct {
+	Start         time.Time
+	Duration      time.Duration
+	TracksChanged bool
+}
+
+// CheckReport is the result of Check.
+type CheckReport struct {
+	Segments     []*CheckSegment
+	Gaps         []*CheckGap
+	MediaTime    time.Duration
+	BytesWritten uint64
+	Err          error
+}
+
+// Playable returns whether the range can be downloaded.
+func (r *CheckReport) Playable() bool {
+	return r.Err == nil && r.MediaTime > 0
+}
+
+// Check simulates a download of a range of a path, without serving HTTP.
+// It reports segments that would be used, gaps and corrupted segments.
+func Check(
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	duration time.Duration,
+	format string,
+) (*CheckReport, error) {
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		return nil, fmt.Errorf("MPEG-TS format is not supported yet")
+	}
+
+	m, err := newMuxer(format, nil, io.Discard)
+	if err != nil {
+		return nil, err
+	}
+
+	end := start.Add(duration)
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &CheckReport{}
+
+	var prevInit *fmp4.Init
+	var prevEnd time.Time
+
+	for _, seg := range segments {
+		cs := &CheckSegment{
+			Fpath: seg.Fpath,
+			Start: seg.Start,
+		}
+		r.Segments = append(r.Segments, cs)
+
+		parsed, err2 := parseSegment(seg)
+		if err2 != nil {
+			cs.Err = err2
+			prevInit = nil
+			continue
+		}
+
+		cs.Duration = parsed.duration
+
+		if prevInit != nil && !segmentFMP4CanBeConcatenated(prevInit, prevEnd, parsed.init, seg.Start) {
+			r.Gaps = append(r.Gaps, &CheckGap{
+				Start:         prevEnd,
+				Duration:      seg.Start.Sub(prevEnd),
+				TracksChanged: !initAreCompatible(prevInit, parsed.init),
+			})
+		}
+
+		prevInit = parsed.init
+		prevEnd = seg.Start.Add(parsed.duration)
+	}
+
+	r.Err = seekAndMux(context.Background(), pathConf.RecordFormat, segments, start, duration, m)
+
+	p := m.progress()
+	r.MediaTime = p.mediaTime
+	r.BytesWritten = p.bytesWritten
+
+	return r, nil
+}
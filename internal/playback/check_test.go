@@ -0,0 +1,54 @@
+package playback
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment1(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-500000.mp4"))
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+	writeSegment3(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-10-500000.mp4"))
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-11-07_11-23-20-500000.mp4"), []byte{1, 2, 3, 4}, 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:         "mypath",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	r, err := Check(pathConf, "mypath",
+		time.Date(2008, 11, 0o7, 11, 23, 1, 500000000, time.Local), 30*time.Second, "fmp4")
+	require.NoError(t, err)
+
+	require.Len(t, r.Segments, 4)
+	require.NoError(t, r.Segments[0].Err)
+	require.NoError(t, r.Segments[1].Err)
+	require.NoError(t, r.Segments[2].Err)
+	require.Error(t, r.Segments[3].Err)
+
+	require.Len(t, r.Gaps, 1)
+	require.True(t, r.Gaps[0].TracksChanged)
+
+	require.True(t, r.Playable())
+	require.NotZero(t, r.BytesWritten)
+
+	r, err = Check(pathConf, "mypath",
+		time.Date(2010, 11, 0o7, 11, 23, 1, 500000000, time.Local), 30*time.Second, "fmp4")
+	require.NoError(t, err)
+	require.False(t, r.Playable())
+}
@@ -0,0 +1,153 @@
+package playback
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// compatProfile changes the header of MP4 and fMP4 exports
+// in order to improve compatibility with players.
+// Changes never alter the size of boxes, therefore sample offsets
+// that are stored in the header remain valid.
+// There's no need to move the moov box, since both formats
+// already write it before samples (faststart).
+type compatProfile struct {
+	// optional
+	majorBrand [4]byte
+
+	// optional. They replace compatible brands only when
+	// the number of brands is the same, in order not to change the box size.
+	compatibleBrands [][4]byte
+
+	// sample entry types, optional
+	h264 [4]byte
+	h265 [4]byte
+}
+
+var compatProfiles = map[conf.PlaybackCompat]*compatProfile{
+	conf.PlaybackCompatDefault: nil,
+	conf.PlaybackCompatApple: {
+		h265: [4]byte{'h', 'v', 'c', '1'},
+	},
+	conf.PlaybackCompatLegacy: {
+		majorBrand: [4]byte{'m', 'p', '4', '2'},
+		compatibleBrands: [][4]byte{
+			{'i', 's', 'o', 'm'},
+			{'m', 'p', '4', '2'},
+			{'m', 'p', '4', '1'},
+			{'a', 'v', 'c', '1'},
+		},
+		h265: [4]byte{'h', 'v', 'c', '1'},
+	},
+	conf.PlaybackCompatInband: {
+		h264: [4]byte{'a', 'v', 'c', '3'},
+		h265: [4]byte{'h', 'e', 'v', '1'},
+	},
+}
+
+// parseCompat returns the profile with the given name, or the default one.
+func parseCompat(raw string, def conf.PlaybackCompat) (*compatProfile, error) {
+	if raw == "" {
+		return compatProfiles[def], nil
+	}
+
+	var c conf.PlaybackCompat
+	err := c.UnmarshalEnv("", raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compat: %s", raw)
+	}
+
+	return compatProfiles[c], nil
+}
+
+// iterateBoxes calls cb for each box, passing the entire box, header included.
+func iterateBoxes(buf []byte, cb func(typ string, box []byte)) {
+	for len(buf) >= 8 {
+		size := binary.BigEndian.Uint32(buf)
+		if size < 8 || uint64(size) > uint64(len(buf)) {
+			return
+		}
+
+		cb(string(buf[4:8]), buf[:size])
+		buf = buf[size:]
+	}
+}
+
+// rewriteHeader rewrites in place a buffer that starts with the ftyp and moov boxes.
+func (p *compatProfile) rewriteHeader(buf []byte) {
+	iterateBoxes(buf, func(typ string, box []byte) {
+		switch typ {
+		case "ftyp":
+			p.rewriteFtyp(box[8:])
+
+		case "moov":
+			p.rewriteContainer(box[8:])
+		}
+	})
+}
+
+func (p *compatProfile) rewriteFtyp(content []byte) {
+	if p.majorBrand != [4]byte{} && len(content) >= 4 {
+		copy(content, p.majorBrand[:])
+	}
+
+	if p.compatibleBrands != nil && len(content) == 8+len(p.compatibleBrands)*4 {
+		for i, b := range p.compatibleBrands {
+			copy(content[8+i*4:], b[:])
+		}
+	}
+}
+
+func (p *compatProfile) rewriteContainer(content []byte) {
+	iterateBoxes(content, func(typ string, box []byte) {
+		switch typ {
+		case "trak", "mdia", "minf", "stbl":
+			p.rewriteContainer(box[8:])
+
+		case "stsd":
+			// skip version, flags and entry count
+			if len(box) >= 16 {
+				p.rewriteSampleEntries(box[16:])
+			}
+		}
+	})
+}
+
+func (p *compatProfile) rewriteSampleEntries(content []byte) {
+	iterateBoxes(content, func(typ string, box []byte) {
+		var repl [4]byte
+
+		switch typ {
+		case "avc1", "avc3":
+			repl = p.h264
+
+		case "hev1", "hvc1":
+			repl = p.h265
+		}
+
+		if repl != [4]byte{} {
+			copy(box[4:8], repl[:])
+		}
+	})
+}
+
+// compatWriter applies a compatibility profile to the first write,
+// that contains the header of MP4 files.
+// The written buffer is edited in place, since it is not reused by the muxer.
+type compatWriter struct {
+	w       io.Writer
+	profile *compatProfile
+
+	done bool
+}
+
+func (w *compatWriter) Write(p []byte) (int, error) {
+	if !w.done {
+		w.done = true
+		w.profile.rewriteHeader(p)
+	}
+	return w.w.Write(p)
+}
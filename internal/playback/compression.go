@@ -0,0 +1,165 @@
+package playback
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressionEncoding returns the preferred encoding among the ones accepted by the client.
+func compressionEncoding(acceptEncoding string) string {
+	var gzipOK, deflateOK bool
+
+	for _, entry := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(entry, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		accepted := true
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[len("q="):], 64)
+				if err != nil || q <= 0 {
+					accepted = false
+				}
+			}
+		}
+
+		if !accepted {
+			continue
+		}
+
+		switch name {
+		case "gzip":
+			gzipOK = true
+
+		case "deflate":
+			deflateOK = true
+		}
+	}
+
+	switch {
+	case gzipOK:
+		return "gzip"
+
+	case deflateOK:
+		return "deflate"
+	}
+
+	return ""
+}
+
+// contentTypeIsCompressible checks whether a content type can be compressed.
+// Media payloads are never compressed, since they are already compressed.
+func contentTypeIsCompressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mt, "text/"),
+		mt == "application/json",
+		mt == "application/vnd.apple.mpegurl",
+		mt == "application/x-mpegurl":
+		return true
+	}
+
+	return false
+}
+
+type compressionWriter struct {
+	gin.ResponseWriter
+	encoding string
+
+	decided bool
+	cw      io.WriteCloser
+}
+
+func (w *compressionWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	h := w.ResponseWriter.Header()
+
+	if h.Get("Content-Encoding") != "" || !contentTypeIsCompressible(h.Get("Content-Type")) {
+		return
+	}
+
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+
+	if w.encoding == "gzip" {
+		w.cw = gzip.NewWriter(w.ResponseWriter)
+	} else {
+		w.cw, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+	}
+}
+
+func (w *compressionWriter) WriteHeaderNow() {
+	w.decide()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressionWriter) Write(p []byte) (int, error) {
+	w.decide()
+
+	if w.cw != nil {
+		return w.cw.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *compressionWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressionWriter) Flush() {
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		f.Flush() //nolint:errcheck
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *compressionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressionWriter) close() {
+	if w.cw != nil {
+		w.cw.Close()
+	}
+}
+
+func (s *Server) middlewareCompression(ctx *gin.Context) {
+	// responses depend on Accept-Encoding even when they are not compressed,
+	// caches must not send them to clients that accept other codings.
+	ctx.Writer.Header().Add("Vary", "Accept-Encoding")
+
+	if ctx.Request.Method == http.MethodHead {
+		return
+	}
+
+	encoding := compressionEncoding(ctx.Request.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return
+	}
+
+	w := &compressionWriter{
+		ResponseWriter: ctx.Writer,
+		encoding:       encoding,
+	}
+	ctx.Writer = w
+	defer w.close()
+
+	ctx.Next()
+}
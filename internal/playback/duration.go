@@ -0,0 +1,206 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const durationMax = time.Duration(math.MaxInt64)
+
+// durationFormats describes the formats accepted by parseDuration.
+const durationFormats = "seconds (90, 1.5), numbers with units (90m, 2h30m, 1d) or ISO 8601 durations (PT1H30M)"
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+}
+
+var durationISODateUnits = map[string]time.Duration{
+	"W": 7 * 24 * time.Hour,
+	"D": 24 * time.Hour,
+}
+
+var durationISOTimeUnits = map[string]time.Duration{
+	"H": time.Hour,
+	"M": time.Minute,
+	"S": time.Second,
+}
+
+var errDurationYearsMonths = errors.New("ISO 8601 durations with years or months are not supported, " +
+	"since their length is not fixed")
+
+func isDurationDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '.'
+}
+
+func addDurationSaturated(a time.Duration, b time.Duration) time.Duration {
+	if a > durationMax-b {
+		return durationMax
+	}
+	return a + b
+}
+
+// secondsToDuration converts seconds into a duration, rounding to the nearest nanosecond
+// and saturating values that can't be represented.
+func secondsToDuration(secs float64) time.Duration {
+	ns := math.Round(secs * float64(time.Second))
+
+	switch {
+	case ns >= math.MaxInt64:
+		return durationMax
+
+	case ns <= math.MinInt64:
+		return -durationMax
+	}
+
+	return time.Duration(ns)
+}
+
+// parseDurationValue parses a positive number followed by an unit.
+func parseDurationValue(num string, unit time.Duration) (time.Duration, bool) {
+	whole, frac, _ := strings.Cut(num, ".")
+	if (whole == "" && frac == "") || strings.Contains(frac, ".") {
+		return 0, false
+	}
+
+	var v time.Duration
+
+	if whole != "" {
+		n, err := strconv.ParseUint(whole, 10, 64)
+		switch {
+		case errors.Is(err, strconv.ErrRange) || (err == nil && n > uint64(durationMax/unit)):
+			return durationMax, true
+
+		case err != nil:
+			return 0, false
+		}
+		v = time.Duration(n) * unit
+	}
+
+	if frac != "" {
+		f, err := strconv.ParseFloat("0."+frac, 64)
+		if err != nil {
+			return 0, false
+		}
+		v = addDurationSaturated(v, time.Duration(math.Round(f*float64(unit))))
+	}
+
+	return v, true
+}
+
+// parseDurationUnits parses a sequence of numbers followed by units, like 2h30m.
+func parseDurationUnits(s string, units map[string]time.Duration) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	var total time.Duration
+
+	for s != "" {
+		i := 0
+		for i < len(s) && isDurationDigit(s[i]) {
+			i++
+		}
+		num := s[:i]
+		s = s[i:]
+
+		i = 0
+		for i < len(s) && !isDurationDigit(s[i]) {
+			i++
+		}
+		unit, ok := units[s[:i]]
+		if !ok {
+			return 0, false
+		}
+		s = s[i:]
+
+		v, ok := parseDurationValue(num, unit)
+		if !ok {
+			return 0, false
+		}
+		total = addDurationSaturated(total, v)
+	}
+
+	return total, true
+}
+
+// parseDurationISO parses an ISO 8601 duration, like PT1H30M.
+func parseDurationISO(s string) (time.Duration, error) {
+	invalid := fmt.Errorf("malformed ISO 8601 duration '%s'", s)
+
+	date, tim, hasTime := strings.Cut(strings.ReplaceAll(s[1:], ",", "."), "T")
+	if (date == "" && !hasTime) || (hasTime && tim == "") {
+		return 0, invalid
+	}
+
+	if strings.ContainsAny(date, "YM") {
+		return 0, errDurationYearsMonths
+	}
+
+	var total time.Duration
+
+	if date != "" {
+		d, ok := parseDurationUnits(date, durationISODateUnits)
+		if !ok {
+			return 0, invalid
+		}
+		total = d
+	}
+
+	if hasTime {
+		d, ok := parseDurationUnits(tim, durationISOTimeUnits)
+		if !ok {
+			return 0, invalid
+		}
+		total = addDurationSaturated(total, d)
+	}
+
+	return total, nil
+}
+
+// ParseDuration parses a duration with the same syntax of the duration parameter of the playback server.
+func ParseDuration(raw string) (time.Duration, error) {
+	return parseDuration(raw)
+}
+
+// parseDuration parses a duration expressed in seconds, with units or in ISO 8601 format.
+// Values too big to be represented are saturated.
+func parseDuration(raw string) (time.Duration, error) {
+	// seconds
+	if secs, err := strconv.ParseFloat(raw, 64); err == nil && !math.IsNaN(secs) {
+		return secondsToDuration(secs), nil
+	}
+
+	// ISO 8601
+	if upper := strings.ToUpper(raw); strings.HasPrefix(upper, "P") {
+		return parseDurationISO(upper)
+	}
+
+	// numbers with units, that include the golang format
+	s := strings.TrimPrefix(raw, "+")
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	}
+
+	d, ok := parseDurationUnits(s, durationUnits)
+	if !ok {
+		return 0, fmt.Errorf("unrecognized format '%s', use %s", raw, durationFormats)
+	}
+
+	if neg {
+		return -d, nil
+	}
+	return d, nil
+}
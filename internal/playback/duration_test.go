@@ -0,0 +1,68 @@
+package playback
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	for _, ca := range []struct {
+		raw string
+		d   time.Duration
+	}{
+		{"90", 90 * time.Second},
+		{"1.5", 1500 * time.Millisecond},
+		{"1.001", 1001 * time.Millisecond},
+		{"-2", -2 * time.Second},
+		{"90m", 90 * time.Minute},
+		{"2h30m", 2*time.Hour + 30*time.Minute},
+		{"1d", 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"1.5h", 90 * time.Minute},
+		{"300ms", 300 * time.Millisecond},
+		{"1h0m0.5s", time.Hour + 500*time.Millisecond},
+		{"-1h", -time.Hour},
+		{"PT1H30M", 90 * time.Minute},
+		{"PT0.5S", 500 * time.Millisecond},
+		{"PT0,5S", 500 * time.Millisecond},
+		{"P1D", 24 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"P1DT2H", 26 * time.Hour},
+		{"pt10m", 10 * time.Minute},
+		{"1e30", durationMax},
+		{"1000000000d", durationMax},
+		{"99999999999999999999999h", durationMax},
+	} {
+		t.Run(ca.raw, func(t *testing.T) {
+			d, err := parseDuration(ca.raw)
+			require.NoError(t, err)
+			require.Equal(t, ca.d, d)
+		})
+	}
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	for _, ca := range []struct {
+		raw string
+		err string
+	}{
+		{"", "unrecognized format '', use " + durationFormats},
+		{"abc", "unrecognized format 'abc', use " + durationFormats},
+		{"NaN", "unrecognized format 'NaN', use " + durationFormats},
+		{"1h30", "unrecognized format '1h30', use " + durationFormats},
+		{"1.2.3s", "unrecognized format '1.2.3s', use " + durationFormats},
+		{"1y", "unrecognized format '1y', use " + durationFormats},
+		{"P", "malformed ISO 8601 duration 'P'"},
+		{"PT", "malformed ISO 8601 duration 'PT'"},
+		{"PT1X", "malformed ISO 8601 duration 'PT1X'"},
+		{"P1Y", "ISO 8601 durations with years or months are not supported, since their length is not fixed"},
+		{"P1M", "ISO 8601 durations with years or months are not supported, since their length is not fixed"},
+	} {
+		t.Run(ca.raw, func(t *testing.T) {
+			_, err := parseDuration(ca.raw)
+			require.EqualError(t, err, ca.err)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package playback
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// firstHeaderValue returns the first entry of a header that can contain
+// a comma-separated list, added by each proxy in the chain.
+func firstHeaderValue(ctx *gin.Context, name string) string {
+	return strings.TrimSpace(strings.Split(ctx.GetHeader(name), ",")[0])
+}
+
+// fromTrustedProxy checks whether a request has been sent directly by a trusted proxy.
+func (s *Server) fromTrustedProxy(ctx *gin.Context) bool {
+	host, _, err := net.SplitHostPort(ctx.Request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range s.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// externalURL returns the URL that clients can use to reach a route of the server.
+// When ExternalURL is not set, the URL is deduced from the request
+// and from X-Forwarded-* headers sent by trusted proxies.
+func (s *Server) externalURL(ctx *gin.Context, route string, query url.Values) string {
+	var u url.URL
+
+	if s.ExternalURL != "" {
+		base, _ := url.Parse(s.ExternalURL)
+		u.Scheme = base.Scheme
+		u.Host = base.Host
+		u.Path = strings.TrimSuffix(base.Path, "/")
+	} else {
+		if s.Encryption {
+			u.Scheme = "https"
+		} else {
+			u.Scheme = "http"
+		}
+		u.Host = ctx.Request.Host
+
+		if s.fromTrustedProxy(ctx) {
+			if proto := firstHeaderValue(ctx, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+				u.Scheme = proto
+			}
+
+			if host := firstHeaderValue(ctx, "X-Forwarded-Host"); host != "" {
+				u.Host = host
+			}
+
+			if prefix := firstHeaderValue(ctx, "X-Forwarded-Prefix"); strings.HasPrefix(prefix, "/") {
+				u.Path = strings.TrimSuffix(prefix, "/")
+			}
+		}
+	}
+
+	u.Path += routePrefix(ctx) + route
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
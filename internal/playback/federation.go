@@ -0,0 +1,206 @@
+package playback
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// header added to requests sent to peers.
+// Peers do not forward these requests, in order to prevent loops.
+const federatedHeader = "X-Playback-Federated"
+
+type peerListEntry struct {
+	Start         time.Time        `json:"start"`
+	Duration      float64          `json:"duration"`
+	Tracks        []listEntryTrack `json:"tracks"`
+	TracksChanged bool             `json:"tracksChanged"`
+}
+
+func (s *Server) federationEnabled(ctx *gin.Context) bool {
+	return len(s.Peers) != 0 && ctx.Request.Header.Get(federatedHeader) == ""
+}
+
+func (s *Server) newPeerRequest(ctx *gin.Context, peer string, route string, query string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx.Request.Context(), http.MethodGet,
+		strings.TrimSuffix(peer, "/")+route+"?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// credentials are checked by peers too
+	for _, h := range []string{"Authorization", "X-API-Key"} {
+		if v := ctx.Request.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+
+	// the output format may have been selected with the Accept header
+	if v := ctx.Request.Header.Get("Accept"); v != "" {
+		req.Header.Set("Accept", v)
+	}
+
+	req.Header.Set("X-Request-ID", ctx.GetString(requestIDKey))
+	req.Header.Set(federatedHeader, "1")
+
+	return req, nil
+}
+
+func (s *Server) listPeer(ctx *gin.Context, peer string) ([]listEntry, error) {
+	req, err := s.newPeerRequest(ctx, peer, "/list", ctx.Request.URL.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.peerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	var in []peerListEntry
+	err = json.NewDecoder(res.Body).Decode(&in)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]listEntry, len(in))
+	for i, e := range in {
+		out[i] = listEntry{
+			Start:         e.Start,
+			Duration:      listEntryDuration(time.Duration(e.Duration * float64(time.Second))),
+			Tracks:        e.Tracks,
+			TracksChanged: e.TracksChanged,
+		}
+	}
+
+	return out, nil
+}
+
+// listPeers returns timespans of recordings stored by peers.
+// Peers that are not reachable are skipped.
+func (s *Server) listPeers(ctx *gin.Context) []listEntry {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var out []listEntry
+
+	for _, peer := range s.Peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+
+			entries, err := s.listPeer(ctx, peer)
+			if err != nil {
+				s.logRequest(ctx, logger.Warn, "unable to list recordings of peer %s: %v", peer, err)
+				return
+			}
+
+			mutex.Lock()
+			out = append(out, entries...)
+			mutex.Unlock()
+		}(peer)
+	}
+
+	wg.Wait()
+
+	return out
+}
+
+// proxyGetToPeers forwards a /get request to the first peer that owns the requested recording.
+// The range is the one that has been approved by hooks, and the response is written into
+// the export returned by hooks, in the same way of local recordings.
+// It returns false if no peer owns the recording.
+func (s *Server) proxyGetToPeers(
+	ctx *gin.Context,
+	export *hookExport,
+	start time.Time,
+	duration time.Duration,
+) bool {
+	query := ctx.Request.URL.Query()
+	query.Set("start", start.Format(time.RFC3339Nano))
+	query.Set("duration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+
+	for _, peer := range s.Peers {
+		req, err := s.newPeerRequest(ctx, peer, "/get", query.Encode())
+		if err != nil {
+			s.logRequest(ctx, logger.Warn, "unable to query peer %s: %v", peer, err)
+			continue
+		}
+
+		res, err := s.peerClient.Do(req)
+		if err != nil {
+			s.logRequest(ctx, logger.Warn, "unable to query peer %s: %v", peer, err)
+			continue
+		}
+
+		if res.StatusCode == http.StatusNotFound {
+			res.Body.Close()
+			continue
+		}
+
+		func() {
+			defer res.Body.Close()
+
+			for _, key := range []string{
+				"Accept-Ranges",
+				"Content-Type",
+				"X-Playback-Actual-Start",
+			} {
+				if v := res.Header.Get(key); v != "" {
+					ctx.Header(key, v)
+				}
+			}
+
+			// the client moves keys of the Trailer header into res.Trailer
+			trailers := make([]string, 0, len(res.Trailer))
+			for key := range res.Trailer {
+				trailers = append(trailers, key)
+			}
+			if len(trailers) != 0 {
+				sort.Strings(trailers)
+				ctx.Header("Trailer", strings.Join(trailers, ", "))
+			}
+
+			ctx.Status(res.StatusCode)
+
+			// errors are not post-processed by hooks
+			if res.StatusCode != http.StatusOK {
+				_, err = io.Copy(s.newStallWriter(ctx, ctx.Writer), res.Body)
+			} else {
+				_, err = io.Copy(export, res.Body)
+				if err == nil {
+					err = export.close()
+				}
+			}
+			if err != nil {
+				s.logRequest(ctx, logger.Error, "unable to forward response of peer %s: %v", peer, err)
+				return
+			}
+
+			for key := range res.Trailer {
+				ctx.Writer.Header().Set(key, res.Trailer.Get(key))
+			}
+		}()
+
+		return true
+	}
+
+	return false
+}
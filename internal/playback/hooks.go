@@ -0,0 +1,201 @@
+package playback
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
+	"github.com/gin-gonic/gin"
+)
+
+// Hook is an extension point that allows to customize playback requests
+// without changing handlers.
+// Hooks are registered at build time with RegisterHook, usually by the init() function
+// of a package that is imported by the main package.
+// Hooks are called by every endpoint that serves media (/get, /batch, /segment, /archive,
+// /init and /timestamps). Requests with multiple items or ranges call OnRequest once per item or range.
+// /get requests that are forwarded to peers are post-processed by hooks of both servers.
+// /segment and /init serve whole segments or sections, therefore Duration is zero
+// and requests are rejected when hooks change the range.
+type Hook interface {
+	// OnRequest is called after authentication.
+	// It can reject the request by returning an error,
+	// or change the requested range by editing Start and Duration.
+	OnRequest(req *HookRequest) error
+
+	// OnExport is called before an export is written.
+	// It returns a writer that post-processes the export, or w itself.
+	// If the returned writer implements io.Closer, it is closed once the export is complete.
+	OnExport(req *HookRequest, w io.Writer) io.Writer
+}
+
+// HookRequest is a playback request that is passed to hooks.
+// Format is the output format (fmp4, mp4, ts, tar or vtt).
+type HookRequest struct {
+	Path     string
+	Query    string
+	User     string
+	IP       net.IP
+	Format   string
+	Start    time.Time
+	Duration time.Duration
+}
+
+type hookEntry struct {
+	id   int
+	hook Hook
+}
+
+var (
+	hooksMutex  sync.Mutex
+	hookEntries []hookEntry
+	hooksNextID int
+)
+
+// RegisterHook registers a hook. Hooks are called in order of registration.
+// It returns a function that removes the hook.
+func RegisterHook(h Hook) func() {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+
+	id := hooksNextID
+	hooksNextID++
+	hookEntries = append(hookEntries, hookEntry{id: id, hook: h})
+
+	return func() {
+		hooksMutex.Lock()
+		defer hooksMutex.Unlock()
+
+		for i, e := range hookEntries {
+			if e.id == id {
+				hookEntries = append(hookEntries[:i:i], hookEntries[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func registeredHooks() []Hook {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+
+	out := make([]Hook, len(hookEntries))
+	for i, e := range hookEntries {
+		out[i] = e.hook
+	}
+	return out
+}
+
+// hookExport chains writers returned by hooks.
+type hookExport struct {
+	base    io.Writer
+	w       io.Writer
+	closers []io.Closer
+}
+
+// passthrough checks whether no hook post-processes the export.
+func (e *hookExport) passthrough() bool {
+	return len(e.closers) == 0 && e.w == e.base
+}
+
+func (e *hookExport) Write(p []byte) (int, error) {
+	return e.w.Write(p)
+}
+
+// close closes writers returned by hooks, starting from the outermost one,
+// in order to allow each writer to flush into the following one.
+func (e *hookExport) close() error {
+	for i := len(e.closers) - 1; i >= 0; i-- {
+		err := e.closers[i].Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHooks calls OnRequest of registered hooks.
+// Requests rejected by hooks are replied with status code 403.
+func (s *Server) runHooks(ctx *gin.Context, hs []Hook, req *HookRequest) bool {
+	for _, h := range hs {
+		err := h.OnRequest(req)
+		if err != nil {
+			s.writeError(ctx, http.StatusForbidden, err)
+			return false
+		}
+	}
+	return true
+}
+
+// startHooks creates a hook request and calls OnRequest of registered hooks.
+func (s *Server) startHooks(
+	ctx *gin.Context,
+	pathName string,
+	format string,
+	start time.Time,
+	duration time.Duration,
+) ([]Hook, *HookRequest, bool) {
+	hs := registeredHooks()
+	hreq := newHookRequest(ctx, pathName, format, start, duration)
+	if !s.runHooks(ctx, hs, hreq) {
+		return nil, nil, false
+	}
+	return hs, hreq, true
+}
+
+// startHooksFixed is like startHooks, but is used by endpoints that can't change the served range.
+// Requests whose range is changed by hooks are rejected with status code 403.
+func (s *Server) startHooksFixed(
+	ctx *gin.Context,
+	pathName string,
+	format string,
+	start time.Time,
+) ([]Hook, *HookRequest, bool) {
+	hs, hreq, ok := s.startHooks(ctx, pathName, format, start, 0)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !hreq.Start.Equal(start) || hreq.Duration != 0 {
+		s.writeError(ctx, http.StatusForbidden, fmt.Errorf("the requested range is not allowed"))
+		return nil, nil, false
+	}
+
+	return hs, hreq, true
+}
+
+func newHookRequest(
+	ctx *gin.Context,
+	pathName string,
+	format string,
+	start time.Time,
+	duration time.Duration,
+) *HookRequest {
+	return &HookRequest{
+		Path:     pathName,
+		Query:    ctx.Request.URL.RawQuery,
+		User:     httpp.Credentials(ctx.Request).User,
+		IP:       net.ParseIP(httpp.ClientIP(ctx)),
+		Format:   format,
+		Start:    start,
+		Duration: duration,
+	}
+}
+
+func newHookExport(hs []Hook, req *HookRequest, w io.Writer) *hookExport {
+	e := &hookExport{base: w, w: w}
+
+	for _, h := range hs {
+		prev := e.w
+		e.w = h.OnExport(req, prev)
+		if c, ok := e.w.(io.Closer); ok && e.w != prev {
+			e.closers = append(e.closers, c)
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,191 @@
+package playback
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+var errIncompatibleTracks = errors.New("some tracks can't be represented in the requested format")
+
+// codecIsCompatible checks whether a codec can be represented in an output format.
+func codecIsCompatible(format string, compat *compatProfile, codec mp4.Codec) bool {
+	if format == "ts" {
+		return tsCodec(codec) != nil
+	}
+
+	// players that require the legacy profile don't support recent codecs
+	if compat == compatProfiles[conf.PlaybackCompatLegacy] {
+		switch codec.(type) {
+		case *mp4.CodecAV1, *mp4.CodecVP9, *mp4.CodecOpus:
+			return false
+		}
+	}
+
+	return true
+}
+
+// findIncompatibleTracks returns the tracks of segments that can't be represented in an output format.
+// Segments that can't be read are skipped, since errors are reported when muxing.
+func findIncompatibleTracks(
+	segments []*recordstore.Segment,
+	format string,
+	compat *compatProfile,
+) []*infoTrack {
+	var out []*infoTrack
+	found := make(map[int]struct{})
+
+	for _, seg := range segments {
+		// MPEG-TS recordings are copied without changes
+		if seg.Format != conf.RecordFormatFMP4 {
+			continue
+		}
+
+		init, err := readSegmentInit(seg)
+		if err != nil {
+			continue
+		}
+
+		for _, track := range init.Tracks {
+			if _, ok := found[track.ID]; ok {
+				continue
+			}
+
+			if !codecIsCompatible(format, compat, track.Codec) {
+				found[track.ID] = struct{}{}
+				out = append(out, newInfoTrack(track.ID, track.Codec))
+			}
+		}
+	}
+
+	return out
+}
+
+func readSegmentInit(seg *recordstore.Segment) (*fmp4.Init, error) {
+	f, err := seg.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	init, _, err := segmentFMP4ReadHeader(f)
+	return init, err
+}
+
+// incompatibleSuggestions returns the query parameters that allow to export all tracks.
+func incompatibleSuggestions(format string, compat *compatProfile) []string {
+	if format == "ts" {
+		return []string{"format=fmp4", "format=mp4"}
+	}
+
+	if compat == compatProfiles[conf.PlaybackCompatLegacy] {
+		return []string{"compat=default"}
+	}
+
+	return []string{}
+}
+
+type incompatibleTrack struct {
+	ID    int    `json:"id"`
+	Codec string `json:"codec"`
+}
+
+func newIncompatibleTracks(tracks []*infoTrack) []incompatibleTrack {
+	out := make([]incompatibleTrack, len(tracks))
+	for i, t := range tracks {
+		out[i] = incompatibleTrack{ID: t.ID, Codec: t.Codec}
+	}
+	return out
+}
+
+type incompatibleResponse struct {
+	Error       string              `json:"error"`
+	Tracks      []incompatibleTrack `json:"tracks"`
+	Suggestions []string            `json:"suggestions"`
+}
+
+// writeIncompatible replies with the tracks that can't be exported
+// and with the parameters that can be used instead.
+func (s *Server) writeIncompatible(
+	ctx *gin.Context,
+	format string,
+	compat *compatProfile,
+	tracks []*infoTrack,
+) {
+	// show error in logs and in the audit log
+	s.logRequest(ctx, logger.Error, errIncompatibleTracks.Error())
+	ctx.Error(errIncompatibleTracks) //nolint:errcheck
+
+	ctx.JSON(http.StatusBadRequest, incompatibleResponse{
+		Error:       errIncompatibleTracks.Error(),
+		Tracks:      newIncompatibleTracks(tracks),
+		Suggestions: incompatibleSuggestions(format, compat),
+	})
+}
+
+// muxerDropIncompatible removes tracks that can't be represented in the output format.
+type muxerDropIncompatible struct {
+	m      muxer
+	format string
+	compat *compatProfile
+
+	dropped map[int]struct{}
+	skip    bool
+}
+
+func (w *muxerDropIncompatible) writeInit(init *fmp4.Init) {
+	w.dropped = make(map[int]struct{})
+
+	filtered := *init
+	filtered.Tracks = nil
+
+	for _, track := range init.Tracks {
+		if codecIsCompatible(w.format, w.compat, track.Codec) {
+			filtered.Tracks = append(filtered.Tracks, track)
+		} else {
+			w.dropped[track.ID] = struct{}{}
+		}
+	}
+
+	w.m.writeInit(&filtered)
+}
+
+func (w *muxerDropIncompatible) setTrack(trackID int) {
+	_, w.skip = w.dropped[trackID]
+	if !w.skip {
+		w.m.setTrack(trackID)
+	}
+}
+
+func (w *muxerDropIncompatible) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	if w.skip {
+		return nil
+	}
+	return w.m.writeSample(dts, ptsOffset, isNonSyncSample, payloadSize, getPayload)
+}
+
+func (w *muxerDropIncompatible) writeFinalDTS(dts int64) {
+	if !w.skip {
+		w.m.writeFinalDTS(dts)
+	}
+}
+
+func (w *muxerDropIncompatible) flush() error {
+	return w.m.flush()
+}
+
+func (w *muxerDropIncompatible) progress() muxerProgress {
+	return w.m.progress()
+}
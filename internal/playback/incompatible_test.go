@@ -0,0 +1,199 @@
+package playback
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/pmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSegmentVP9(t *testing.T, fpath string) {
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{
+			{
+				ID:        1,
+				TimeScale: 90000,
+				Codec: &mp4.CodecH264{
+					SPS: test.FormatH264.SPS,
+					PPS: test.FormatH264.PPS,
+				},
+			},
+			{
+				ID:        2,
+				TimeScale: 90000,
+				Codec: &mp4.CodecVP9{
+					Width:             1280,
+					Height:            720,
+					Profile:           1,
+					BitDepth:          8,
+					ChromaSubsampling: 1,
+				},
+			},
+		},
+	}
+
+	var buf1 seekablebuffer.Buffer
+	err := init.Marshal(&buf1)
+	require.NoError(t, err)
+
+	var buf2 seekablebuffer.Buffer
+	parts := fmp4.Parts{
+		{
+			Tracks: []*fmp4.PartTrack{
+				{
+					ID: 1,
+					Samples: []*fmp4.Sample{
+						{
+							Duration: 2 * 90000,
+							Payload:  []byte{0, 0, 0, 1, 0x05}, // IDR
+						},
+						{
+							Duration:        2 * 90000,
+							IsNonSyncSample: true,
+							Payload:         []byte{0, 0, 0, 1, 0x01},
+						},
+					},
+				},
+				{
+					ID: 2,
+					Samples: []*fmp4.Sample{
+						{
+							Duration: 4 * 90000,
+							Payload:  []byte{1, 2},
+						},
+					},
+				},
+			},
+		},
+	}
+	err = parts.Marshal(&buf2)
+	require.NoError(t, err)
+
+	err = os.WriteFile(fpath, append(buf1.Bytes(), buf2.Bytes()...), 0o644)
+	require.NoError(t, err)
+}
+
+func TestOnGetIncompatible(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegmentVP9(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	for _, ca := range []struct {
+		name        string
+		format      string
+		compat      string
+		suggestions []string
+	}{
+		{
+			"ts",
+			"ts",
+			"",
+			[]string{"format=fmp4", "format=mp4"},
+		},
+		{
+			"legacy",
+			"mp4",
+			"legacy",
+			[]string{"compat=default"},
+		},
+	} {
+		for _, drop := range []bool{false, true} {
+			name := ca.name + " reject"
+			if drop {
+				name = ca.name + " drop"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				s := &Server{
+					Playback: conf.Playback{
+						Address:    "127.0.0.1:9996",
+						CompatDrop: drop,
+					},
+					ReadTimeout: conf.Duration(10 * time.Second),
+					PathConfs: map[string]*conf.Path{
+						"mypath": {
+							Name:       "mypath",
+							RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+						},
+					},
+					AuthManager: test.NilAuthManager,
+					Parent:      test.NilLogger,
+				}
+				err := s.Initialize()
+				require.NoError(t, err)
+				defer s.Close()
+
+				tr := &http.Transport{}
+				defer tr.CloseIdleConnections()
+				hc := &http.Client{Transport: tr}
+
+				v := url.Values{}
+				v.Set("path", "mypath")
+				v.Set("start", time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano))
+				v.Set("duration", "4")
+				v.Set("format", ca.format)
+				if ca.compat != "" {
+					v.Set("compat", ca.compat)
+				}
+
+				res, err := hc.Get("http://localhost:9996/get?" + v.Encode())
+				require.NoError(t, err)
+				defer res.Body.Close()
+
+				if !drop {
+					require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+					var out incompatibleResponse
+					err = json.NewDecoder(res.Body).Decode(&out)
+					require.NoError(t, err)
+
+					require.Equal(t, incompatibleResponse{
+						Error:       "some tracks can't be represented in the requested format",
+						Tracks:      []incompatibleTrack{{ID: 2, Codec: "VP9"}},
+						Suggestions: ca.suggestions,
+					}, out)
+					return
+				}
+
+				require.Equal(t, http.StatusOK, res.StatusCode)
+
+				buf, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+
+				if ca.format == "ts" {
+					r := &mpegts.Reader{R: bytes.NewReader(buf)}
+					err = r.Initialize()
+					require.NoError(t, err)
+					require.Len(t, r.Tracks(), 1)
+					require.IsType(t, &mpegts.CodecH264{}, r.Tracks()[0].Codec)
+					return
+				}
+
+				var p pmp4.Presentation
+				err = p.Unmarshal(bytes.NewReader(buf))
+				require.NoError(t, err)
+				require.Len(t, p.Tracks, 1)
+				require.IsType(t, &mp4.CodecH264{}, p.Tracks[0].Codec)
+			})
+		}
+	}
+}
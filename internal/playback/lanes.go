@@ -0,0 +1,68 @@
+package playback
+
+import (
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// lane limits the number of concurrent downloads of a class.
+type lane struct {
+	name  string
+	slots chan struct{}
+}
+
+func newLane(name string, maxDownloads int) *lane {
+	l := &lane{name: name}
+	if maxDownloads != 0 {
+		l.slots = make(chan struct{}, maxDownloads)
+	}
+	return l
+}
+
+func (l *lane) tryAcquire() bool {
+	if l.slots == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *lane) release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// admitLane assigns a download to the interactive lane or to the bulk lane,
+// depending on its duration, and waits until the lane has a free slot,
+// in order to prevent bulk exports from slowing down scrubbing.
+// It returns a function that frees the slot.
+func (s *Server) admitLane(ctx *gin.Context, duration time.Duration) (func(), bool) {
+	l := s.interactiveLane
+	if duration > time.Duration(s.BulkDuration) {
+		l = s.bulkLane
+	}
+
+	ctx.Header("X-Playback-Lane", l.name)
+
+	if l.tryAcquire() {
+		return l.release, true
+	}
+
+	s.logRequest(ctx, logger.Debug, "%s lane is full, waiting", l.name)
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, true
+
+	case <-ctx.Request.Context().Done():
+		return nil, false
+	}
+}
@@ -0,0 +1,191 @@
+package playback
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	loadSampleInterval = 1 * time.Second
+
+	// interval between checks of queued requests.
+	loadQueuePollInterval = 100 * time.Millisecond
+
+	// queued requests are rejected after this period.
+	loadQueueMaxWait = 1 * time.Minute
+
+	// speed of downloads when the throttle policy is in use, in bytes per second.
+	loadThrottleRate = 1024 * 1024
+)
+
+var errLiveLoadTooHigh = errors.New("live streaming load is too high")
+
+// loadShedder measures the bandwidth used by live streaming,
+// in order to limit downloads when it exceeds a threshold.
+type loadShedder struct {
+	pathManager serverPathManager
+	threshold   uint64
+	parent      logger.Writer
+
+	prevBytes  uint64
+	overloaded atomic.Bool
+
+	terminate chan struct{}
+	done      chan struct{}
+}
+
+func (l *loadShedder) initialize() {
+	l.terminate = make(chan struct{})
+	l.done = make(chan struct{})
+
+	if l.threshold == 0 || l.pathManager == nil {
+		close(l.done)
+		return
+	}
+
+	l.prevBytes, _ = l.liveBytes()
+
+	go l.run()
+}
+
+func (l *loadShedder) close() {
+	close(l.terminate)
+	<-l.done
+}
+
+// liveBytes returns the bytes received and sent by all paths.
+func (l *loadShedder) liveBytes() (uint64, error) {
+	data, err := l.pathManager.APIPathsList()
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, item := range data.Items {
+		n += item.BytesReceived + item.BytesSent
+	}
+	return n, nil
+}
+
+// update computes the bandwidth from a new sample.
+func (l *loadShedder) update(curBytes uint64, elapsed time.Duration) {
+	// counters decrease when paths are removed
+	if curBytes < l.prevBytes {
+		l.prevBytes = curBytes
+		return
+	}
+
+	rate := (curBytes - l.prevBytes) * uint64(time.Second) / uint64(elapsed)
+	l.prevBytes = curBytes
+
+	overloaded := rate > l.threshold
+
+	if l.overloaded.Swap(overloaded) != overloaded {
+		if overloaded {
+			l.parent.Log(logger.Warn, "live streaming bandwidth is %d bytes/s, limiting downloads", rate)
+		} else {
+			l.parent.Log(logger.Info, "live streaming bandwidth is %d bytes/s, downloads are no longer limited", rate)
+		}
+	}
+}
+
+func (l *loadShedder) run() {
+	defer close(l.done)
+
+	t := time.NewTicker(loadSampleInterval)
+	defer t.Stop()
+
+	prevTime := time.Now()
+
+	for {
+		select {
+		case <-t.C:
+			curBytes, err := l.liveBytes()
+			if err != nil {
+				continue
+			}
+
+			now := time.Now()
+			l.update(curBytes, now.Sub(prevTime))
+			prevTime = now
+
+		case <-l.terminate:
+			return
+		}
+	}
+}
+
+// admitLoad applies the load policy before a download starts.
+func (s *Server) admitLoad(ctx *gin.Context) bool {
+	if !s.load.overloaded.Load() {
+		return true
+	}
+
+	switch s.LoadPolicy {
+	case conf.PlaybackLoadPolicyReject:
+		s.writeError(ctx, http.StatusServiceUnavailable, errLiveLoadTooHigh)
+		return false
+
+	case conf.PlaybackLoadPolicyQueue:
+		s.logRequest(ctx, logger.Info, "%v, waiting", errLiveLoadTooHigh)
+
+		t := time.NewTicker(loadQueuePollInterval)
+		defer t.Stop()
+
+		timeout := time.NewTimer(loadQueueMaxWait)
+		defer timeout.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				if !s.load.overloaded.Load() {
+					return true
+				}
+
+			case <-timeout.C:
+				s.writeError(ctx, http.StatusServiceUnavailable, errLiveLoadTooHigh)
+				return false
+
+			case <-ctx.Request.Context().Done():
+				return false
+			}
+		}
+	}
+
+	// throttling is performed by loadWriter
+	return true
+}
+
+// loadWriter limits the speed of downloads while live streaming is overloaded.
+type loadWriter struct {
+	l *loadShedder
+	w io.Writer
+}
+
+func (s *Server) newLoadWriter(w io.Writer) io.Writer {
+	if s.LoadPolicy != conf.PlaybackLoadPolicyThrottle || s.LoadThreshold == 0 {
+		return w
+	}
+
+	return &loadWriter{
+		l: s.load,
+		w: w,
+	}
+}
+
+func (w *loadWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+
+	if w.l.overloaded.Load() {
+		time.Sleep(time.Duration(n) * time.Second / loadThrottleRate)
+	}
+
+	return n, err
+}
@@ -0,0 +1,66 @@
+package playback
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
+	"github.com/gin-gonic/gin"
+)
+
+// ipAnonymizer converts IPs of clients into a form that can be written into logs.
+// It is used by logs only: bans, rate limits, hooks and the audit log use full IPs.
+type ipAnonymizer struct {
+	mode conf.PlaybackLogIPs
+
+	key []byte
+}
+
+func (a *ipAnonymizer) initialize() error {
+	if a.mode != conf.PlaybackLogIPsHash {
+		return nil
+	}
+
+	// the key is regenerated at every start, in order to prevent the
+	// reversal of hashes by enumerating all IPs.
+	a.key = make([]byte, 32)
+	_, err := rand.Read(a.key)
+	return err
+}
+
+func (a *ipAnonymizer) ip(raw string) string {
+	switch a.mode {
+	case conf.PlaybackLogIPsTruncate:
+		ip := net.ParseIP(raw)
+		if ip == nil {
+			return raw
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.Mask(net.CIDRMask(24, 32)).String()
+		}
+		return ip.Mask(net.CIDRMask(48, 128)).String()
+
+	case conf.PlaybackLogIPsHash:
+		h := hmac.New(sha256.New, a.key)
+		h.Write([]byte(raw))
+		return hex.EncodeToString(h.Sum(nil)[:8])
+
+	default:
+		return raw
+	}
+}
+
+// logIP returns the IP of the client in the form that must be written into logs.
+func (s *Server) logIP(ctx *gin.Context) string {
+	return s.logIPs.ip(httpp.ClientIP(ctx))
+}
+
+// logAddr returns the address of the client in the form that must be written into logs.
+func (s *Server) logAddr(ctx *gin.Context) string {
+	_, port, _ := net.SplitHostPort(ctx.Request.RemoteAddr)
+	return net.JoinHostPort(s.logIP(ctx), port)
+}
@@ -0,0 +1,45 @@
+package playback
+
+import (
+	"testing"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPAnonymizer(t *testing.T) {
+	t.Run("full", func(t *testing.T) {
+		a := &ipAnonymizer{mode: conf.PlaybackLogIPsFull}
+		err := a.initialize()
+		require.NoError(t, err)
+
+		require.Equal(t, "192.168.3.4", a.ip("192.168.3.4"))
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		a := &ipAnonymizer{mode: conf.PlaybackLogIPsTruncate}
+		err := a.initialize()
+		require.NoError(t, err)
+
+		require.Equal(t, "192.168.3.0", a.ip("192.168.3.4"))
+		require.Equal(t, "2001:db8:1::", a.ip("2001:db8:1:2:3:4:5:6"))
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		a := &ipAnonymizer{mode: conf.PlaybackLogIPsHash}
+		err := a.initialize()
+		require.NoError(t, err)
+
+		h := a.ip("192.168.3.4")
+		require.Len(t, h, 16)
+		require.NotContains(t, h, "192.168")
+		require.Equal(t, h, a.ip("192.168.3.4"))
+		require.NotEqual(t, h, a.ip("192.168.3.5"))
+
+		// keys are regenerated at every start
+		a2 := &ipAnonymizer{mode: conf.PlaybackLogIPsHash}
+		err = a2.initialize()
+		require.NoError(t, err)
+		require.NotEqual(t, h, a2.ip("192.168.3.4"))
+	})
+}
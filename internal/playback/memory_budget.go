@@ -0,0 +1,126 @@
+package playback
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+const (
+	// the MP4 muxer keeps a sample table entry (sample + pointer + payload getter)
+	// in memory for every sample until the moov box is written.
+	mp4EstimatedSampleSize = 160
+
+	// a 60 FPS video track and a couple of audio tracks.
+	mp4EstimatedSamplesPerSecond = 150
+
+	// the fMP4 muxer keeps in memory a single part at a time.
+	fmp4EstimatedMemory = 4 * 1024 * 1024
+)
+
+var errMemoryBudgetExceeded = errors.New("server is busy, memory budget exceeded")
+
+// estimateMuxMemory estimates the memory needed to mux a recording.
+func estimateMuxMemory(format string, duration time.Duration) uint64 {
+	if format == "mp4" {
+		return uint64(duration.Seconds()*mp4EstimatedSamplesPerSecond) * mp4EstimatedSampleSize
+	}
+	return fmp4EstimatedMemory
+}
+
+// coveredDuration returns the duration of the part of a range that is covered by segments,
+// in order to avoid estimating the memory of ranges that are longer than recordings.
+// Only the last segment is parsed, since it is the only one that can end before the range.
+func coveredDuration(
+	pathConf *conf.Path,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+) time.Duration {
+	if len(segments) == 0 {
+		return 0
+	}
+
+	end := start.Add(duration)
+
+	if segments[0].Start.After(start) {
+		start = segments[0].Start
+	}
+
+	last := segments[len(segments)-1]
+	var segEnd time.Time
+
+	if last.Format == conf.RecordFormatFMP4 {
+		parsed, err := parseSegment(last)
+		if err == nil {
+			segEnd = last.Start.Add(parsed.duration)
+		}
+	}
+
+	// MPEG-TS segments don't contain their duration, use the maximum one
+	if segEnd.IsZero() && pathConf.RecordSegmentDuration != 0 {
+		segEnd = last.Start.Add(time.Duration(pathConf.RecordSegmentDuration))
+	}
+
+	if !segEnd.IsZero() && segEnd.Before(end) {
+		end = segEnd
+	}
+
+	if !end.After(start) {
+		return 0
+	}
+
+	return end.Sub(start)
+}
+
+// memoryBudget limits the memory that can be used by concurrent mux operations.
+type memoryBudget struct {
+	max uint64
+
+	mutex sync.Mutex
+	used  uint64
+}
+
+// reservation returns the memory that is reserved by a request.
+// It is capped to the whole budget, that is the most a request can use.
+func (b *memoryBudget) reservation(n uint64) uint64 {
+	return min(n, b.max)
+}
+
+// acquire reserves memory in the budget.
+// A request is always admitted when there are no other requests in progress,
+// in order to allow requests that are bigger than the whole budget.
+func (b *memoryBudget) acquire(n uint64) error {
+	if b.max == 0 {
+		return nil
+	}
+
+	n = b.reservation(n)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.used != 0 && (b.used+n) > b.max {
+		return errMemoryBudgetExceeded
+	}
+
+	b.used += n
+	return nil
+}
+
+// release gives back memory previously reserved with acquire.
+func (b *memoryBudget) release(n uint64) {
+	if b.max == 0 {
+		return
+	}
+
+	n = b.reservation(n)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.used -= n
+}
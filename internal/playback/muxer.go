@@ -1,6 +1,20 @@
 package playback
 
-import "github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+import (
+	"io"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+)
+
+// muxerProgress is the progress of a muxer.
+type muxerProgress struct {
+	// bytes written to the output
+	bytesWritten uint64
+
+	// highest media time reached, relative to the requested start
+	mediaTime time.Duration
+}
 
 type muxer interface {
 	writeInit(init *fmp4.Init)
@@ -14,4 +28,16 @@ type muxer interface {
 	) error
 	writeFinalDTS(dts int64)
 	flush() error
+	progress() muxerProgress
+}
+
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += uint64(n)
+	return n, err
 }
@@ -11,6 +11,9 @@ import (
 
 const (
 	partDuration = 1 * time.Second
+
+	// duration of parts when low latency is enabled.
+	lowLatencyPartDuration = 200 * time.Millisecond
 )
 
 type muxerFMP4Track struct {
@@ -31,13 +34,23 @@ func findTrack(tracks []*muxerFMP4Track, id int) *muxerFMP4Track {
 }
 
 type muxerFMP4 struct {
-	w io.Writer
+	w            io.Writer
+	partDuration time.Duration  // optional
+	compat       *compatProfile // optional
 
 	init               *fmp4.Init
 	nextSequenceNumber uint32
 	tracks             []*muxerFMP4Track
 	curTrack           *muxerFMP4Track
 	outBuf             seekablebuffer.Buffer
+	bytesWritten       uint64
+	mediaTime          time.Duration
+}
+
+func (w *muxerFMP4) updateMediaTime(dts int64) {
+	if t := durationMp4ToGo(dts, w.curTrack.timeScale); t > w.mediaTime {
+		w.mediaTime = t
+	}
 }
 
 func (w *muxerFMP4) writeInit(init *fmp4.Init) {
@@ -93,8 +106,13 @@ func (w *muxerFMP4) writeSample(
 			Payload:         pl,
 		})
 		w.curTrack.lastDTS = dts
+		w.updateMediaTime(dts)
 
-		partDurationMP4 := durationGoToMp4(partDuration, w.curTrack.timeScale)
+		pd := w.partDuration
+		if pd == 0 {
+			pd = partDuration
+		}
+		partDurationMP4 := durationGoToMp4(pd, w.curTrack.timeScale)
 
 		if (w.curTrack.lastDTS - w.curTrack.firstDTS) >= partDurationMP4 {
 			err := w.innerFlush(false)
@@ -133,6 +151,7 @@ func (w *muxerFMP4) writeFinalDTS(dts int64) {
 			duration = 0
 		}
 		w.curTrack.samples[len(w.curTrack.samples)-1].Duration = uint32(duration)
+		w.updateMediaTime(dts)
 	}
 }
 
@@ -181,7 +200,12 @@ func (w *muxerFMP4) innerFlush(final bool) error {
 			return err
 		}
 
-		_, err = w.w.Write(w.outBuf.Bytes())
+		if w.compat != nil {
+			w.compat.rewriteHeader(w.outBuf.Bytes())
+		}
+
+		n, err := w.w.Write(w.outBuf.Bytes())
+		w.bytesWritten += uint64(n)
 		if err != nil {
 			return err
 		}
@@ -195,7 +219,8 @@ func (w *muxerFMP4) innerFlush(final bool) error {
 		return err
 	}
 
-	_, err = w.w.Write(w.outBuf.Bytes())
+	n, err := w.w.Write(w.outBuf.Bytes())
+	w.bytesWritten += uint64(n)
 	if err != nil {
 		return err
 	}
@@ -208,3 +233,10 @@ func (w *muxerFMP4) innerFlush(final bool) error {
 func (w *muxerFMP4) flush() error {
 	return w.innerFlush(true)
 }
+
+func (w *muxerFMP4) progress() muxerProgress {
+	return muxerProgress{
+		bytesWritten: w.bytesWritten,
+		mediaTime:    w.mediaTime,
+	}
+}
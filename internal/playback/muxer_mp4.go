@@ -2,15 +2,62 @@ package playback
 
 import (
 	"io"
+	"sync"
+	"time"
 
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/pmp4"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
+// samples are allocated in chunks, that are reused between requests
+// in order to reduce allocations and GC pressure.
+const mp4SampleChunkSize = 4096
+
+type mp4SampleChunk [mp4SampleChunkSize]pmp4.Sample
+
+var mp4SampleChunkPool = sync.Pool{
+	New: func() interface{} {
+		return new(mp4SampleChunk)
+	},
+}
+
+type mp4SampleAllocator struct {
+	chunks []*mp4SampleChunk
+	n      int
+}
+
+func (a *mp4SampleAllocator) alloc() *pmp4.Sample {
+	i := a.n / mp4SampleChunkSize
+	if i == len(a.chunks) {
+		a.chunks = append(a.chunks, mp4SampleChunkPool.Get().(*mp4SampleChunk))
+	}
+
+	s := &a.chunks[i][a.n%mp4SampleChunkSize]
+	*s = pmp4.Sample{}
+	a.n++
+	return s
+}
+
+// reset allows to reuse samples. It must be called only
+// when previously allocated samples are not in use anymore.
+func (a *mp4SampleAllocator) reset() {
+	a.n = 0
+}
+
+func (a *mp4SampleAllocator) release() {
+	for _, c := range a.chunks {
+		*c = mp4SampleChunk{} // release references to payload getters
+		mp4SampleChunkPool.Put(c)
+	}
+	a.chunks = nil
+	a.n = 0
+}
+
 type muxerMP4Track struct {
 	pmp4.Track
 	lastDTS int64
+	alloc   mp4SampleAllocator
 }
 
 func findTrackMP4(tracks []*muxerMP4Track, id int) *muxerMP4Track {
@@ -23,10 +70,19 @@ func findTrackMP4(tracks []*muxerMP4Track, id int) *muxerMP4Track {
 }
 
 type muxerMP4 struct {
-	w io.Writer
+	w      io.Writer
+	compat *compatProfile // optional
+
+	tracks       []*muxerMP4Track
+	curTrack     *muxerMP4Track
+	bytesWritten uint64
+	mediaTime    time.Duration
+}
 
-	tracks   []*muxerMP4Track
-	curTrack *muxerMP4Track
+func (w *muxerMP4) updateMediaTime(dts int64) {
+	if t := durationMp4ToGo(dts, w.curTrack.TimeScale); t > w.mediaTime {
+		w.mediaTime = t
+	}
 }
 
 func (w *muxerMP4) writeInit(init *fmp4.Init) {
@@ -57,6 +113,7 @@ func (w *muxerMP4) writeSample(
 	// remove GOPs before the GOP of the first sample
 	if (dts < 0 || (dts >= 0 && w.curTrack.lastDTS < 0)) && !isNonSyncSample {
 		w.curTrack.Samples = w.curTrack.Samples[:0]
+		w.curTrack.alloc.reset()
 	}
 
 	if len(w.curTrack.Samples) == 0 {
@@ -74,13 +131,15 @@ func (w *muxerMP4) writeSample(
 		ptsOffset = 0
 	}
 
-	w.curTrack.Samples = append(w.curTrack.Samples, &pmp4.Sample{
-		PTSOffset:       ptsOffset,
-		IsNonSyncSample: isNonSyncSample,
-		PayloadSize:     payloadSize,
-		GetPayload:      getPayload,
-	})
+	sample := w.curTrack.alloc.alloc()
+	sample.PTSOffset = ptsOffset
+	sample.IsNonSyncSample = isNonSyncSample
+	sample.PayloadSize = payloadSize
+	sample.GetPayload = getPayload
+
+	w.curTrack.Samples = append(w.curTrack.Samples, sample)
 	w.curTrack.lastDTS = dts
+	w.updateMediaTime(dts)
 
 	return nil
 }
@@ -92,10 +151,18 @@ func (w *muxerMP4) writeFinalDTS(dts int64) {
 			duration = 0
 		}
 		w.curTrack.Samples[len(w.curTrack.Samples)-1].Duration = uint32(duration)
+		w.updateMediaTime(dts)
 	}
 }
 
 func (w *muxerMP4) flush() error {
+	defer func() {
+		for _, track := range w.tracks {
+			track.Samples = nil
+			track.alloc.release()
+		}
+	}()
+
 	if len(w.curTrack.Samples) == 0 || w.curTrack.lastDTS < 0 {
 		return recordstore.ErrNoSegmentsFound
 	}
@@ -108,5 +175,21 @@ func (w *muxerMP4) flush() error {
 		h.Tracks[i] = &track.Track
 	}
 
-	return h.Marshal(w.w)
+	cw := &countingWriter{w: w.w}
+
+	var mw io.Writer = cw
+	if w.compat != nil {
+		mw = &compatWriter{w: cw, profile: w.compat}
+	}
+
+	err := h.Marshal(mw)
+	w.bytesWritten = cw.n
+	return err
+}
+
+func (w *muxerMP4) progress() muxerProgress {
+	return muxerProgress{
+		bytesWritten: w.bytesWritten,
+		mediaTime:    w.mediaTime,
+	}
 }
@@ -0,0 +1,146 @@
+package playback
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+)
+
+// redactRange is a range to redact.
+type redactRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// parseRedactRange parses a range in the format "start,duration".
+func parseRedactRange(raw string) (redactRange, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 2 {
+		return redactRange{}, fmt.Errorf("invalid redact: %s", raw)
+	}
+
+	start, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return redactRange{}, fmt.Errorf("invalid redact: %w", err)
+	}
+
+	duration, err := parseDuration(parts[1])
+	if err != nil {
+		return redactRange{}, fmt.Errorf("invalid redact: %w", err)
+	}
+
+	return redactRange{
+		start: start,
+		end:   start.Add(duration),
+	}, nil
+}
+
+type muxerRedactTrack struct {
+	timeScale uint32
+	isVideo   bool
+
+	// video samples after a redacted range are dropped until a random access point,
+	// since they can't be decoded without the previous ones.
+	waitingKeyframe bool
+
+	// the duration of a sample is known only when the next one is received,
+	// therefore the duration of the previous sample is used instead.
+	prevDTS      int64
+	prevDuration int64
+	hasPrev      bool
+}
+
+// muxerRedact removes samples that are inside redacted ranges.
+// Redacted ranges are not replaced with other content, therefore
+// players show the last frame that precedes them.
+type muxerRedact struct {
+	m      muxer
+	start  time.Time
+	ranges []redactRange
+
+	tracks   map[int]*muxerRedactTrack
+	curTrack *muxerRedactTrack
+}
+
+func (w *muxerRedact) writeInit(init *fmp4.Init) {
+	w.tracks = make(map[int]*muxerRedactTrack)
+	for _, track := range init.Tracks {
+		w.tracks[track.ID] = &muxerRedactTrack{
+			timeScale: track.TimeScale,
+			isVideo:   track.Codec.IsVideo(),
+		}
+	}
+
+	w.m.writeInit(init)
+}
+
+func (w *muxerRedact) setTrack(trackID int) {
+	w.curTrack = w.tracks[trackID]
+	w.m.setTrack(trackID)
+}
+
+// isRedacted checks whether a sample is displayed inside a redacted range.
+// Samples are displayed from their presentation timestamp, that differs from
+// the decode timestamp when frames are reordered, until the next sample.
+func (w *muxerRedact) isRedacted(dts int64, ptsOffset int32) bool {
+	t := w.curTrack
+
+	if t.hasPrev {
+		t.prevDuration = dts - t.prevDTS
+	}
+	t.prevDTS = dts
+	t.hasPrev = true
+
+	pts := dts + int64(ptsOffset)
+	displayStart := w.start.Add(durationMp4ToGo(pts, t.timeScale))
+	displayEnd := w.start.Add(durationMp4ToGo(pts+t.prevDuration, t.timeScale))
+
+	for _, r := range w.ranges {
+		if !displayStart.Before(r.end) {
+			continue
+		}
+
+		if displayEnd.After(r.start) || !displayStart.Before(r.start) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *muxerRedact) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	if w.isRedacted(dts, ptsOffset) {
+		if w.curTrack.isVideo {
+			w.curTrack.waitingKeyframe = true
+		}
+		return nil
+	}
+
+	if w.curTrack.waitingKeyframe {
+		if isNonSyncSample {
+			return nil
+		}
+		w.curTrack.waitingKeyframe = false
+	}
+
+	return w.m.writeSample(dts, ptsOffset, isNonSyncSample, payloadSize, getPayload)
+}
+
+func (w *muxerRedact) writeFinalDTS(dts int64) {
+	w.m.writeFinalDTS(dts)
+}
+
+func (w *muxerRedact) flush() error {
+	return w.m.flush()
+}
+
+func (w *muxerRedact) progress() muxerProgress {
+	return w.m.progress()
+}
@@ -0,0 +1,78 @@
+package playback
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+type dtsMuxer struct {
+	dts []int64
+}
+
+func (m *dtsMuxer) writeInit(_ *fmp4.Init) {}
+
+func (m *dtsMuxer) setTrack(_ int) {}
+
+func (m *dtsMuxer) writeSample(dts int64, _ int32, _ bool, _ uint32, _ func() ([]byte, error)) error {
+	m.dts = append(m.dts, dts)
+	return nil
+}
+
+func (m *dtsMuxer) writeFinalDTS(_ int64) {}
+
+func (m *dtsMuxer) flush() error {
+	return nil
+}
+
+func (m *dtsMuxer) progress() muxerProgress {
+	return muxerProgress{}
+}
+
+func TestMuxerRedactPresentationTime(t *testing.T) {
+	start := time.Date(2008, 11, 0o7, 11, 23, 2, 0, time.UTC)
+
+	dm := &dtsMuxer{}
+
+	m := &muxerRedact{
+		m:     dm,
+		start: start,
+		ranges: []redactRange{
+			// inside the display span of the second sample
+			{start: start.Add(150 * time.Millisecond), end: start.Add(160 * time.Millisecond)},
+			// the third sample is decoded before the range but displayed inside it
+			{start: start.Add(450 * time.Millisecond), end: start.Add(460 * time.Millisecond)},
+		},
+	}
+
+	m.writeInit(&fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &mp4.CodecH264{
+				SPS: test.FormatH264.SPS,
+				PPS: test.FormatH264.PPS,
+			},
+		}},
+	})
+	m.setTrack(1)
+
+	for _, s := range []struct {
+		dts       int64
+		ptsOffset int32
+	}{
+		{0, 0},         // displayed at 0ms
+		{9000, 0},      // displayed from 100ms to 200ms
+		{18000, 18000}, // displayed from 400ms to 500ms
+		{27000, 0},     // displayed from 300ms to 400ms
+	} {
+		err := m.writeSample(s.dts, s.ptsOffset, false, 1, nil)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []int64{0, 27000}, dm.dts)
+}
@@ -0,0 +1,84 @@
+package playback
+
+import (
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+)
+
+// muxerStitch places multiple ranges back-to-back into a single muxer.
+// Each range must start with a random access point, since samples
+// placed before the beginning of a range are discarded.
+type muxerStitch struct {
+	m muxer
+
+	timeScales  map[int]uint32
+	curTrack    int
+	rangeOffset time.Duration
+	rangeEnd    time.Duration
+}
+
+// nextRange moves the timeline after the end of the current range.
+func (w *muxerStitch) nextRange() {
+	w.rangeOffset += w.rangeEnd
+	w.rangeEnd = 0
+}
+
+func (w *muxerStitch) writeInit(init *fmp4.Init) {
+	// compatibility of following inits is checked in advance
+	if w.timeScales != nil {
+		return
+	}
+
+	w.timeScales = make(map[int]uint32)
+	for _, track := range init.Tracks {
+		w.timeScales[track.ID] = track.TimeScale
+	}
+
+	w.m.writeInit(init)
+}
+
+func (w *muxerStitch) setTrack(trackID int) {
+	w.curTrack = trackID
+	w.m.setTrack(trackID)
+}
+
+func (w *muxerStitch) offset() int64 {
+	return durationGoToMp4(w.rangeOffset, w.timeScales[w.curTrack])
+}
+
+func (w *muxerStitch) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	if dts < 0 {
+		return nil
+	}
+
+	return w.m.writeSample(dts+w.offset(), ptsOffset, isNonSyncSample, payloadSize, getPayload)
+}
+
+func (w *muxerStitch) writeFinalDTS(dts int64) {
+	if dts < 0 {
+		return
+	}
+
+	if d := durationMp4ToGo(dts, w.timeScales[w.curTrack]); d > w.rangeEnd {
+		w.rangeEnd = d
+	}
+
+	w.m.writeFinalDTS(dts + w.offset())
+}
+
+// flush is called by seekAndMux at the end of every range.
+// The inner muxer is flushed once all ranges have been written.
+func (w *muxerStitch) flush() error {
+	return nil
+}
+
+func (w *muxerStitch) progress() muxerProgress {
+	return w.m.progress()
+}
@@ -0,0 +1,382 @@
+package playback
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+const (
+	tsClockRate = 90000
+
+	// timestamps start from this value, in order to leave room for B-frames,
+	// that have a PTS lower than the DTS of the following samples.
+	tsTimestampOffset = 1 * tsClockRate
+
+	// tracks that are behind other tracks by more than this are considered ended,
+	// and stop delaying the other tracks.
+	tsMaxInterleave = 5 * time.Second
+
+	tsBufferSize = 64 * 1024
+)
+
+// tsCodec converts a MP4 codec into a MPEG-TS codec.
+// It returns nil if the codec can't be stored in MPEG-TS.
+func tsCodec(codec mp4.Codec) mpegts.Codec {
+	switch codec := codec.(type) {
+	case *mp4.CodecH265:
+		return &mpegts.CodecH265{}
+
+	case *mp4.CodecH264:
+		return &mpegts.CodecH264{}
+
+	case *mp4.CodecOpus:
+		return &mpegts.CodecOpus{ChannelCount: codec.ChannelCount}
+
+	case *mp4.CodecMPEG4Audio:
+		return &mpegts.CodecMPEG4Audio{Config: codec.Config}
+
+	case *mp4.CodecMPEG1Audio:
+		return &mpegts.CodecMPEG1Audio{}
+
+	case *mp4.CodecAC3:
+		return &mpegts.CodecAC3{SampleRate: codec.SampleRate, ChannelCount: codec.ChannelCount}
+	}
+
+	return nil
+}
+
+func tsContainsParams(au [][]byte, isParam func([]byte) bool) bool {
+	for _, nalu := range au {
+		if len(nalu) != 0 && isParam(nalu) {
+			return true
+		}
+	}
+	return false
+}
+
+// tsPrependParams inserts parameters at the beginning of an access unit,
+// after the access unit delimiter, if there's one.
+func tsPrependParams(au [][]byte, params [][]byte, startsWithAUD bool) [][]byte {
+	if startsWithAUD {
+		return append(append([][]byte{au[0]}, params...), au[1:]...)
+	}
+	return append(params, au...)
+}
+
+type muxerTSSample struct {
+	dts          int64
+	pts          int64
+	randomAccess bool
+	getPayload   func() ([]byte, error)
+}
+
+type muxerTSTrack struct {
+	id        int
+	timeScale uint32
+	codec     mp4.Codec
+	track     *mpegts.Track // nil when the codec is not supported
+	started   bool
+	received  bool
+	lastDTS   int64
+	queue     []*muxerTSSample
+}
+
+// muxerTS is a muxer that converts fMP4 recordings into MPEG-TS.
+// Since samples are provided one track at a time, they are queued
+// and written in DTS order.
+type muxerTS struct {
+	w io.Writer
+
+	tracks       []*muxerTSTrack
+	curTrack     *muxerTSTrack
+	cw           *countingWriter
+	bw           *bufio.Writer
+	mw           *mpegts.Writer
+	written      bool
+	bytesWritten uint64
+	mediaTime    time.Duration
+}
+
+func (w *muxerTS) updateMediaTime(dts int64) {
+	if t := durationMp4ToGo(dts, w.curTrack.timeScale); t > w.mediaTime {
+		w.mediaTime = t
+	}
+}
+
+func (w *muxerTS) timestamp(v int64, timeScale uint32) int64 {
+	return tsTimestampOffset + durationGoToMp4(durationMp4ToGo(v, timeScale), tsClockRate)
+}
+
+func (w *muxerTS) writeInit(init *fmp4.Init) {
+	w.tracks = make([]*muxerTSTrack, len(init.Tracks))
+
+	for i, track := range init.Tracks {
+		t := &muxerTSTrack{
+			id:        track.ID,
+			timeScale: track.TimeScale,
+			codec:     track.Codec,
+		}
+
+		if codec := tsCodec(track.Codec); codec != nil {
+			t.track = &mpegts.Track{Codec: codec}
+		}
+
+		w.tracks[i] = t
+	}
+}
+
+func (w *muxerTS) setTrack(trackID int) {
+	for _, track := range w.tracks {
+		if track.id == trackID {
+			w.curTrack = track
+			return
+		}
+	}
+	w.curTrack = nil
+}
+
+func (w *muxerTS) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	_ uint32,
+	getPayload func() ([]byte, error),
+) error {
+	t := w.curTrack
+	if t == nil || t.track == nil {
+		return nil
+	}
+
+	if dts < 0 {
+		// keep the GOP that precedes the start, with the DTS of the start
+		if !isNonSyncSample {
+			t.queue = t.queue[:0]
+		}
+		dts = 0
+	} else if !t.started {
+		t.started = true
+
+		// if this is a random access point, the previous GOP is not needed
+		if !isNonSyncSample {
+			t.queue = t.queue[:0]
+		}
+	}
+
+	t.queue = append(t.queue, &muxerTSSample{
+		dts:          w.timestamp(dts, t.timeScale),
+		pts:          w.timestamp(dts+int64(ptsOffset), t.timeScale),
+		randomAccess: !isNonSyncSample,
+		getPayload:   getPayload,
+	})
+	t.received = true
+	t.lastDTS = t.queue[len(t.queue)-1].dts
+	w.updateMediaTime(dts)
+
+	return w.writeQueued(false)
+}
+
+// watermark returns the DTS before which all samples have been received.
+func (w *muxerTS) watermark() int64 {
+	var maxDTS int64
+	for _, t := range w.tracks {
+		if t.received && t.lastDTS > maxDTS {
+			maxDTS = t.lastDTS
+		}
+	}
+
+	minDTS := maxDTS
+	maxInterleave := durationGoToMp4(tsMaxInterleave, tsClockRate)
+	for _, t := range w.tracks {
+		if t.received && t.lastDTS < minDTS && (maxDTS-t.lastDTS) <= maxInterleave {
+			minDTS = t.lastDTS
+		}
+	}
+
+	return minDTS
+}
+
+// writeQueued writes queued samples in DTS order.
+// Unless final is true, samples are written only when all tracks have reached their DTS.
+func (w *muxerTS) writeQueued(final bool) error {
+	watermark := w.watermark()
+
+	for {
+		var next *muxerTSTrack
+		for _, t := range w.tracks {
+			if len(t.queue) != 0 && t.started && (next == nil || t.queue[0].dts < next.queue[0].dts) {
+				next = t
+			}
+		}
+
+		if next == nil || (!final && next.queue[0].dts >= watermark) {
+			return nil
+		}
+
+		s := next.queue[0]
+		next.queue = next.queue[1:]
+
+		err := w.writeTSSample(next, s)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (w *muxerTS) initializeWriter() error {
+	var tracks []*mpegts.Track
+	for _, t := range w.tracks {
+		if t.track != nil {
+			tracks = append(tracks, t.track)
+		}
+	}
+
+	w.cw = &countingWriter{w: w.w}
+	w.bw = bufio.NewWriterSize(w.cw, tsBufferSize)
+	w.mw = &mpegts.Writer{W: w.bw, Tracks: tracks}
+	return w.mw.Initialize()
+}
+
+func (w *muxerTS) writeTSSample(t *muxerTSTrack, s *muxerTSSample) error {
+	if w.mw == nil {
+		err := w.initializeWriter()
+		if err != nil {
+			return err
+		}
+	}
+
+	payload, err := s.getPayload()
+	if err != nil {
+		return err
+	}
+
+	w.written = true
+
+	switch codec := t.codec.(type) {
+	case *mp4.CodecH265:
+		var au h264.AVCC
+		err = au.Unmarshal(payload)
+		if err != nil {
+			return err
+		}
+
+		// parameters are stored in the initialization section, while MPEG-TS needs them in-band
+		if s.randomAccess && !tsContainsParams(au, func(nalu []byte) bool {
+			return h265.NALUType((nalu[0]>>1)&0b111111) == h265.NALUType_VPS_NUT
+		}) {
+			au = tsPrependParams(au, [][]byte{codec.VPS, codec.SPS, codec.PPS},
+				h265.NALUType((au[0][0]>>1)&0b111111) == h265.NALUType_AUD_NUT)
+		}
+
+		return w.mw.WriteH265(t.track, s.pts, s.dts, au)
+
+	case *mp4.CodecH264:
+		var au h264.AVCC
+		err = au.Unmarshal(payload)
+		if err != nil {
+			return err
+		}
+
+		if s.randomAccess && !tsContainsParams(au, func(nalu []byte) bool {
+			return h264.NALUType(nalu[0]&0x1F) == h264.NALUTypeSPS
+		}) {
+			au = tsPrependParams(au, [][]byte{codec.SPS, codec.PPS},
+				h264.NALUType(au[0][0]&0x1F) == h264.NALUTypeAccessUnitDelimiter)
+		}
+
+		return w.mw.WriteH264(t.track, s.pts, s.dts, au)
+
+	case *mp4.CodecOpus:
+		return w.mw.WriteOpus(t.track, s.pts, [][]byte{payload})
+
+	case *mp4.CodecMPEG4Audio:
+		return w.mw.WriteMPEG4Audio(t.track, s.pts, [][]byte{payload})
+
+	case *mp4.CodecMPEG1Audio:
+		return w.mw.WriteMPEG1Audio(t.track, s.pts, [][]byte{payload})
+
+	case *mp4.CodecAC3:
+		return w.mw.WriteAC3(t.track, s.pts, payload)
+	}
+
+	return nil
+}
+
+func (w *muxerTS) writeFinalDTS(dts int64) {
+	if w.curTrack != nil && w.curTrack.received {
+		w.updateMediaTime(dts)
+	}
+}
+
+func (w *muxerTS) flush() error {
+	err := w.writeQueued(true)
+	if err != nil {
+		return err
+	}
+
+	if !w.written {
+		for _, t := range w.tracks {
+			if t.track != nil {
+				return recordstore.ErrNoSegmentsFound
+			}
+		}
+		return fmt.Errorf("none of the tracks can be converted to the ts format")
+	}
+
+	err = w.bw.Flush()
+	w.bytesWritten = w.cw.n
+	return err
+}
+
+// copySegments writes MPEG-TS segments as they are stored.
+func (w *muxerTS) copySegments(ctx context.Context, segments []*recordstore.Segment) error {
+	cw := &countingWriter{w: w.w}
+	defer func() {
+		w.bytesWritten += cw.n
+	}()
+
+	for _, seg := range segments {
+		// stop reading from disk when the client goes away
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		err = func() error {
+			f, err := seg.Open()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			_, err = io.Copy(cw, f)
+			return err
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *muxerTS) progress() muxerProgress {
+	bytesWritten := w.bytesWritten
+	if w.cw != nil {
+		bytesWritten = w.cw.n
+	}
+
+	return muxerProgress{
+		bytesWritten: bytesWritten,
+		mediaTime:    w.mediaTime,
+	}
+}
@@ -0,0 +1,119 @@
+package playback
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// noSegmentsResponse is returned when there are no recordings in the requested window.
+type noSegmentsResponse struct {
+	Error  string     `json:"error"`
+	Before *listEntry `json:"before"`
+	After  *listEntry `json:"after"`
+}
+
+func nearestEntry(seg *recordstore.Segment) *listEntry {
+	parsed, err := parseSegment(seg)
+	if err != nil {
+		return nil
+	}
+
+	return &listEntry{
+		Start:    parsed.start,
+		Duration: listEntryDuration(parsed.duration),
+		Tracks:   newListEntryTracks(parsed.init),
+	}
+}
+
+// nearestEntries returns the segments that are the closest to a window
+// that doesn't contain any recording, before and after it.
+func (s *Server) nearestEntries(
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	end time.Time,
+) (*listEntry, *listEntry) {
+	// segments of MPEG-TS recordings can't be parsed
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		return nil, nil
+	}
+
+	var before *listEntry
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, &start)
+	if err == nil {
+		segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+		for i := len(segments) - 1; i >= 0; i-- {
+			if segments[i].Start.Before(start) {
+				before = nearestEntry(segments[i])
+				break
+			}
+		}
+	}
+
+	var after *listEntry
+
+	// the segment that precedes end may be returned too
+	segments, err = recordstore.FindSegments(pathConf, pathName, &end, nil)
+	if err == nil {
+		segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+		for _, seg := range segments {
+			if !seg.Start.Before(end) {
+				after = nearestEntry(seg)
+				break
+			}
+		}
+	}
+
+	return before, after
+}
+
+// noSegmentsError describes why there are no recordings in a window.
+func noSegmentsError(start time.Time, before *listEntry, after *listEntry) error {
+	switch {
+	case start.After(time.Now()):
+		return fmt.Errorf("%w: start is in the future", recordstore.ErrNoSegmentsFound)
+
+	case before == nil && after != nil:
+		return fmt.Errorf("%w: the oldest recording starts at %s",
+			recordstore.ErrNoSegmentsFound, after.Start.Format(time.RFC3339Nano))
+	}
+
+	return recordstore.ErrNoSegmentsFound
+}
+
+// writeNoSegments replies with a 404 error that contains the recordings
+// that are the closest to the requested window, in order to allow
+// clients to jump to them.
+func (s *Server) writeNoSegments(
+	ctx *gin.Context,
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	end time.Time,
+) {
+	var res noSegmentsResponse
+	res.Before, res.After = s.nearestEntries(pathConf, pathName, start, end)
+
+	err := noSegmentsError(start, res.Before, res.After)
+	res.Error = err.Error()
+
+	// show error in logs and in the audit log
+	s.logRequest(ctx, logger.Error, err.Error())
+	ctx.Error(err) //nolint:errcheck
+
+	if res.Before != nil {
+		res.Before.URL = s.entryURL(ctx, pathName, res.Before)
+	}
+	if res.After != nil {
+		res.After.URL = s.entryURL(ctx, pathName, res.After)
+	}
+
+	ctx.JSON(http.StatusNotFound, res)
+}
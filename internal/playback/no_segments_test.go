@@ -0,0 +1,131 @@
+package playback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoSegmentsNearest(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-30-00-000000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	seg1 := time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local)
+	seg2 := time.Date(2008, 11, 0o7, 11, 30, 0, 0, time.Local)
+
+	for _, ca := range []struct {
+		name   string
+		start  time.Time
+		before *time.Time
+		after  *time.Time
+		err    string
+	}{
+		{
+			"gap",
+			time.Date(2008, 11, 0o7, 11, 25, 0, 0, time.Local),
+			&seg1,
+			&seg2,
+			"no recording segments found",
+		},
+		{
+			"before first",
+			time.Date(2008, 11, 0o7, 11, 0, 0, 0, time.Local),
+			nil,
+			&seg1,
+			"no recording segments found: the oldest recording starts at " + seg1.Format(time.RFC3339Nano),
+		},
+		{
+			"after last",
+			time.Date(2008, 11, 0o7, 12, 0, 0, 0, time.Local),
+			&seg2,
+			nil,
+			"no recording segments found",
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			v := url.Values{}
+			v.Set("path", "mypath")
+			v.Set("start", ca.start.Format(time.RFC3339Nano))
+			v.Set("duration", "10")
+
+			res, err := hc.Get("http://localhost:9996/get?" + v.Encode())
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+			var out struct {
+				Error  string `json:"error"`
+				Before *struct {
+					Start    time.Time `json:"start"`
+					Duration float64   `json:"duration"`
+					URL      string    `json:"url"`
+				} `json:"before"`
+				After *struct {
+					Start    time.Time `json:"start"`
+					Duration float64   `json:"duration"`
+					URL      string    `json:"url"`
+				} `json:"after"`
+			}
+			err = json.NewDecoder(res.Body).Decode(&out)
+			require.NoError(t, err)
+
+			require.Equal(t, ca.err, out.Error)
+
+			if ca.before != nil {
+				require.NotNil(t, out.Before)
+				require.True(t, ca.before.Equal(out.Before.Start))
+				require.Equal(t, float64(4), out.Before.Duration)
+				require.NotEmpty(t, out.Before.URL)
+			} else {
+				require.Nil(t, out.Before)
+			}
+
+			if ca.after != nil {
+				require.NotNil(t, out.After)
+				require.True(t, ca.after.Equal(out.After.Start))
+				require.Equal(t, float64(4), out.After.Duration)
+				require.NotEmpty(t, out.After.URL)
+			} else {
+				require.Nil(t, out.After)
+			}
+		})
+	}
+}
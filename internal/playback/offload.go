@@ -0,0 +1,110 @@
+package playback
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// offloadTarget returns the value of the offload header that points to a segment.
+// X-Sendfile expects a file path, while X-Accel-Redirect expects the URI
+// of an internal location of the reverse proxy, that is built by replacing
+// the non-variable part of the record path with the prefix.
+func (s *Server) offloadTarget(pathConf *conf.Path, fpath string) (string, error) {
+	if s.OffloadHeader == "X-Sendfile" {
+		return filepath.Abs(fpath)
+	}
+
+	// the record path can be relative, while segment paths are absolute
+	base, err := filepath.Abs(recordstore.CommonPath(pathConf.RecordPath))
+	if err != nil {
+		return "", err
+	}
+
+	fpath, err = filepath.Abs(fpath)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(base, fpath)
+	if err != nil {
+		return "", err
+	}
+
+	// segments in the archive path are not reachable through the prefix
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("segment is outside the record path")
+	}
+
+	return strings.TrimSuffix(s.OffloadPrefix, "/") + "/" + filepath.ToSlash(rel), nil
+}
+
+// offloadableSegment checks whether the transfer of a segment can be delegated to the reverse proxy,
+// and returns the segment and the value of the offload header.
+// This is possible only when the requested range covers a single segment entirely,
+// and that segment is already in the requested format.
+func (s *Server) offloadableSegment(
+	ctx *gin.Context,
+	pathConf *conf.Path,
+	segments []*recordstore.Segment,
+	format string,
+	start time.Time,
+	duration time.Duration,
+) (*parsedSegment, string, bool) {
+	// encrypted segments must be decrypted by the server
+	if s.OffloadHeader == "" ||
+		pathConf.RecordEncryptionKey != "" ||
+		(format != "" && format != "fmp4") ||
+		len(segments) != 1 ||
+		segments[0].Format != conf.RecordFormatFMP4 ||
+		start.After(segments[0].Start) {
+		return nil, "", false
+	}
+
+	parsed, err := parseSegment(segments[0])
+	if err != nil {
+		return nil, "", false
+	}
+
+	if start.Add(duration).Before(parsed.start.Add(parsed.duration)) {
+		return nil, "", false
+	}
+
+	target, err := s.offloadTarget(pathConf, segments[0].Fpath)
+	if err != nil {
+		return nil, "", false
+	}
+
+	return parsed, target, true
+}
+
+// offloadSegment delegates the transfer of a segment to the reverse proxy, when possible.
+func (s *Server) offloadSegment(
+	ctx *gin.Context,
+	pathConf *conf.Path,
+	segments []*recordstore.Segment,
+	format string,
+	start time.Time,
+	duration time.Duration,
+) bool {
+	parsed, target, ok := s.offloadableSegment(ctx, pathConf, segments, format, start, duration)
+	if !ok {
+		return false
+	}
+
+	s.logRequest(ctx, logger.Debug, "offloading %s", target)
+
+	ctx.Header("Content-Type", "video/mp4")
+	ctx.Header("X-Playback-Actual-Start", parsed.start.Format(time.RFC3339Nano))
+	ctx.Header(s.OffloadHeader, target)
+	ctx.Status(http.StatusOK)
+
+	return true
+}
@@ -0,0 +1,217 @@
+package playback
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+const archiveManifestName = "manifest.json"
+
+type archiveManifestSegment struct {
+	Name   string    `json:"name"`
+	Start  time.Time `json:"start"`
+	Size   int64     `json:"size"`
+	SHA256 string    `json:"sha256"`
+}
+
+type archiveManifest struct {
+	Path     string                    `json:"path"`
+	Start    time.Time                 `json:"start"`
+	End      time.Time                 `json:"end"`
+	Format   conf.RecordFormat         `json:"format"`
+	Segments []*archiveManifestSegment `json:"segments"`
+	Tags     []*recordstore.Tag        `json:"tags"`
+}
+
+func archiveEntryName(seg *recordstore.Segment) string {
+	return seg.Start.UTC().Format("2006-01-02_15-04-05.000000") + filepath.Ext(seg.Fpath)
+}
+
+// writeArchiveSegment writes a segment into a tar archive and returns its manifest entry.
+func writeArchiveSegment(tw *tar.Writer, seg *recordstore.Segment) (*archiveManifestSegment, error) {
+	f, err := seg.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// the size of encrypted segments is the one of decrypted content
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	name := archiveEntryName(seg)
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  seg.Start,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the segment may be still growing: copy the size that has been written in the header only
+	h := sha256.New()
+	_, err = io.CopyN(io.MultiWriter(tw, h), f, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archiveManifestSegment{
+		Name:   name,
+		Start:  seg.Start,
+		Size:   size,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// onArchive sends a tar archive that contains raw segments of a time window,
+// followed by a manifest with their timestamps and hashes.
+func (s *Server) onArchive(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackExport, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, ctx.Query("end"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
+		return
+	}
+
+	if !end.After(start) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("end must be after start"))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	start, end, ok := s.applyRangePolicy(ctx, pathConf, pathName, start, end)
+	if !ok {
+		return
+	}
+
+	hs, hreq, ok := s.startHooks(ctx, pathName, "tar", start, end.Sub(start))
+	if !ok {
+		return
+	}
+	start, end = hreq.Start, hreq.Start.Add(hreq.Duration)
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	tags, err := recordstore.FindTags(pathConf, pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !s.admitRate(ctx) {
+		return
+	}
+
+	if !s.admitLoad(ctx) {
+		return
+	}
+
+	release, ok := s.admitLane(ctx, end.Sub(start))
+	if !ok {
+		return
+	}
+	defer release()
+
+	ctx.Header("Content-Type", "application/x-tar")
+	ctx.Header("Content-Disposition", `attachment; filename="recordings.tar"`)
+	ctx.Status(http.StatusOK)
+
+	export := newHookExport(hs, hreq, s.newLoadWriter(s.newStallWriter(ctx, ctx.Writer)))
+	tw := tar.NewWriter(export)
+
+	manifest := &archiveManifest{
+		Path:     pathName,
+		Start:    start,
+		End:      end,
+		Format:   pathConf.RecordFormat,
+		Segments: []*archiveManifestSegment{},
+		Tags:     recordstore.TagsOverlapping(tags, start, end),
+	}
+
+	for _, seg := range segments {
+		entry, err := writeArchiveSegment(tw, seg)
+		if err != nil {
+			// user aborted the download
+			if clientAborted(ctx, err) {
+				return
+			}
+
+			// the archive has already been started: abort and write logs only
+			s.logRequest(ctx, logger.Error, "segment %s: %v", seg.Fpath, err)
+			return
+		}
+
+		manifest.Segments = append(manifest.Segments, entry)
+	}
+
+	// the manifest is written last, since hashes are computed while segments are sent
+	buf, _ := json.MarshalIndent(manifest, "", "  ")
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     archiveManifestName,
+		Size:     int64(len(buf)),
+		Mode:     0o644,
+		ModTime:  time.Now(),
+	})
+	if err == nil {
+		_, err = tw.Write(buf)
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = export.close()
+	}
+	if err != nil {
+		s.logRequest(ctx, logger.Error, err.Error())
+	}
+}
@@ -0,0 +1,223 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type compactEntry struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+	Merged   int               `json:"merged"`
+}
+
+// compactRuns groups segments into runs that can be merged together.
+// Each run contains segments that can be concatenated and whose total duration
+// doesn't exceed maxDuration.
+func compactRuns(
+	segments []*recordstore.Segment,
+	parsed []*parsedSegment,
+	maxDuration time.Duration,
+) [][]int {
+	var runs [][]int
+	var cur []int
+	var curEnd time.Time
+
+	for i, p := range parsed {
+		if len(cur) != 0 &&
+			segmentFMP4CanBeConcatenated(parsed[cur[0]].init, curEnd, p.init, p.start) &&
+			p.start.Add(p.duration).Sub(segments[cur[0]].Start) <= maxDuration {
+			cur = append(cur, i)
+		} else {
+			if len(cur) > 1 {
+				runs = append(runs, cur)
+			}
+			cur = []int{i}
+		}
+
+		curEnd = p.start.Add(p.duration)
+	}
+
+	if len(cur) > 1 {
+		runs = append(runs, cur)
+	}
+
+	return runs
+}
+
+// compactSegments merges segments into a single segment, that replaces the first one.
+// It returns the segments that have been removed.
+func compactSegments(
+	recordFormat conf.RecordFormat,
+	segments []*recordstore.Segment,
+	duration time.Duration,
+) ([]*recordstore.Segment, error) {
+	first := segments[0]
+
+	// the new file is encrypted with the key of the original segments
+	tmpPath, err := muxToTempFile(recordFormat, segments, first.Start, duration, first.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// replace the first segment atomically, then remove the others.
+	// in case of a crash, this leads to duplicate data, but never to data loss.
+	err = os.Rename(tmpPath, first.Fpath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	var removed []*recordstore.Segment
+
+	for _, seg := range segments[1:] {
+		if os.Remove(seg.Fpath) == nil {
+			removed = append(removed, seg)
+		}
+	}
+
+	return removed, nil
+}
+
+func (s *Server) onCompact(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDelete, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, ctx.Query("end"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
+		return
+	}
+
+	if !end.After(start) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("end must be after start"))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("compaction is supported with the fMP4 format only"))
+		return
+	}
+
+	// segments are managed by an external tool
+	if pathConf.RecordLayout != "" {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("compaction cannot be used with 'recordLayout'"))
+		return
+	}
+
+	holds, err := recordstore.FindHolds(pathConf, pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	// compaction rewrites and removes segments
+	if recordstore.HoldsOverlap(holds, start, end) {
+		s.writeError(ctx, http.StatusConflict, fmt.Errorf("range is protected by a hold"))
+		return
+	}
+
+	maxDuration := time.Duration(pathConf.RecordSegmentDuration)
+	if raw := ctx.Query("maxDuration"); raw != "" {
+		maxDuration, err = parseDuration(raw)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid maxDuration: %w", err))
+			return
+		}
+		if maxDuration <= 0 {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid maxDuration"))
+			return
+		}
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	// the last segment of the path may still be in the process of being written.
+	segments = segments[:len(segments)-1]
+
+	// keep segments that are fully inside the range only
+	var selected []*recordstore.Segment
+	for _, seg := range segments {
+		if !seg.Start.Before(start) && seg.Start.Before(end) {
+			selected = append(selected, seg)
+		}
+	}
+
+	parsed, err := parseSegments(selected)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	out := []compactEntry{}
+
+	for _, run := range compactRuns(selected, parsed, maxDuration) {
+		last := parsed[run[len(run)-1]]
+		if last.start.Add(last.duration).After(end) {
+			run = run[:len(run)-1]
+			if len(run) < 2 {
+				continue
+			}
+			last = parsed[run[len(run)-1]]
+		}
+
+		runSegments := make([]*recordstore.Segment, len(run))
+		for i, j := range run {
+			runSegments[i] = selected[j]
+		}
+
+		duration := last.start.Add(last.duration).Sub(runSegments[0].Start)
+
+		removed, err := compactSegments(pathConf.RecordFormat, runSegments, duration)
+		if err != nil {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		for _, seg := range removed {
+			s.segmentDeleted(pathConf, pathName, seg.Fpath)
+		}
+
+		s.logRequest(ctx, logger.Info, "merged %d segments of path '%s' into %s",
+			len(runSegments), pathName, runSegments[0].Fpath)
+
+		out = append(out, compactEntry{
+			Start:    runSegments[0].Start,
+			Duration: listEntryDuration(duration),
+			Merged:   len(runSegments),
+		})
+	}
+
+	ctx.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,246 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/hooks"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type deleteResult struct {
+	Deleted int `json:"deleted"`
+	Split   int `json:"split"`
+}
+
+// splitSegment removes the part of a segment that is inside the given range.
+// The part before the range is kept in place, while the part after the range
+// is moved into a new segment that starts with a random access point.
+// It returns false when there's nothing that can be removed.
+func splitSegment(
+	recordFormat conf.RecordFormat,
+	pathFormat string,
+	seg *recordstore.Segment,
+	segEnd time.Time,
+	start time.Time,
+	end time.Time,
+) (bool, error) {
+	keepHead := seg.Start.Before(start)
+	keepTail := segEnd.After(end)
+
+	var tailStart time.Time
+	if keepTail {
+		var err error
+		tailStart, err = snapStart(recordFormat, []*recordstore.Segment{seg}, end, "keyframe")
+		if err != nil {
+			return false, err
+		}
+
+		if !tailStart.After(seg.Start) ||
+			(keepHead && !tailStart.After(start)) {
+			return false, nil
+		}
+	}
+
+	var headPath string
+	if keepHead {
+		var err error
+		headPath, err = muxToTempFile(recordFormat, []*recordstore.Segment{seg}, seg.Start, start.Sub(seg.Start),
+			seg.EncryptionKey)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if keepTail {
+		tailPath, err := muxToTempFile(recordFormat, []*recordstore.Segment{seg}, tailStart, segEnd.Sub(tailStart),
+			seg.EncryptionKey)
+		if err != nil {
+			if headPath != "" {
+				os.Remove(headPath)
+			}
+			return false, err
+		}
+
+		err = os.Rename(tailPath, recordstore.Path{Start: tailStart}.Encode(pathFormat))
+		if err != nil {
+			os.Remove(tailPath)
+			if headPath != "" {
+				os.Remove(headPath)
+			}
+			return false, err
+		}
+	}
+
+	if keepHead {
+		err := os.Rename(headPath, seg.Fpath)
+		if err != nil {
+			os.Remove(headPath)
+			return false, err
+		}
+	} else {
+		err := os.Remove(seg.Fpath)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// segmentDeleted is called when a segment file is removed.
+func (s *Server) segmentDeleted(pathConf *conf.Path, pathName string, fpath string) {
+	hooks.OnRecordSegmentDelete(hooks.OnRecordSegmentDeleteParams{
+		Logger:          s,
+		ExternalCmdPool: s.ExternalCmdPool,
+		Conf:            pathConf,
+		PathName:        pathName,
+		SegmentPath:     fpath,
+	})
+}
+
+func (s *Server) onDelete(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDelete, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, ctx.Query("end"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
+		return
+	}
+
+	if !end.After(start) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("end must be after start"))
+		return
+	}
+
+	split := false
+	if raw := ctx.Query("split"); raw != "" {
+		split, err = strconv.ParseBool(raw)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid split: %w", err))
+			return
+		}
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("deletion is supported with the fMP4 format only"))
+		return
+	}
+
+	// segments are managed by an external tool
+	if pathConf.RecordLayout != "" {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("deletion cannot be used with 'recordLayout'"))
+		return
+	}
+
+	holds, err := recordstore.FindHolds(pathConf, pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if recordstore.HoldsOverlap(holds, start, end) {
+		s.writeError(ctx, http.StatusConflict, fmt.Errorf("range is protected by a hold"))
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	// the last segment of the path may still be in the process of being written.
+	segments = segments[:len(segments)-1]
+
+	// skip segments that start after the range
+	for i, seg := range segments {
+		if !seg.Start.Before(end) {
+			segments = segments[:i]
+			break
+		}
+	}
+
+	parsed, err := parseSegments(segments)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	res := deleteResult{}
+
+	for i, seg := range segments {
+		segEnd := seg.Start.Add(parsed[i].duration)
+
+		// segment is outside the range
+		if !segEnd.After(start) || !seg.Start.Before(end) {
+			continue
+		}
+
+		if !seg.Start.Before(start) && !segEnd.After(end) {
+			err = os.Remove(seg.Fpath)
+			if err != nil {
+				s.writeError(ctx, http.StatusInternalServerError, err)
+				return
+			}
+
+			s.segmentDeleted(pathConf, pathName, seg.Fpath)
+
+			res.Deleted++
+			continue
+		}
+
+		if split {
+			var ok bool
+			// remaining parts are written in the same storage of the segment
+			pathFormat := recordstore.SegmentPathFormat(pathConf, pathName, seg.Fpath)
+
+			ok, err = splitSegment(pathConf.RecordFormat, pathFormat, seg, segEnd, start, end)
+			if err != nil {
+				s.writeError(ctx, http.StatusInternalServerError, err)
+				return
+			}
+
+			if ok {
+				res.Split++
+
+				// the segment is replaced by its head, when it is kept, otherwise it is removed
+				if !seg.Start.Before(start) {
+					s.segmentDeleted(pathConf, pathName, seg.Fpath)
+				}
+			}
+		}
+	}
+
+	s.logRequest(ctx, logger.Info, "deleted %d segments and split %d segments of path '%s'",
+		res.Deleted, res.Split, pathName)
+
+	ctx.JSON(http.StatusOK, res)
+}
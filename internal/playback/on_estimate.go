@@ -0,0 +1,160 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// speed at which recordings are expected to be read and muxed, in bytes per second.
+	estimateMuxRate = 100 * 1024 * 1024
+
+	// size of the init or moov header, excluding sample tables.
+	estimateHeaderSize = 1024
+
+	// size of a moof and mdat header and of a traf box.
+	estimatePartOverhead  = 100
+	estimateTrackOverhead = 80
+
+	// size of a trun entry or of a row of sample tables.
+	estimateSampleOverhead = 16
+
+	// size and payload of MPEG-TS packets, and size of a PES header.
+	estimateTSPacketSize     = 188
+	estimateTSPacketPayload  = 184
+	estimateTSSampleOverhead = 20
+)
+
+type estimate struct {
+	Start          time.Time         `json:"start"`
+	Duration       listEntryDuration `json:"duration"`
+	Format         string            `json:"format"`
+	Size           uint64            `json:"size"`
+	GenerationTime listEntryDuration `json:"generationTime"`
+	Throttled      bool              `json:"throttled"`
+}
+
+// estimateSize estimates the size of an export, by adding the overhead of the container
+// to the size of samples, that is computed from part metadata.
+func estimateSize(format string, in *info) uint64 {
+	if format == "ts" {
+		// PAT and PMT
+		size := uint64(2 * estimateTSPacketSize)
+
+		for _, t := range in.Tracks {
+			// each sample starts a new packet, wasting half a packet on average
+			payload := t.stats.size + uint64(t.stats.samples)*(estimateTSSampleOverhead+estimateTSPacketPayload/2)
+			size += (payload / estimateTSPacketPayload) * estimateTSPacketSize
+		}
+
+		return size
+	}
+
+	size := uint64(estimateHeaderSize)
+
+	for _, t := range in.Tracks {
+		size += t.stats.size + uint64(t.stats.samples)*estimateSampleOverhead
+	}
+
+	if format == "fmp4" {
+		parts := uint64(time.Duration(in.Duration)/partDuration) + 1
+		size += parts * (estimatePartOverhead + uint64(len(in.Tracks))*estimateTrackOverhead)
+	}
+
+	return size
+}
+
+func (s *Server) onEstimate(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackList, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	format := ctx.Query("format")
+	switch format {
+	case "":
+		format = "fmp4"
+
+	case "fmp4", "mp4", "ts":
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", format))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := start.Add(duration)
+
+	start, end, ok := s.applyRangePolicy(ctx, pathConf, pathName, start, end)
+	if !ok {
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+	if len(segments) == 0 {
+		s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		return
+	}
+
+	in, err := readInfo(pathConf.RecordFormat, segments, start, end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	size := estimateSize(format, in)
+
+	// downloads are slowed down while live streaming is overloaded
+	throttled := s.LoadPolicy == conf.PlaybackLoadPolicyThrottle && s.load.overloaded.Load()
+	rate := uint64(estimateMuxRate)
+	if throttled {
+		rate = loadThrottleRate
+	}
+
+	ctx.JSON(http.StatusOK, estimate{
+		Start:          in.Start,
+		Duration:       in.Duration,
+		Format:         format,
+		Size:           size,
+		GenerationTime: listEntryDuration(time.Duration(size) * time.Second / time.Duration(rate)),
+		Throttled:      throttled,
+	})
+}
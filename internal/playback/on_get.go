@@ -1,11 +1,14 @@
 package playback
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -17,30 +20,130 @@ import (
 )
 
 type writerWrapper struct {
-	ctx     *gin.Context
-	written bool
+	ctx         *gin.Context
+	out         io.Writer
+	contentType string
+	flush       bool
+	written     bool
+}
+
+func formatContentType(format string) string {
+	if format == "ts" {
+		return "video/mp2t"
+	}
+	return "video/mp4"
 }
 
 func (w *writerWrapper) Write(p []byte) (int, error) {
 	if !w.written {
 		w.written = true
 		w.ctx.Header("Accept-Ranges", "none")
-		w.ctx.Header("Content-Type", "video/mp4")
+		w.ctx.Header("Content-Type", w.contentType)
+		w.ctx.Header("Trailer", "X-Playback-Bytes-Written, X-Playback-Media-Time")
+	}
+
+	n, err := w.out.Write(p)
+
+	// send data to the client immediately instead of waiting for the buffer to fill
+	if err == nil && w.flush {
+		w.ctx.Writer.Flush()
 	}
-	return w.ctx.Writer.Write(p)
+
+	return n, err
+}
+
+// clientAborted checks whether an error has been caused by a client that stopped reading.
+// Besides closed connections, this detects HTTP/2 stream resets and TLS errors,
+// that cancel the context of the request.
+func clientAborted(ctx *gin.Context, err error) bool {
+	var neterr *net.OpError
+	return errors.As(err, &neterr) ||
+		errors.Is(err, context.Canceled) ||
+		ctx.Request.Context().Err() != nil
+}
+
+// writeProgressTrailers reports the progress of the muxer through trailers,
+// that allow clients to check whether the download is complete.
+func writeProgressTrailers(ctx *gin.Context, p muxerProgress) {
+	ctx.Writer.Header().Set("X-Playback-Bytes-Written", strconv.FormatUint(p.bytesWritten, 10))
+	ctx.Writer.Header().Set("X-Playback-Media-Time", strconv.FormatFloat(p.mediaTime.Seconds(), 'f', -1, 64))
 }
 
-func parseDuration(raw string) (time.Duration, error) {
-	// seconds
-	if secs, err := strconv.ParseFloat(raw, 64); err == nil {
-		return time.Duration(secs * float64(time.Second)), nil
+func newMuxer(format string, compat *compatProfile, w io.Writer) (muxer, error) {
+	switch format {
+	case "", "fmp4":
+		return &muxerFMP4{w: w, compat: compat}, nil
+
+	case "mp4":
+		return &muxerMP4{w: w, compat: compat}, nil
+
+	case "ts":
+		return &muxerTS{w: w}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid format: %s", format)
 	}
+}
 
-	// deprecated, golang format
-	return time.ParseDuration(raw)
+// snapStart moves start backward to the nearest random access point
+// or to the beginning of the segment that contains it.
+func snapStart(
+	recordFormat conf.RecordFormat,
+	segments []*recordstore.Segment,
+	start time.Time,
+	snap string,
+) (time.Time, error) {
+	// start is before the first segment: there's nothing before it
+	if snap == "none" || !segments[0].Start.Before(start) {
+		return start, nil
+	}
+
+	if recordFormat != conf.RecordFormatFMP4 {
+		return time.Time{}, fmt.Errorf("MPEG-TS format is not supported yet")
+	}
+
+	if snap == "segment" {
+		return segments[0].Start, nil
+	}
+
+	f, err := segments[0].Open()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	init, _, err := segmentFMP4ReadHeader(f)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var videoTrack *fmp4.InitTrack
+	for _, track := range init.Tracks {
+		if track.Codec.IsVideo() {
+			videoTrack = track
+			break
+		}
+	}
+
+	// without video tracks, every sample is a random access point
+	if videoTrack == nil {
+		return start, nil
+	}
+
+	dts, found, err := segmentFMP4FindSyncSample(f, videoTrack, start.Sub(segments[0].Start))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !found {
+		return segments[0].Start, nil
+	}
+
+	return segments[0].Start.Add(dts), nil
 }
 
 func seekAndMux(
+	ctx context.Context,
 	recordFormat conf.RecordFormat,
 	segments []*recordstore.Segment,
 	start time.Time,
@@ -51,22 +154,28 @@ func seekAndMux(
 		var firstInit *fmp4.Init
 		var segmentEnd time.Time
 
-		f, err := os.Open(segments[0].Fpath)
+		f, err := segments[0].Open()
 		if err != nil {
 			return err
 		}
 		defer f.Close()
 
-		firstInit, _, err = segmentFMP4ReadHeader(f)
+		var headerDuration time.Duration
+		firstInit, headerDuration, err = segmentFMP4ReadHeader(f)
 		if err != nil {
 			return err
 		}
 
 		m.writeInit(firstInit)
 
+		r, err := segmentFMP4PartsReader(f, headerDuration)
+		if err != nil {
+			return err
+		}
+
 		segmentStartOffset := segments[0].Start.Sub(start) // this is negative
 
-		segmentDuration, err := segmentFMP4MuxParts(f, segmentStartOffset, duration, firstInit.Tracks, m)
+		segmentDuration, err := segmentFMP4MuxParts(ctx, r, segmentStartOffset, duration, firstInit.Tracks, m)
 		if err != nil {
 			return err
 		}
@@ -74,14 +183,20 @@ func seekAndMux(
 		segmentEnd = start.Add(segmentDuration)
 
 		for _, seg := range segments[1:] {
-			f, err = os.Open(seg.Fpath)
+			// stop reading from disk when the client goes away
+			err = ctx.Err()
+			if err != nil {
+				return err
+			}
+
+			f, err = seg.Open()
 			if err != nil {
 				return err
 			}
 			defer f.Close()
 
 			var init *fmp4.Init
-			init, _, err = segmentFMP4ReadHeader(f)
+			init, headerDuration, err = segmentFMP4ReadHeader(f)
 			if err != nil {
 				return err
 			}
@@ -90,10 +205,15 @@ func seekAndMux(
 				break
 			}
 
+			r, err = segmentFMP4PartsReader(f, headerDuration)
+			if err != nil {
+				return err
+			}
+
 			segmentStartOffset := seg.Start.Sub(start) // this is positive
 
 			var segmentDuration time.Duration
-			segmentDuration, err = segmentFMP4MuxParts(f, segmentStartOffset, duration, firstInit.Tracks, m)
+			segmentDuration, err = segmentFMP4MuxParts(ctx, r, segmentStartOffset, duration, firstInit.Tracks, m)
 			if err != nil {
 				return err
 			}
@@ -109,73 +229,272 @@ func seekAndMux(
 		return nil
 	}
 
+	// MPEG-TS segments can be concatenated without changes
+	if tm, ok := m.(*muxerTS); ok {
+		return tm.copySegments(ctx, segments)
+	}
+
 	return fmt.Errorf("MPEG-TS format is not supported yet")
 }
 
+// muxToTempFile muxes segments into a temporary fMP4 file,
+// placed in the same directory of the first segment and encrypted with key, if present.
+// The file is meant to be renamed once complete.
+func muxToTempFile(
+	recordFormat conf.RecordFormat,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+	key []byte,
+) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(segments[0].Fpath), ".playback-*.tmp")
+	if err != nil {
+		return "", err
+	}
+
+	w, err := recordstore.NewSegmentFile(f, key)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	err = seekAndMux(context.Background(), recordFormat, segments, start, duration, &muxerFMP4{w: w})
+	w.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
 func (s *Server) onGet(ctx *gin.Context) {
 	pathName := ctx.Query("path")
 
-	if !s.doAuth(ctx, pathName) {
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDownload, pathName) {
 		return
 	}
 
-	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
-	if err != nil {
-		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+	// parameters have been validated by middlewareValidate
+	start := queryValue(ctx, "start").(time.Time)
+	duration := queryValue(ctx, "duration").(time.Duration)
+
+	format, ok := s.negotiateFormat(ctx)
+	if !ok {
 		return
 	}
 
-	duration, err := parseDuration(ctx.Query("duration"))
-	if err != nil {
-		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+	snap, _ := queryValue(ctx, "snap").(string)
+	if snap == "" {
+		snap = "none"
+	}
+
+	split, _ := queryValue(ctx, "split").(bool)
+	plan, _ := queryValue(ctx, "plan").(bool)
+	lowLatency, _ := queryValue(ctx, "lowLatency").(bool)
+
+	// the format may have been negotiated with the Accept header
+	if lowLatency && format != "" && format != "fmp4" {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("lowLatency requires the fmp4 format"))
 		return
 	}
 
-	ww := &writerWrapper{ctx: ctx}
-	var m muxer
+	var redact []redactRange
+	for _, v := range queryArray(ctx, "redact") {
+		redact = append(redact, v.(redactRange))
+	}
 
-	format := ctx.Query("format")
-	switch format {
-	case "", "fmp4":
-		m = &muxerFMP4{w: ww}
+	compat, ok := queryValue(ctx, "compat").(*compatProfile)
+	if !ok {
+		compat = compatProfiles[s.Compat]
+	}
 
-	case "mp4":
-		m = &muxerMP4{w: ww}
+	hs, hreq, ok := s.startHooks(ctx, pathName, format, start, duration)
+	if !ok {
+		return
+	}
+	start, duration = hreq.Start, hreq.Duration
+
+	export := newHookExport(hs, hreq, s.newLoadWriter(s.newStallWriter(ctx, ctx.Writer)))
+	wb := s.newWriteBuffer(export, lowLatency)
+	ww := &writerWrapper{
+		ctx:         ctx,
+		out:         wb,
+		contentType: formatContentType(format),
+		flush:       lowLatency,
+	}
 
-	default:
-		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", format))
+	m, err := newMuxer(format, compat, ww)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
+	if lowLatency {
+		m.(*muxerFMP4).partDuration = lowLatencyPartDuration
+	}
+
 	pathConf, err := s.safeFindPathConf(pathName)
 	if err != nil {
+		// the path may be recorded by a peer
+		if s.federationEnabled(ctx) && s.proxyGetToPeers(ctx, export, start, duration) {
+			return
+		}
+
 		s.writeError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	end := start.Add(duration)
-	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+
+	start, end, ok = s.applyRangePolicy(ctx, pathConf, pathName, start, end)
+	if !ok {
+		return
+	}
+
+	// the record format may have been changed over time
+	segments, err := recordstore.FindSegmentsAllFormats(pathConf, pathName, &start, &end)
 	if err != nil {
 		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
-			s.writeError(ctx, http.StatusNotFound, err)
+			if s.federationEnabled(ctx) && s.proxyGetToPeers(ctx, export, start, end.Sub(start)) {
+				return
+			}
+
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
 		} else {
 			s.writeError(ctx, http.StatusBadRequest, err)
 		}
 		return
 	}
 
-	err = seekAndMux(pathConf.RecordFormat, segments, start, duration, m)
+	segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+	if len(segments) == 0 {
+		s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		return
+	}
+
+	recordFormat, mixed := segmentsFormat(segments)
+
+	// MPEG-TS segments are copied without being demuxed
+	if len(redact) != 0 && (recordFormat != conf.RecordFormatFMP4 || mixed) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("redact cannot be used with MPEG-TS recordings"))
+		return
+	}
+
+	actualStart, err := snapStart(segments[0].Format, segments, start, snap)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	// keep the requested end
+	duration += start.Sub(actualStart)
+	start = actualStart
+
+	var runs []*getSplitRun
+	if split {
+		if mixed {
+			s.writeError(ctx, http.StatusBadRequest,
+				fmt.Errorf("split cannot be used with segments recorded in multiple formats"))
+			return
+		}
+
+		runs, err = splitSegments(recordFormat, segments)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	// the first segment of each run provides the tracks of the output
+	initSegments := segments[:1]
+	if split {
+		initSegments = make([]*recordstore.Segment, len(runs))
+		for i, run := range runs {
+			initSegments[i] = run.segments[0]
+		}
+	}
+
+	incompatible := findIncompatibleTracks(initSegments, format, compat)
+	if len(incompatible) != 0 {
+		if !s.CompatDrop {
+			s.writeIncompatible(ctx, format, compat, incompatible)
+			return
+		}
+
+		s.logRequest(ctx, logger.Debug, "dropping %d tracks that can't be represented in the requested format",
+			len(incompatible))
+		m = &muxerDropIncompatible{m: m, format: format, compat: compat}
+	}
+
+	// offloaded exports are sent without changes
+	canOffload := !split && !lowLatency && len(redact) == 0 && compat == nil && len(hs) == 0
+
+	if plan {
+		s.writeGetPlan(ctx, pathConf, segments, format, start, duration, split, canOffload, incompatible)
+		return
+	}
+
+	if !s.admitRate(ctx) {
+		return
+	}
+
+	if !s.admitLoad(ctx) {
+		return
+	}
+
+	if canOffload && s.offloadSegment(ctx, pathConf, segments, format, start, duration) {
+		return
+	}
+
+	release, ok := s.admitLane(ctx, duration)
+	if !ok {
+		return
+	}
+	defer release()
+
+	memory := estimateMuxMemory(format, coveredDuration(pathConf, segments, start, duration))
+	err = s.budget.acquire(memory)
+	if err != nil {
+		s.writeError(ctx, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer s.budget.release(memory)
+
+	ctx.Header("X-Playback-Actual-Start", start.Format(time.RFC3339Nano))
+
+	if split {
+		s.writeSplit(ctx, export, pathName, runs, recordFormat, start, duration,
+			format, compat, redact)
+		return
+	}
+
+	if len(redact) != 0 {
+		m = &muxerRedact{m: m, start: start, ranges: redact}
+	}
+
+	if mixed {
+		err = seekAndMuxMixed(ctx.Request.Context(), segments, start, duration, m)
+	} else {
+		err = seekAndMux(ctx.Request.Context(), recordFormat, segments, start, duration, m)
+	}
+	if err == nil {
+		err = wb.flush()
+	}
+	if err == nil {
+		err = export.close()
+	}
 	if err != nil {
 		// user aborted the download
-		var neterr *net.OpError
-		if errors.As(err, &neterr) {
+		if clientAborted(ctx, err) {
 			return
 		}
 
 		// nothing has been written yet; send back JSON
 		if !ww.written {
 			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
-				s.writeError(ctx, http.StatusNotFound, err)
+				s.writeNoSegments(ctx, pathConf, pathName, start, end)
 			} else {
 				s.writeError(ctx, http.StatusBadRequest, err)
 			}
@@ -183,7 +502,11 @@ func (s *Server) onGet(ctx *gin.Context) {
 		}
 
 		// something has already been written: abort and write logs only
-		s.Log(logger.Error, err.Error())
+		s.logRequest(ctx, logger.Error, err.Error())
+		wb.flush() //nolint:errcheck
+		writeProgressTrailers(ctx, m.progress())
 		return
 	}
+
+	writeProgressTrailers(ctx, m.progress())
 }
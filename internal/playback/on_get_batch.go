@@ -0,0 +1,326 @@
+package playback
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	getBatchMaxItems     = 128
+	getBatchMaxBodySize  = 1024 * 1024
+	getBatchManifestName = "manifest.json"
+)
+
+// requestDuration is a duration passed inside a JSON request body.
+// It can be expressed in seconds or in Golang format.
+type requestDuration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *requestDuration) UnmarshalJSON(b []byte) error {
+	var secs float64
+	if err := json.Unmarshal(b, &secs); err == nil {
+		*d = requestDuration(secs * float64(time.Second))
+		return nil
+	}
+
+	var in string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return fmt.Errorf("invalid duration")
+	}
+
+	v, err := parseDuration(in)
+	if err != nil {
+		return err
+	}
+
+	*d = requestDuration(v)
+	return nil
+}
+
+type getBatchItem struct {
+	Path     string          `json:"path"`
+	Start    time.Time       `json:"start"`
+	Duration requestDuration `json:"duration"`
+	Format   string          `json:"format"`
+}
+
+type getBatchRequest struct {
+	Items    []getBatchItem `json:"items"`
+	Manifest bool           `json:"manifest"`
+}
+
+type getBatchManifestItem struct {
+	Name   string       `json:"name"`
+	Path   string       `json:"path"`
+	Start  time.Time    `json:"start"`
+	End    time.Time    `json:"end"`
+	Format string       `json:"format"`
+	Tracks []*infoTrack `json:"tracks"`
+	Size   uint64       `json:"size"`
+	SHA256 string       `json:"sha256"`
+}
+
+type getBatchManifest struct {
+	ServerVersion string                  `json:"serverVersion"`
+	Created       time.Time               `json:"created"`
+	Items         []*getBatchManifestItem `json:"items"`
+}
+
+type getBatchEntry struct {
+	item         getBatchItem
+	hookRequest  *HookRequest
+	recordFormat conf.RecordFormat
+	segments     []*recordstore.Segment
+	info         *info
+}
+
+func getBatchEntryName(i int, item getBatchItem) string {
+	ext := "mp4"
+	if item.Format == "ts" {
+		ext = "ts"
+	}
+
+	return fmt.Sprintf("%03d_%s_%s.%s",
+		i+1,
+		strings.ReplaceAll(item.Path, "/", "_"),
+		item.Start.UTC().Format("2006-01-02_15-04-05.000000"),
+		ext)
+}
+
+func writeGetBatchManifest(zw *zip.Writer, manifest *getBatchManifest) error {
+	buf, _ := json.MarshalIndent(manifest, "", "  ")
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     getBatchManifestName,
+		Method:   zip.Deflate,
+		Modified: manifest.Created,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
+
+func (s *Server) onGetBatch(ctx *gin.Context) {
+	var req getBatchRequest
+	err := json.NewDecoder(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, getBatchMaxBodySize)).Decode(&req)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	if len(req.Items) == 0 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("no items provided"))
+		return
+	}
+
+	if len(req.Items) > getBatchMaxItems {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("too many items (maximum is %d)", getBatchMaxItems))
+		return
+	}
+
+	authenticated := make(map[string]struct{})
+
+	for _, item := range req.Items {
+		if _, ok := authenticated[item.Path]; ok {
+			continue
+		}
+
+		if !s.doAuth(ctx, conf.AuthActionPlaybackExport, item.Path) {
+			return
+		}
+
+		authenticated[item.Path] = struct{}{}
+	}
+
+	entries := make([]*getBatchEntry, len(req.Items))
+	hs := registeredHooks()
+
+	// items are muxed one at a time
+	var memory uint64
+
+	for i, item := range req.Items {
+		if item.Format == "" {
+			item.Format = "mp4"
+		}
+
+		if item.Format != "mp4" && item.Format != "fmp4" {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("item %d: invalid format: %s", i, item.Format))
+			return
+		}
+
+		if item.Duration <= 0 {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("item %d: invalid duration", i))
+			return
+		}
+
+		hreq := newHookRequest(ctx, item.Path, item.Format, item.Start, time.Duration(item.Duration))
+		if !s.runHooks(ctx, hs, hreq) {
+			return
+		}
+		item.Start, item.Duration = hreq.Start, requestDuration(hreq.Duration)
+
+		pathConf, err := s.safeFindPathConf(item.Path)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("item %d: %w", i, err))
+			return
+		}
+
+		end := item.Start.Add(time.Duration(item.Duration))
+		segments, err := recordstore.FindSegments(pathConf, item.Path, &item.Start, &end)
+		if err != nil {
+			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				s.writeError(ctx, http.StatusNotFound, fmt.Errorf("item %d: %w", i, err))
+			} else {
+				s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("item %d: %w", i, err))
+			}
+			return
+		}
+
+		covered := coveredDuration(pathConf, segments, item.Start, time.Duration(item.Duration))
+		if m := estimateMuxMemory(item.Format, covered); m > memory {
+			memory = m
+		}
+
+		entries[i] = &getBatchEntry{
+			item:         item,
+			hookRequest:  hreq,
+			recordFormat: pathConf.RecordFormat,
+			segments:     segments,
+		}
+
+		// codecs and timestamps are read before the archive is started, in order to be able to return errors
+		if req.Manifest {
+			entries[i].info, err = readInfo(pathConf.RecordFormat, segments, item.Start, end)
+			if err != nil {
+				if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+					s.writeError(ctx, http.StatusNotFound, fmt.Errorf("item %d: %w", i, err))
+				} else {
+					s.writeError(ctx, http.StatusInternalServerError, fmt.Errorf("item %d: %w", i, err))
+				}
+				return
+			}
+		}
+	}
+
+	if !s.admitRate(ctx) {
+		return
+	}
+
+	if !s.admitLoad(ctx) {
+		return
+	}
+
+	var totalDuration time.Duration
+	for _, entry := range entries {
+		totalDuration += time.Duration(entry.item.Duration)
+	}
+
+	release, ok := s.admitLane(ctx, totalDuration)
+	if !ok {
+		return
+	}
+	defer release()
+
+	err = s.budget.acquire(memory)
+	if err != nil {
+		s.writeError(ctx, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer s.budget.release(memory)
+
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", `attachment; filename="recordings.zip"`)
+	ctx.Status(http.StatusOK)
+
+	zw := zip.NewWriter(s.newLoadWriter(s.newStallWriter(ctx, ctx.Writer)))
+
+	manifest := &getBatchManifest{
+		ServerVersion: s.Version,
+		Created:       time.Now(),
+		Items:         []*getBatchManifestItem{},
+	}
+
+	for i, entry := range entries {
+		name := getBatchEntryName(i, entry.item)
+
+		zf, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Store, // recordings are already compressed
+			Modified: entry.item.Start,
+		})
+		if err != nil {
+			s.logRequest(ctx, logger.Error, err.Error())
+			return
+		}
+
+		// hashes are computed while items are sent
+		h := sha256.New()
+		w := &countingWriter{w: io.MultiWriter(zf, h)}
+		export := newHookExport(hs, entry.hookRequest, w)
+
+		m, _ := newMuxer(entry.item.Format, compatProfiles[s.Compat], export)
+
+		err = seekAndMux(ctx.Request.Context(), entry.recordFormat, entry.segments,
+			entry.item.Start, time.Duration(entry.item.Duration), m)
+		if err == nil {
+			err = export.close()
+		}
+		if err != nil {
+			// user aborted the download
+			if clientAborted(ctx, err) {
+				return
+			}
+
+			// the archive has already been started: abort and write logs only
+			s.logRequest(ctx, logger.Error, "item %d: %v", i, err)
+			return
+		}
+
+		if req.Manifest {
+			to := contiguousEnd(entry.info.Segments)
+			if end := entry.item.Start.Add(time.Duration(entry.item.Duration)); to.After(end) {
+				to = end
+			}
+
+			manifest.Items = append(manifest.Items, &getBatchManifestItem{
+				Name:   name,
+				Path:   entry.item.Path,
+				Start:  entry.info.Start,
+				End:    to,
+				Format: entry.item.Format,
+				Tracks: entry.info.Tracks,
+				Size:   w.n,
+				SHA256: hex.EncodeToString(h.Sum(nil)),
+			})
+		}
+	}
+
+	if req.Manifest {
+		err = writeGetBatchManifest(zw, manifest)
+		if err != nil {
+			s.logRequest(ctx, logger.Error, err.Error())
+			return
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		s.logRequest(ctx, logger.Error, err.Error())
+	}
+}
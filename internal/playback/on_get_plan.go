@@ -0,0 +1,165 @@
+package playback
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type getPlanSegment struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+
+	// position inside the segment from which samples are read
+	Offset listEntryDuration `json:"offset"`
+}
+
+type getPlanBreakpoint struct {
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+// getPlan describes how a /get request would be handled.
+type getPlan struct {
+	Start         time.Time           `json:"start"`
+	Duration      listEntryDuration   `json:"duration"`
+	Format        string              `json:"format"`
+	Method        string              `json:"method"`
+	Split         bool                `json:"split"`
+	Segments      []getPlanSegment    `json:"segments"`
+	Breakpoints   []getPlanBreakpoint `json:"breakpoints"`
+	DroppedTracks []incompatibleTrack `json:"droppedTracks"`
+}
+
+// breakpointReason returns why a segment can't be concatenated to the previous ones,
+// reproducing the checks of segmentFMP4CanBeConcatenated.
+func breakpointReason(prevInit *fmp4.Init, prevEnd time.Time, curInit *fmp4.Init, curStart time.Time) string {
+	switch {
+	case !initAreCompatible(prevInit, curInit):
+		return "tracks changed"
+
+	case curStart.Before(prevEnd.Add(-concatenationTolerance)):
+		return "overlap"
+
+	case curStart.After(prevEnd.Add(concatenationTolerance)):
+		return "gap"
+	}
+
+	return ""
+}
+
+// planSegments returns the segments that would be muxed and the points
+// in which concatenation stops. Without split, the output ends at the first breakpoint,
+// while with split, each breakpoint begins a new file.
+func planSegments(
+	segments []*recordstore.Segment,
+	start time.Time,
+	end time.Time,
+	split bool,
+) ([]getPlanSegment, []getPlanBreakpoint, time.Duration, error) {
+	out := []getPlanSegment{}
+	breakpoints := []getPlanBreakpoint{}
+	var total time.Duration
+
+	var firstInit *fmp4.Init
+	var prevEnd time.Time
+
+	for _, seg := range segments {
+		parsed, err := parseSegment(seg)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		if firstInit != nil {
+			reason := breakpointReason(firstInit, prevEnd, parsed.init, seg.Start)
+			if reason != "" {
+				breakpoints = append(breakpoints, getPlanBreakpoint{Time: seg.Start, Reason: reason})
+				if !split {
+					break
+				}
+				firstInit = nil
+			}
+		}
+
+		if firstInit == nil {
+			firstInit = parsed.init
+		}
+
+		segEnd := seg.Start.Add(parsed.duration)
+		prevEnd = segEnd
+
+		var offset time.Duration
+		if start.After(seg.Start) {
+			offset = min(start.Sub(seg.Start), parsed.duration)
+		}
+
+		out = append(out, getPlanSegment{
+			Start:    seg.Start,
+			Duration: listEntryDuration(parsed.duration),
+			Offset:   listEntryDuration(offset),
+		})
+
+		// portion of the segment inside the requested window
+		if segEnd.After(end) {
+			segEnd = end
+		}
+		if d := segEnd.Sub(seg.Start.Add(offset)); d > 0 {
+			total += d
+		}
+	}
+
+	return out, breakpoints, total, nil
+}
+
+// writeGetPlan replies with the plan of a /get request instead of media.
+func (s *Server) writeGetPlan(
+	ctx *gin.Context,
+	pathConf *conf.Path,
+	segments []*recordstore.Segment,
+	format string,
+	start time.Time,
+	duration time.Duration,
+	split bool,
+	canOffload bool,
+	dropped []*infoTrack,
+) {
+	for _, seg := range segments {
+		if seg.Format != conf.RecordFormatFMP4 {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("MPEG-TS format is not supported yet"))
+			return
+		}
+	}
+
+	planned, breakpoints, total, err := planSegments(segments, start, start.Add(duration), split)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if format == "" {
+		format = "fmp4"
+	}
+
+	method := "remux"
+	if canOffload {
+		if _, _, ok := s.offloadableSegment(ctx, pathConf, segments, format, start, duration); ok {
+			method = "offload"
+		}
+	}
+
+	ctx.JSON(http.StatusOK, getPlan{
+		Start:         start,
+		Duration:      listEntryDuration(total),
+		Format:        format,
+		Method:        method,
+		Split:         split,
+		Segments:      planned,
+		Breakpoints:   breakpoints,
+		DroppedTracks: newIncompatibleTracks(dropped),
+	})
+}
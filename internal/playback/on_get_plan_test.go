@@ -0,0 +1,151 @@
+package playback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnGetPlan(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-10-000000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	type planSegment struct {
+		Start    time.Time `json:"start"`
+		Duration float64   `json:"duration"`
+		Offset   float64   `json:"offset"`
+	}
+
+	type plan struct {
+		Start       time.Time           `json:"start"`
+		Duration    float64             `json:"duration"`
+		Format      string              `json:"format"`
+		Method      string              `json:"method"`
+		Split       bool                `json:"split"`
+		Segments    []planSegment       `json:"segments"`
+		Breakpoints []getPlanBreakpoint `json:"breakpoints"`
+	}
+
+	start := time.Date(2008, 11, 0o7, 11, 23, 3, 500000000, time.Local)
+	second := time.Date(2008, 11, 0o7, 11, 23, 10, 0, time.Local)
+
+	for _, ca := range []struct {
+		name  string
+		split bool
+		out   plan
+	}{
+		{
+			"single",
+			false,
+			plan{
+				Start:    start,
+				Duration: 3,
+				Format:   "mp4",
+				Method:   "remux",
+				Segments: []planSegment{{
+					Start:    time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local),
+					Duration: 4,
+					Offset:   1,
+				}},
+				Breakpoints: []getPlanBreakpoint{{Time: second, Reason: "gap"}},
+			},
+		},
+		{
+			"split",
+			true,
+			plan{
+				Start:    start,
+				Duration: 6.5,
+				Format:   "mp4",
+				Method:   "remux",
+				Split:    true,
+				Segments: []planSegment{
+					{
+						Start:    time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local),
+						Duration: 4,
+						Offset:   1,
+					},
+					{
+						Start:    second,
+						Duration: 4,
+					},
+				},
+				Breakpoints: []getPlanBreakpoint{{Time: second, Reason: "gap"}},
+			},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			tr := &http.Transport{}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			v := url.Values{}
+			v.Set("path", "mypath")
+			v.Set("start", start.Format(time.RFC3339Nano))
+			v.Set("duration", "10")
+			v.Set("format", "mp4")
+			v.Set("plan", "true")
+			if ca.split {
+				v.Set("split", "true")
+			}
+
+			res, err := hc.Get("http://localhost:9996/get?" + v.Encode())
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.Equal(t, "application/json; charset=utf-8", res.Header.Get("Content-Type"))
+
+			var out plan
+			err = json.NewDecoder(res.Body).Decode(&out)
+			require.NoError(t, err)
+
+			require.True(t, ca.out.Start.Equal(out.Start))
+			out.Start = ca.out.Start
+
+			for i := range out.Segments {
+				require.True(t, ca.out.Segments[i].Start.Equal(out.Segments[i].Start))
+				out.Segments[i].Start = ca.out.Segments[i].Start
+			}
+			for i := range out.Breakpoints {
+				require.True(t, ca.out.Breakpoints[i].Time.Equal(out.Breakpoints[i].Time))
+				out.Breakpoints[i].Time = ca.out.Breakpoints[i].Time
+			}
+
+			require.Equal(t, ca.out, out)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package playback
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type getRange struct {
+	Start    time.Time       `json:"start"`
+	Duration requestDuration `json:"duration"`
+}
+
+type getRangesRequest struct {
+	Path   string     `json:"path"`
+	Format string     `json:"format"`
+	Ranges []getRange `json:"ranges"`
+}
+
+type getRangesEntry struct {
+	start    time.Time
+	duration time.Duration
+	segments []*recordstore.Segment
+}
+
+func readFirstInit(segments []*recordstore.Segment) (*fmp4.Init, error) {
+	f, err := segments[0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	init, _, err := segmentFMP4ReadHeader(f)
+	return init, err
+}
+
+// onGetRanges stitches multiple ranges of a path into a single file.
+func (s *Server) onGetRanges(ctx *gin.Context) {
+	var req getRangesRequest
+	err := json.NewDecoder(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, getBatchMaxBodySize)).Decode(&req)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDownload, req.Path) {
+		return
+	}
+
+	if len(req.Ranges) == 0 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("no ranges provided"))
+		return
+	}
+
+	if len(req.Ranges) > getBatchMaxItems {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("too many ranges (maximum is %d)", getBatchMaxItems))
+		return
+	}
+
+	hs := registeredHooks()
+	var exportReq *HookRequest
+
+	for i, r := range req.Ranges {
+		hreq := newHookRequest(ctx, req.Path, req.Format, r.Start, time.Duration(r.Duration))
+		if !s.runHooks(ctx, hs, hreq) {
+			return
+		}
+		req.Ranges[i].Start, req.Ranges[i].Duration = hreq.Start, requestDuration(hreq.Duration)
+
+		// the export spans from the first range to the last one
+		if exportReq == nil {
+			exportReq = hreq
+		} else {
+			exportEnd := exportReq.Start.Add(exportReq.Duration)
+			if end := hreq.Start.Add(hreq.Duration); end.After(exportEnd) {
+				exportEnd = end
+			}
+			if hreq.Start.Before(exportReq.Start) {
+				exportReq.Start = hreq.Start
+			}
+			exportReq.Duration = exportEnd.Sub(exportReq.Start)
+		}
+	}
+
+	export := newHookExport(hs, exportReq, s.newLoadWriter(s.newStallWriter(ctx, ctx.Writer)))
+	ww := &writerWrapper{
+		ctx:         ctx,
+		out:         export,
+		contentType: formatContentType(req.Format),
+	}
+
+	m, err := newMuxer(req.Format, compatProfiles[s.Compat], ww)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(req.Path)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("MPEG-TS format is not supported yet"))
+		return
+	}
+
+	entries := make([]*getRangesEntry, len(req.Ranges))
+	var firstInit *fmp4.Init
+	var totalDuration time.Duration
+	var coveredTotal time.Duration
+
+	for i, r := range req.Ranges {
+		if r.Duration <= 0 {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("range %d: invalid duration", i))
+			return
+		}
+
+		end := r.Start.Add(time.Duration(r.Duration))
+		segments, err2 := recordstore.FindSegments(pathConf, req.Path, &r.Start, &end)
+		if err2 != nil {
+			if errors.Is(err2, recordstore.ErrNoSegmentsFound) {
+				s.writeError(ctx, http.StatusNotFound, fmt.Errorf("range %d: %w", i, err2))
+			} else {
+				s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("range %d: %w", i, err2))
+			}
+			return
+		}
+
+		// ranges must start with a random access point
+		start, err2 := snapStart(pathConf.RecordFormat, segments, r.Start, "keyframe")
+		if err2 != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("range %d: %w", i, err2))
+			return
+		}
+
+		init, err2 := readFirstInit(segments)
+		if err2 != nil {
+			s.writeError(ctx, http.StatusInternalServerError, fmt.Errorf("range %d: %w", i, err2))
+			return
+		}
+
+		if firstInit == nil {
+			firstInit = init
+		} else if !initAreCompatible(firstInit, init) {
+			s.writeError(ctx, http.StatusBadRequest,
+				fmt.Errorf("range %d: tracks are different from the ones of the first range", i))
+			return
+		}
+
+		entries[i] = &getRangesEntry{
+			start:    start,
+			duration: end.Sub(start),
+			segments: segments,
+		}
+		totalDuration += entries[i].duration
+		coveredTotal += coveredDuration(pathConf, segments, start, entries[i].duration)
+	}
+
+	if !s.admitRate(ctx) {
+		return
+	}
+
+	if !s.admitLoad(ctx) {
+		return
+	}
+
+	release, ok := s.admitLane(ctx, totalDuration)
+	if !ok {
+		return
+	}
+	defer release()
+
+	memory := estimateMuxMemory(req.Format, coveredTotal)
+	err = s.budget.acquire(memory)
+	if err != nil {
+		s.writeError(ctx, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer s.budget.release(memory)
+
+	st := &muxerStitch{m: m}
+
+	for _, entry := range entries {
+		err = seekAndMux(ctx.Request.Context(), pathConf.RecordFormat, entry.segments, entry.start, entry.duration, st)
+		if err != nil {
+			break
+		}
+		st.nextRange()
+	}
+
+	if err == nil {
+		err = m.flush()
+	}
+	if err == nil {
+		err = export.close()
+	}
+
+	if err != nil {
+		// user aborted the download
+		if clientAborted(ctx, err) {
+			return
+		}
+
+		// nothing has been written yet; send back JSON
+		if !ww.written {
+			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				s.writeError(ctx, http.StatusNotFound, err)
+			} else {
+				s.writeError(ctx, http.StatusBadRequest, err)
+			}
+			return
+		}
+
+		// something has already been written: abort and write logs only
+		s.logRequest(ctx, logger.Error, err.Error())
+		writeProgressTrailers(ctx, m.progress())
+		return
+	}
+
+	writeProgressTrailers(ctx, m.progress())
+}
@@ -0,0 +1,127 @@
+package playback
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// getSplitRun is a sequence of segments that can be concatenated.
+type getSplitRun struct {
+	segments []*recordstore.Segment
+	end      time.Time
+}
+
+// splitSegments groups segments into runs that can be concatenated.
+func splitSegments(recordFormat conf.RecordFormat, segments []*recordstore.Segment) ([]*getSplitRun, error) {
+	if recordFormat != conf.RecordFormatFMP4 {
+		return nil, fmt.Errorf("MPEG-TS format is not supported yet")
+	}
+
+	var runs []*getSplitRun
+	var prevInit *fmp4.Init
+	var prevEnd time.Time
+
+	for _, seg := range segments {
+		parsed, err := parseSegment(seg)
+		if err != nil {
+			return nil, err
+		}
+
+		if prevInit == nil || !segmentFMP4CanBeConcatenated(prevInit, prevEnd, parsed.init, seg.Start) {
+			runs = append(runs, &getSplitRun{})
+		}
+
+		run := runs[len(runs)-1]
+		run.segments = append(run.segments, seg)
+
+		prevInit = parsed.init
+		prevEnd = seg.Start.Add(parsed.duration)
+		run.end = prevEnd
+	}
+
+	return runs, nil
+}
+
+// writeSplit writes a zip archive that contains a file for each run of segments
+// that can be concatenated, covering the entire requested window.
+func (s *Server) writeSplit(
+	ctx *gin.Context,
+	export *hookExport,
+	pathName string,
+	runs []*getSplitRun,
+	recordFormat conf.RecordFormat,
+	start time.Time,
+	duration time.Duration,
+	format string,
+	compat *compatProfile,
+	redact []redactRange,
+) {
+	end := start.Add(duration)
+
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", `attachment; filename="recordings.zip"`)
+	ctx.Status(http.StatusOK)
+
+	zw := zip.NewWriter(export)
+	i := 0
+
+	for _, run := range runs {
+		// the first run may end before the requested window
+		if !run.end.After(start) {
+			continue
+		}
+
+		runStart := start
+		if run.segments[0].Start.After(start) {
+			runStart = run.segments[0].Start
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     getBatchEntryName(i, getBatchItem{Path: pathName, Start: runStart, Format: format}),
+			Method:   zip.Store, // recordings are already compressed
+			Modified: runStart,
+		})
+		if err != nil {
+			s.logRequest(ctx, logger.Error, err.Error())
+			return
+		}
+
+		m, _ := newMuxer(format, compat, w)
+		if s.CompatDrop {
+			m = &muxerDropIncompatible{m: m, format: format, compat: compat}
+		}
+		if len(redact) != 0 {
+			m = &muxerRedact{m: m, start: runStart, ranges: redact}
+		}
+
+		err = seekAndMux(ctx.Request.Context(), recordFormat, run.segments, runStart, end.Sub(runStart), m)
+		if err != nil {
+			// user aborted the download
+			if clientAborted(ctx, err) {
+				return
+			}
+
+			// the archive has already been started: abort and write logs only
+			s.logRequest(ctx, logger.Error, "run %d: %v", i, err)
+			return
+		}
+
+		i++
+	}
+
+	err := zw.Close()
+	if err == nil {
+		err = export.close()
+	}
+	if err != nil {
+		s.logRequest(ctx, logger.Error, err.Error())
+	}
+}
@@ -0,0 +1,305 @@
+package playback
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// default interval between data points.
+	grafanaDefaultInterval = 1 * time.Minute
+
+	// maximum number of data points of a time series.
+	grafanaMaxDataPoints = 10000
+)
+
+// metrics that can be queried by the Grafana JSON datasource.
+// targets are in the format metric:path.
+var grafanaMetrics = []string{
+	"coverage", // percentage of each interval that is covered by recordings
+	"bitrate",  // bitrate of recordings, in bit/s
+}
+
+type grafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+type grafanaQueryTarget struct {
+	RefID  string `json:"refId"`
+	Target string `json:"target"`
+}
+
+type grafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int64                `json:"intervalMs"`
+	MaxDataPoints int                  `json:"maxDataPoints"`
+	Targets       []grafanaQueryTarget `json:"targets"`
+}
+
+// grafanaTimeSeries is a time series in the format of the Grafana JSON datasource.
+// Each data point is a value followed by a timestamp in milliseconds.
+type grafanaTimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+func parseGrafanaTarget(target string) (string, string, error) {
+	metric, pathName, ok := strings.Cut(target, ":")
+	if !ok || pathName == "" {
+		return "", "", fmt.Errorf("invalid target '%s', use metric:path", target)
+	}
+
+	for _, m := range grafanaMetrics {
+		if m == metric {
+			return metric, pathName, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("invalid metric '%s', use one of %s", metric, strings.Join(grafanaMetrics, ", "))
+}
+
+// grafanaInterval returns the interval between data points of a query.
+func grafanaInterval(req *grafanaQueryRequest) time.Duration {
+	span := req.Range.To.Sub(req.Range.From)
+
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = grafanaDefaultInterval
+	}
+
+	maxPoints := time.Duration(grafanaMaxDataPoints)
+	if req.MaxDataPoints > 0 && req.MaxDataPoints < grafanaMaxDataPoints {
+		maxPoints = time.Duration(req.MaxDataPoints)
+	}
+
+	// increase the interval until data points fit into the limit
+	if (span+interval-1)/interval > maxPoints {
+		interval = (span + maxPoints - 1) / maxPoints
+	}
+
+	return interval
+}
+
+// coverageDatapoints computes the percentage of each interval that is covered by entries.
+func coverageDatapoints(entries []listEntry, start time.Time, end time.Time, interval time.Duration) [][2]float64 {
+	count := int((end.Sub(start) + interval - 1) / interval)
+	out := make([][2]float64, count)
+
+	for i := range out {
+		bucketStart := start.Add(time.Duration(i) * interval)
+		bucketEnd := bucketStart.Add(interval)
+		if bucketEnd.After(end) {
+			bucketEnd = end
+		}
+
+		var covered time.Duration
+
+		for _, e := range entries {
+			entryStart := e.Start
+			entryEnd := e.Start.Add(time.Duration(e.Duration))
+
+			if entryStart.Before(bucketStart) {
+				entryStart = bucketStart
+			}
+			if entryEnd.After(bucketEnd) {
+				entryEnd = bucketEnd
+			}
+
+			if entryEnd.After(entryStart) {
+				covered += entryEnd.Sub(entryStart)
+			}
+		}
+
+		out[i] = [2]float64{
+			float64(covered) * 100 / float64(bucketEnd.Sub(bucketStart)),
+			float64(bucketStart.UnixMilli()),
+		}
+	}
+
+	return out
+}
+
+// bitrateDatapoints computes the bitrate of all tracks of segments during each interval.
+func bitrateDatapoints(
+	segments []*recordstore.Segment,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+) ([][2]float64, error) {
+	count := int((end.Sub(start) + interval - 1) / interval)
+	out := make([][2]float64, count)
+
+	for i := range out {
+		out[i][1] = float64(start.Add(time.Duration(i) * interval).UnixMilli())
+	}
+
+	if len(segments) == 0 {
+		return out, nil
+	}
+
+	st, err := readStats(segments, start, end, interval)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return out, nil
+		}
+		return nil, err
+	}
+
+	for _, t := range st.Tracks {
+		for i, b := range t.Buckets {
+			out[i][0] += b.Bitrate
+		}
+	}
+
+	return out, nil
+}
+
+func (s *Server) grafanaQueryTarget(
+	metric string,
+	pathName string,
+	start time.Time,
+	end time.Time,
+	interval time.Duration,
+) ([][2]float64, int, error) {
+	switch metric {
+	case "coverage":
+		entries, _, status, err := s.listLocal(pathName, &start, &end)
+		if err != nil {
+			// intervals without recordings have a coverage of zero
+			if status != http.StatusNotFound {
+				return nil, status, err
+			}
+			entries = nil
+		}
+
+		return coverageDatapoints(entries, start, end, interval), http.StatusOK, nil
+
+	default: // bitrate
+		pathConf, err := s.safeFindPathConf(pathName)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+
+		if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+			return nil, http.StatusBadRequest, fmt.Errorf("MPEG-TS format is not supported yet")
+		}
+
+		segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+		if err != nil && !errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return nil, http.StatusBadRequest, err
+		}
+
+		segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+
+		out, err := bitrateDatapoints(segments, start, end, interval)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		return out, http.StatusOK, nil
+	}
+}
+
+// onGrafanaTest allows the Grafana JSON datasource to test the connection.
+func (s *Server) onGrafanaTest(ctx *gin.Context) {
+	if !s.doAuth(ctx, conf.AuthActionAPI, "") {
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (s *Server) onGrafanaSearch(ctx *gin.Context) {
+	if !s.doAuth(ctx, conf.AuthActionAPI, "") {
+		return
+	}
+
+	var req grafanaSearchRequest
+
+	// the body is optional
+	err := json.NewDecoder(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, getBatchMaxBodySize)).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	s.mutex.RLock()
+	pathNames := recordstore.FindAllPathsWithSegments(s.PathConfs)
+	s.mutex.RUnlock()
+
+	out := []string{}
+
+	for _, metric := range grafanaMetrics {
+		for _, pathName := range pathNames {
+			target := metric + ":" + pathName
+			if strings.Contains(target, req.Target) {
+				out = append(out, target)
+			}
+		}
+	}
+
+	sort.Strings(out)
+
+	ctx.JSON(http.StatusOK, out)
+}
+
+func (s *Server) onGrafanaQuery(ctx *gin.Context) {
+	var req grafanaQueryRequest
+	err := json.NewDecoder(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, getBatchMaxBodySize)).Decode(&req)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	if !req.Range.To.After(req.Range.From) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("range end must be after range start"))
+		return
+	}
+
+	interval := grafanaInterval(&req)
+
+	out := make([]grafanaTimeSeries, 0, len(req.Targets))
+
+	for _, t := range req.Targets {
+		// targets that are being edited are empty
+		if t.Target == "" {
+			continue
+		}
+
+		metric, pathName, err := parseGrafanaTarget(t.Target)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+
+		if !s.doAuth(ctx, conf.AuthActionPlaybackList, pathName) {
+			return
+		}
+
+		datapoints, status, err := s.grafanaQueryTarget(metric, pathName, req.Range.From, req.Range.To, interval)
+		if err != nil {
+			s.writeError(ctx, status, err)
+			return
+		}
+
+		out = append(out, grafanaTimeSeries{
+			Target:     t.Target,
+			Datapoints: datapoints,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,146 @@
+package playback
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrafanaInterval(t *testing.T) {
+	from := time.Date(2008, 11, 0o7, 11, 0, 0, 0, time.UTC)
+
+	for _, ca := range []struct {
+		name          string
+		span          time.Duration
+		intervalMs    int64
+		maxDataPoints int
+		out           time.Duration
+	}{
+		{"default", time.Hour, 0, 0, time.Minute},
+		{"interval", time.Hour, 5000, 0, 5 * time.Second},
+		{"max data points", time.Hour, 1000, 60, time.Minute},
+		{"limit", 100 * 24 * time.Hour, 1000, 0, 864 * time.Second},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			var req grafanaQueryRequest
+			req.Range.From = from
+			req.Range.To = from.Add(ca.span)
+			req.IntervalMs = ca.intervalMs
+			req.MaxDataPoints = ca.maxDataPoints
+			require.Equal(t, ca.out, grafanaInterval(&req))
+		})
+	}
+}
+
+func TestOnGrafana(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	t.Run("test", func(t *testing.T) {
+		res, err := hc.Get("http://localhost:9996/grafana")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		res, err := hc.Post("http://localhost:9996/grafana/search", "application/json",
+			bytes.NewReader([]byte(`{"target":"my"}`)))
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out []string
+		err = json.NewDecoder(res.Body).Decode(&out)
+		require.NoError(t, err)
+		require.Equal(t, []string{"bitrate:mypath", "coverage:mypath"}, out)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		start := time.Date(2008, 11, 0o7, 11, 23, 0, 0, time.Local)
+
+		byts, err := json.Marshal(map[string]interface{}{
+			"range": map[string]interface{}{
+				"from": start,
+				"to":   start.Add(10 * time.Second),
+			},
+			"intervalMs": 5000,
+			"targets": []map[string]interface{}{
+				{"refId": "A", "target": "coverage:mypath"},
+				{"refId": "B", "target": "bitrate:mypath"},
+			},
+		})
+		require.NoError(t, err)
+
+		res, err := hc.Post("http://localhost:9996/grafana/query", "application/json", bytes.NewReader(byts))
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out []grafanaTimeSeries
+		err = json.NewDecoder(res.Body).Decode(&out)
+		require.NoError(t, err)
+		require.Len(t, out, 2)
+
+		ms := float64(start.UnixMilli())
+
+		require.Equal(t, grafanaTimeSeries{
+			Target:     "coverage:mypath",
+			Datapoints: [][2]float64{{50, ms}, {30, ms + 5000}},
+		}, out[0])
+
+		require.Equal(t, "bitrate:mypath", out[1].Target)
+		require.Len(t, out[1].Datapoints, 2)
+		require.Greater(t, out[1].Datapoints[0][0], float64(0))
+		require.Equal(t, ms+5000, out[1].Datapoints[1][1])
+	})
+
+	t.Run("invalid target", func(t *testing.T) {
+		res, err := hc.Post("http://localhost:9996/grafana/query", "application/json",
+			bytes.NewReader([]byte(`{"range":{"from":"2008-11-07T11:23:00Z","to":"2008-11-07T11:24:00Z"},`+
+				`"targets":[{"target":"files:mypath"}]}`)))
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
@@ -0,0 +1,120 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (s *Server) onHoldsList(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackList, pathName) {
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	holds, err := recordstore.FindHolds(pathConf, pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, holds)
+}
+
+func (s *Server) onHoldsAdd(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDelete, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, ctx.Query("end"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
+		return
+	}
+
+	if !end.After(start) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("end must be after start"))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	hold := &recordstore.Hold{
+		ID:      uuid.New(),
+		Path:    pathName,
+		Start:   start,
+		End:     end,
+		Reason:  ctx.Query("reason"),
+		Created: time.Now(),
+	}
+
+	err = recordstore.AddHold(pathConf, hold)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.logRequest(ctx, logger.Info, "added hold %s to path '%s'", hold.ID, pathName)
+
+	ctx.JSON(http.StatusOK, hold)
+}
+
+func (s *Server) onHoldsRemove(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDelete, pathName) {
+		return
+	}
+
+	id, err := uuid.Parse(ctx.Query("id"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid id: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	err = recordstore.RemoveHold(pathConf, pathName, id)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrHoldNotFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	s.logRequest(ctx, logger.Info, "removed hold %s from path '%s'", id, pathName)
+
+	ctx.Status(http.StatusOK)
+}
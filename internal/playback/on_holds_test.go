@@ -0,0 +1,125 @@
+package playback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnHolds(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-06-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	do := func(method string, endpoint string, v url.Values) *http.Response {
+		u := &url.URL{
+			Scheme:   "http",
+			Host:     "localhost:9996",
+			Path:     endpoint,
+			RawQuery: v.Encode(),
+		}
+
+		req, err2 := http.NewRequest(method, u.String(), nil)
+		require.NoError(t, err2)
+
+		res, err2 := hc.Do(req)
+		require.NoError(t, err2)
+
+		return res
+	}
+
+	rangeStart := time.Date(2008, 11, 0o7, 11, 23, 0, 0, time.Local).Format(time.RFC3339)
+	rangeEnd := time.Date(2008, 11, 0o7, 11, 24, 0, 0, time.Local).Format(time.RFC3339)
+
+	res := do(http.MethodPost, "/holds", url.Values{
+		"path":   []string{"mypath"},
+		"start":  []string{rangeStart},
+		"end":    []string{rangeEnd},
+		"reason": []string{"investigation"},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var hold recordstore.Hold
+	err = json.NewDecoder(res.Body).Decode(&hold)
+	require.NoError(t, err)
+	require.Equal(t, "investigation", hold.Reason)
+
+	res = do(http.MethodGet, "/holds", url.Values{"path": []string{"mypath"}})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var holds []*recordstore.Hold
+	err = json.NewDecoder(res.Body).Decode(&holds)
+	require.NoError(t, err)
+	require.Len(t, holds, 1)
+	require.Equal(t, hold.ID, holds[0].ID)
+
+	deleteQuery := url.Values{
+		"path":  []string{"mypath"},
+		"start": []string{rangeStart},
+		"end":   []string{rangeEnd},
+	}
+
+	res = do(http.MethodDelete, "/recordings", deleteQuery)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusConflict, res.StatusCode)
+
+	res = do(http.MethodPost, "/compact", deleteQuery)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusConflict, res.StatusCode)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+	require.NoError(t, err)
+
+	res = do(http.MethodDelete, "/holds", url.Values{
+		"path": []string{"mypath"},
+		"id":   []string{hold.ID.String()},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = do(http.MethodDelete, "/recordings", deleteQuery)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+	require.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type importEntry struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+}
+
+// importOverlaps checks whether existing recordings overlap with the given window.
+func importOverlaps(pathConf *conf.Path, pathName string, start time.Time, end time.Time) (bool, error) {
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) || errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// the first segment may start before the window
+	if segments[0].Start.Before(start) {
+		parsed, err := parseSegment(segments[0])
+		if err != nil {
+			return false, err
+		}
+
+		if !segments[0].Start.Add(parsed.duration).After(start) {
+			segments = segments[1:]
+		}
+	}
+
+	return len(segments) != 0, nil
+}
+
+// importSegment writes an uploaded fMP4 file into a temporary file and remuxes it
+// into a new segment, that is encrypted with the key of the path, if any.
+func importSegment(
+	pathConf *conf.Path,
+	body io.Reader,
+	dir string,
+	start time.Time,
+) (string, time.Duration, error) {
+	f, err := os.CreateTemp(dir, ".playback-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, body)
+	f.Close()
+	if err != nil {
+		return "", 0, err
+	}
+
+	uploaded := &recordstore.Segment{
+		Fpath: f.Name(),
+		Start: start,
+	}
+
+	parsed, err := parseSegment(uploaded)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid fMP4 file: %w", err)
+	}
+
+	tmpPath, err := muxToTempFile(pathConf.RecordFormat, []*recordstore.Segment{uploaded},
+		start, parsed.duration, recordstore.EncryptionKey(pathConf))
+	if err != nil {
+		return "", 0, err
+	}
+
+	return tmpPath, parsed.duration, nil
+}
+
+// onImport stores an external fMP4 file as a segment of a path,
+// making it listable and playable like recorded segments.
+func (s *Server) onImport(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPublish, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	// segments are named with local time, like the ones written by the recorder
+	start = start.Local()
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("import is supported with the fMP4 format only"))
+		return
+	}
+
+	// segments are managed by an external tool
+	if pathConf.RecordLayout != "" {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("import cannot be used with 'recordLayout'"))
+		return
+	}
+
+	pathFormat := recordstore.PathAddExtension(
+		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
+		pathConf.RecordFormat,
+	)
+	fpath := recordstore.Path{Start: start}.Encode(pathFormat)
+
+	err = os.MkdirAll(filepath.Dir(fpath), 0o755)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	tmpPath, duration, err := importSegment(pathConf, ctx.Request.Body, filepath.Dir(fpath), start)
+	if err != nil {
+		if clientAborted(ctx, err) {
+			return
+		}
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	overlaps, err := importOverlaps(pathConf, pathName, start, start.Add(duration))
+	if err != nil {
+		os.Remove(tmpPath)
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if overlaps {
+		os.Remove(tmpPath)
+		s.writeError(ctx, http.StatusConflict, fmt.Errorf("recordings already exist in the requested window"))
+		return
+	}
+
+	err = os.Rename(tmpPath, fpath)
+	if err != nil {
+		os.Remove(tmpPath)
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.logRequest(ctx, logger.Info, "imported segment %s", fpath)
+
+	ctx.JSON(http.StatusOK, importEntry{
+		Start:    start,
+		Duration: listEntryDuration(duration),
+	})
+}
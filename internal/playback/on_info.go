@@ -0,0 +1,302 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type infoTrack struct {
+	ID           int     `json:"id"`
+	Codec        string  `json:"codec"`
+	Width        int     `json:"width,omitempty"`
+	Height       int     `json:"height,omitempty"`
+	FrameRate    float64 `json:"frameRate,omitempty"`
+	SampleRate   int     `json:"sampleRate,omitempty"`
+	ChannelCount int     `json:"channelCount,omitempty"`
+	Bitrate      float64 `json:"bitrate"`
+
+	isVideo bool
+	stats   segmentFMP4TrackStats
+}
+
+type infoSegment struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+	Size     int64             `json:"size"`
+}
+
+type info struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+	Tracks   []*infoTrack      `json:"tracks"`
+	Segments []infoSegment     `json:"segments"`
+}
+
+// newInfoTrack fills codec parameters of a track.
+// Parameters that can't be decoded are left empty.
+func newInfoTrack(id int, codec mp4.Codec) *infoTrack {
+	t := &infoTrack{
+		ID:      id,
+		isVideo: codec.IsVideo(),
+	}
+
+	switch codec := codec.(type) {
+	case *mp4.CodecAV1:
+		t.Codec = "AV1"
+		var sh av1.SequenceHeader
+		if err := sh.Unmarshal(codec.SequenceHeader); err == nil {
+			t.Width = sh.Width()
+			t.Height = sh.Height()
+		}
+
+	case *mp4.CodecVP9:
+		t.Codec = "VP9"
+		t.Width = codec.Width
+		t.Height = codec.Height
+
+	case *mp4.CodecH265:
+		t.Codec = "H265"
+		var sps h265.SPS
+		if err := sps.Unmarshal(codec.SPS); err == nil {
+			t.Width = sps.Width()
+			t.Height = sps.Height()
+		}
+
+	case *mp4.CodecH264:
+		t.Codec = "H264"
+		var sps h264.SPS
+		if err := sps.Unmarshal(codec.SPS); err == nil {
+			t.Width = sps.Width()
+			t.Height = sps.Height()
+		}
+
+	case *mp4.CodecMPEG4Video:
+		t.Codec = "MPEG-4 Video"
+
+	case *mp4.CodecMPEG1Video:
+		t.Codec = "MPEG-1/2 Video"
+
+	case *mp4.CodecMJPEG:
+		t.Codec = "M-JPEG"
+		t.Width = codec.Width
+		t.Height = codec.Height
+
+	case *mp4.CodecOpus:
+		t.Codec = "Opus"
+		t.SampleRate = 48000
+		t.ChannelCount = codec.ChannelCount
+
+	case *mp4.CodecMPEG4Audio:
+		t.Codec = "MPEG-4 Audio"
+		t.SampleRate = codec.SampleRate
+		t.ChannelCount = codec.ChannelCount
+
+	case *mp4.CodecMPEG1Audio:
+		t.Codec = "MPEG-1/2 Audio"
+		t.SampleRate = codec.SampleRate
+		t.ChannelCount = codec.ChannelCount
+
+	case *mp4.CodecAC3:
+		t.Codec = "AC-3"
+		t.SampleRate = codec.SampleRate
+		t.ChannelCount = codec.ChannelCount
+
+	case *mp4.CodecLPCM:
+		t.Codec = "LPCM"
+		t.SampleRate = codec.SampleRate
+		t.ChannelCount = codec.ChannelCount
+	}
+
+	return t
+}
+
+func readInfo(
+	recordFormat conf.RecordFormat,
+	segments []*recordstore.Segment,
+	start time.Time,
+	end time.Time,
+) (*info, error) {
+	if recordFormat != conf.RecordFormatFMP4 {
+		return nil, fmt.Errorf("MPEG-TS format is not supported yet")
+	}
+
+	out := &info{
+		Segments: []infoSegment{},
+	}
+	tracks := make(map[int]*infoTrack)
+	var infoStart, infoEnd time.Time
+
+	for _, seg := range segments {
+		err := func() error {
+			f, err := seg.Open()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			fi, err := os.Stat(seg.Fpath)
+			if err != nil {
+				return err
+			}
+
+			init, duration, err := segmentFMP4ReadHeader(f)
+			if err != nil {
+				return err
+			}
+
+			if duration == 0 {
+				duration, err = segmentFMP4ReadDurationFromParts(f, init)
+				if err != nil {
+					return err
+				}
+			}
+
+			segEnd := seg.Start.Add(duration)
+
+			// segment is in a gap before the requested window
+			if !segEnd.After(start) {
+				return nil
+			}
+
+			out.Segments = append(out.Segments, infoSegment{
+				Start:    seg.Start,
+				Duration: listEntryDuration(duration),
+				Size:     fi.Size(),
+			})
+
+			segStart := seg.Start
+			if segStart.Before(start) {
+				segStart = start
+			}
+			if segEnd.After(end) {
+				segEnd = end
+			}
+			if infoStart.IsZero() {
+				infoStart = segStart
+			}
+			infoEnd = segEnd
+
+			stats, err := segmentFMP4ReadTrackStats(f, init, start.Sub(seg.Start), end.Sub(seg.Start))
+			if err != nil {
+				return err
+			}
+
+			for _, track := range init.Tracks {
+				t, ok := tracks[track.ID]
+				if !ok {
+					t = newInfoTrack(track.ID, track.Codec)
+					tracks[track.ID] = t
+				}
+
+				if ts, ok := stats[track.ID]; ok {
+					t.stats.samples += ts.samples
+					t.stats.size += ts.size
+					t.stats.duration += ts.duration
+				}
+			}
+
+			return nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(out.Segments) == 0 {
+		return nil, recordstore.ErrNoSegmentsFound
+	}
+
+	out.Start = infoStart
+	out.Duration = listEntryDuration(infoEnd.Sub(infoStart))
+
+	out.Tracks = make([]*infoTrack, 0, len(tracks))
+	for _, t := range tracks {
+		if t.stats.duration > 0 {
+			secs := t.stats.duration.Seconds()
+			t.Bitrate = float64(t.stats.size*8) / secs
+			if t.isVideo {
+				t.FrameRate = float64(t.stats.samples) / secs
+			}
+		}
+		out.Tracks = append(out.Tracks, t)
+	}
+
+	sort.Slice(out.Tracks, func(i, j int) bool {
+		return out.Tracks[i].ID < out.Tracks[j].ID
+	})
+
+	return out, nil
+}
+
+func (s *Server) onInfo(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackList, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := start.Add(duration)
+
+	start, end, ok := s.applyRangePolicy(ctx, pathConf, pathName, start, end)
+	if !ok {
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+	if len(segments) == 0 {
+		s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		return
+	}
+
+	out, err := readInfo(pathConf.RecordFormat, segments, start, end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, out)
+}
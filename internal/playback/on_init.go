@@ -0,0 +1,103 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// findInitSegment finds the segment from which a /get request starting at t would read.
+// This is either the segment that contains t, or the first one after t.
+func findInitSegment(segments []*recordstore.Segment, t time.Time) (*parsedSegment, error) {
+	for _, seg := range segments {
+		parsed, err := parseSegment(seg)
+		if err != nil {
+			return nil, err
+		}
+
+		if seg.Start.Add(parsed.duration).After(t) {
+			return parsed, nil
+		}
+	}
+
+	return nil, recordstore.ErrNoSegmentsFound
+}
+
+// onInit returns the initialization section of the segment that covers a time,
+// allowing MSE-based players to set up buffers before requesting media through /get.
+func (s *Server) onInit(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDownload, pathName) {
+		return
+	}
+
+	t, err := time.Parse(time.RFC3339, ctx.Query("time"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid time: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("MPEG-TS format is not supported yet"))
+		return
+	}
+
+	hs, hreq, ok := s.startHooksFixed(ctx, pathName, "fmp4", t)
+	if !ok {
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, &t, nil)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	parsed, err := findInitSegment(segments, t)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	var buf seekablebuffer.Buffer
+	err = parsed.init.Marshal(&buf)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Header("X-Playback-Segment-Start", parsed.start.Format(time.RFC3339Nano))
+	ctx.Header("Content-Type", "video/mp4")
+	ctx.Status(http.StatusOK)
+
+	export := newHookExport(hs, hreq, ctx.Writer)
+	_, err = export.Write(buf.Bytes())
+	if err == nil {
+		err = export.close()
+	}
+	if err != nil && !clientAborted(ctx, err) {
+		s.logRequest(ctx, logger.Error, err.Error())
+	}
+}
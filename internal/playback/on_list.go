@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
 	"strconv"
 	"time"
 
@@ -29,7 +29,7 @@ type parsedSegment struct {
 }
 
 func parseSegment(seg *recordstore.Segment) (*parsedSegment, error) {
-	f, err := os.Open(seg.Fpath)
+	f, err := seg.Open()
 	if err != nil {
 		return nil, err
 	}
@@ -83,10 +83,40 @@ func parseSegments(segments []*recordstore.Segment) ([]*parsedSegment, error) {
 	return parsed, err
 }
 
+type listEntryTrack struct {
+	Codec        string `json:"codec"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	SampleRate   int    `json:"sampleRate,omitempty"`
+	ChannelCount int    `json:"channelCount,omitempty"`
+}
+
 type listEntry struct {
 	Start    time.Time         `json:"start"`
 	Duration listEntryDuration `json:"duration"`
-	URL      string            `json:"url"`
+	Tracks   []listEntryTrack  `json:"tracks"`
+
+	// whether tracks are different from the ones of the previous entry,
+	// that prevents a single download from covering both entries.
+	TracksChanged bool   `json:"tracksChanged"`
+	URL           string `json:"url"`
+}
+
+func newListEntryTracks(init *fmp4.Init) []listEntryTrack {
+	out := make([]listEntryTrack, len(init.Tracks))
+
+	for i, track := range init.Tracks {
+		it := newInfoTrack(track.ID, track.Codec)
+		out[i] = listEntryTrack{
+			Codec:        it.Codec,
+			Width:        it.Width,
+			Height:       it.Height,
+			SampleRate:   it.SampleRate,
+			ChannelCount: it.ChannelCount,
+		}
+	}
+
+	return out
 }
 
 func concatenateSegments(parsed []*parsedSegment) []listEntry {
@@ -104,8 +134,10 @@ func concatenateSegments(parsed []*parsedSegment) []listEntry {
 			out[len(out)-1].Duration = listEntryDuration(curEnd.Sub(prevStart))
 		} else {
 			out = append(out, listEntry{
-				Start:    parsed.start,
-				Duration: listEntryDuration(parsed.duration),
+				Start:         parsed.start,
+				Duration:      listEntryDuration(parsed.duration),
+				Tracks:        newListEntryTracks(parsed.init),
+				TracksChanged: prevInit != nil && !initAreCompatible(prevInit, parsed.init),
 			})
 		}
 
@@ -132,57 +164,56 @@ func parseAndConcatenate(
 	return nil, fmt.Errorf("MPEG-TS format is not supported yet")
 }
 
-func (s *Server) onList(ctx *gin.Context) {
-	pathName := ctx.Query("path")
+// entryURL returns the URL that allows to download an entry.
+func (s *Server) entryURL(ctx *gin.Context, pathName string, e *listEntry) string {
+	v := url.Values{}
+	v.Add("path", pathName)
+	v.Add("start", e.Start.Format(time.RFC3339Nano))
+	v.Add("duration", strconv.FormatFloat(time.Duration(e.Duration).Seconds(), 'f', -1, 64))
+	return s.externalURL(ctx, "/get", v)
+}
 
-	if !s.doAuth(ctx, pathName) {
-		return
-	}
+// pathNotConfiguredError is returned when a path is not configured on this server.
+type pathNotConfiguredError struct {
+	err error
+}
 
-	pathConf, err := s.safeFindPathConf(pathName)
-	if err != nil {
-		s.writeError(ctx, http.StatusBadRequest, err)
-		return
-	}
+func (e pathNotConfiguredError) Error() string {
+	return e.err.Error()
+}
 
-	var start *time.Time
-	rawStart := ctx.Query("start")
-	if rawStart != "" {
-		var tmp time.Time
-		tmp, err = time.Parse(time.RFC3339, rawStart)
-		if err != nil {
-			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
-			return
-		}
-		start = &tmp
-	}
+func (e pathNotConfiguredError) Unwrap() error {
+	return e.err
+}
 
-	var end *time.Time
-	rawEnd := ctx.Query("end")
-	if rawEnd != "" {
-		var tmp time.Time
-		tmp, err = time.Parse(time.RFC3339, rawEnd)
-		if err != nil {
-			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
-			return
-		}
-		end = &tmp
+// listLocal returns timespans of recordings stored by this server,
+// together with the most recent modification time of their segments.
+func (s *Server) listLocal(
+	pathName string,
+	start *time.Time,
+	end *time.Time,
+) ([]listEntry, time.Time, int, error) {
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		return nil, time.Time{}, http.StatusBadRequest, pathNotConfiguredError{err}
 	}
 
 	segments, err := recordstore.FindSegments(pathConf, pathName, start, end)
 	if err != nil {
 		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
-			s.writeError(ctx, http.StatusNotFound, err)
-		} else {
-			s.writeError(ctx, http.StatusBadRequest, err)
+			return nil, time.Time{}, http.StatusNotFound, err
 		}
-		return
+		return nil, time.Time{}, http.StatusBadRequest, err
+	}
+
+	segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+	if len(segments) == 0 {
+		return nil, time.Time{}, http.StatusNotFound, recordstore.ErrNoSegmentsFound
 	}
 
 	entries, err := parseAndConcatenate(pathConf.RecordFormat, segments)
 	if err != nil {
-		s.writeError(ctx, http.StatusInternalServerError, err)
-		return
+		return nil, time.Time{}, http.StatusInternalServerError, err
 	}
 
 	if start != nil {
@@ -196,8 +227,7 @@ func (s *Server) onList(ctx *gin.Context) {
 			entries = entries[1:]
 
 			if len(entries) == 0 {
-				s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
-				return
+				return nil, time.Time{}, http.StatusNotFound, recordstore.ErrNoSegmentsFound
 			}
 		} else if firstEntry.Start.Before(*start) {
 			entries[0].Duration -= listEntryDuration(start.Sub(firstEntry.Start))
@@ -212,26 +242,122 @@ func (s *Server) onList(ctx *gin.Context) {
 		}
 	}
 
-	var scheme string
-	if s.Encryption {
-		scheme = "https"
-	} else {
-		scheme = "http"
+	return entries, segmentsModTime(segments), http.StatusOK, nil
+}
+
+// filterLocalByTag keeps the parts of local recordings that are covered by a tag.
+func (s *Server) filterLocalByTag(
+	pathName string,
+	entries []listEntry,
+	key string,
+	value string,
+) ([]listEntry, int, error) {
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		return nil, http.StatusBadRequest, pathNotConfiguredError{err}
 	}
 
-	for i := range entries {
-		v := url.Values{}
-		v.Add("path", pathName)
-		v.Add("start", entries[i].Start.Format(time.RFC3339Nano))
-		v.Add("duration", strconv.FormatFloat(time.Duration(entries[i].Duration).Seconds(), 'f', -1, 64))
-		u := &url.URL{
-			Scheme:   scheme,
-			Host:     ctx.Request.Host,
-			Path:     "/get",
-			RawQuery: v.Encode(),
+	tags, err := recordstore.FindTags(pathConf, pathName)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	var matching []*recordstore.Tag
+	for _, t := range tags {
+		if t.Matches(key, value) {
+			matching = append(matching, t)
+		}
+	}
+
+	entries = filterEntriesByTags(entries, matching)
+	if len(entries) == 0 {
+		return nil, http.StatusNotFound, recordstore.ErrNoSegmentsFound
+	}
+
+	return entries, http.StatusOK, nil
+}
+
+func (s *Server) onList(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackList, pathName) {
+		return
+	}
+
+	var start *time.Time
+	rawStart := ctx.Query("start")
+	if rawStart != "" {
+		tmp, err := time.Parse(time.RFC3339, rawStart)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+			return
+		}
+		start = &tmp
+	}
+
+	var end *time.Time
+	rawEnd := ctx.Query("end")
+	if rawEnd != "" {
+		tmp, err := time.Parse(time.RFC3339, rawEnd)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
+			return
 		}
-		entries[i].URL = u.String()
+		end = &tmp
+	}
+
+	switch ctx.Query("detail") {
+	case "", "timespans":
+
+	case "segments":
+		s.onListSegments(ctx, pathName, start, end)
+		return
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid detail: %s", ctx.Query("detail")))
+		return
+	}
+
+	var tagKey, tagValue string
+	if raw := ctx.Query("tag"); raw != "" {
+		var err error
+		tagKey, tagValue, err = parseTagFilter(raw)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	entries, modTime, status, err := s.listLocal(pathName, start, end)
+
+	if err == nil && tagKey != "" {
+		entries, status, err = s.filterLocalByTag(pathName, entries, tagKey, tagValue)
+	}
+
+	// recordings of the path may be stored by peers
+	if s.federationEnabled(ctx) &&
+		(err == nil || errors.As(err, &pathNotConfiguredError{}) || errors.Is(err, recordstore.ErrNoSegmentsFound)) {
+		entries = append(entries, s.listPeers(ctx)...)
+
+		// modification time of recordings of peers is unknown
+		modTime = time.Time{}
+
+		if len(entries) == 0 {
+			s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+			return
+		}
+
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Start.Before(entries[j].Start)
+		})
+	} else if err != nil {
+		s.writeError(ctx, status, err)
+		return
+	}
+
+	for i := range entries {
+		entries[i].URL = s.entryURL(ctx, pathName, &entries[i])
 	}
 
-	ctx.JSON(http.StatusOK, entries)
+	s.writeJSONWithValidators(ctx, entries, modTime)
 }
@@ -0,0 +1,134 @@
+package playback
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type listSegment struct {
+	Start    time.Time         `json:"start"`
+	Duration listEntryDuration `json:"duration"`
+	Size     int64             `json:"size"`
+	Format   conf.RecordFormat `json:"format"`
+
+	// whether the segment can be downloaded.
+	// Segments that are being written or that are damaged are not playable.
+	Playable bool             `json:"playable"`
+	Tracks   []listEntryTrack `json:"tracks"`
+	URL      string           `json:"url,omitempty"`
+}
+
+// parseListSegments parses segments in parallel.
+// Segments that can't be parsed are returned as not playable.
+func parseListSegments(recordFormat conf.RecordFormat, segments []*recordstore.Segment) ([]*listSegment, error) {
+	out := make([]*listSegment, len(segments))
+	ch := make(chan error)
+
+	for i, seg := range segments {
+		go func(i int, seg *recordstore.Segment) {
+			fi, err := os.Stat(seg.Fpath)
+			if err != nil {
+				ch <- err
+				return
+			}
+
+			ls := &listSegment{
+				Start:  seg.Start,
+				Size:   fi.Size(),
+				Format: recordFormat,
+				Tracks: []listEntryTrack{},
+			}
+
+			if recordFormat == conf.RecordFormatFMP4 {
+				parsed, err := parseSegment(seg)
+				if err == nil {
+					ls.Duration = listEntryDuration(parsed.duration)
+					ls.Playable = true
+					ls.Tracks = newListEntryTracks(parsed.init)
+				}
+			}
+
+			out[i] = ls
+			ch <- nil
+		}(i, seg)
+	}
+
+	var err error
+
+	for range segments {
+		err2 := <-ch
+		if err2 != nil {
+			err = err2
+		}
+	}
+
+	return out, err
+}
+
+// listLocalSegments returns segments stored by this server, without merging them.
+func (s *Server) listLocalSegments(
+	pathName string,
+	start *time.Time,
+	end *time.Time,
+) ([]*listSegment, time.Time, int, error) {
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		return nil, time.Time{}, http.StatusBadRequest, err
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, start, end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			return nil, time.Time{}, http.StatusNotFound, err
+		}
+		return nil, time.Time{}, http.StatusBadRequest, err
+	}
+
+	out, err := parseListSegments(pathConf.RecordFormat, segments)
+	if err != nil {
+		return nil, time.Time{}, http.StatusInternalServerError, err
+	}
+
+	// the first segment may end before start
+	if start != nil && out[0].Playable && !out[0].Start.Add(time.Duration(out[0].Duration)).After(*start) {
+		out = out[1:]
+
+		if len(out) == 0 {
+			return nil, time.Time{}, http.StatusNotFound, recordstore.ErrNoSegmentsFound
+		}
+	}
+
+	return out, segmentsModTime(segments), http.StatusOK, nil
+}
+
+// onListSegments replies to /list requests with detail=segments.
+// Segments are stored locally, therefore peers are not queried.
+func (s *Server) onListSegments(ctx *gin.Context, pathName string, start *time.Time, end *time.Time) {
+	out, modTime, status, err := s.listLocalSegments(pathName, start, end)
+	if err != nil {
+		s.writeError(ctx, status, err)
+		return
+	}
+
+	for _, seg := range out {
+		if !seg.Playable {
+			continue
+		}
+
+		v := url.Values{}
+		v.Add("path", pathName)
+		v.Add("start", seg.Start.Format(time.RFC3339Nano))
+		v.Add("duration", strconv.FormatFloat(time.Duration(seg.Duration).Seconds(), 'f', -1, 64))
+		seg.URL = s.externalURL(ctx, "/get", v)
+	}
+
+	s.writeJSONWithValidators(ctx, out, modTime)
+}
@@ -2,6 +2,8 @@ package playback
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,6 +24,29 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+var (
+	videoAudioTracks = []interface{}{
+		map[string]interface{}{
+			"codec":  "H264",
+			"width":  float64(1920),
+			"height": float64(1080),
+		},
+		map[string]interface{}{
+			"codec":        "MPEG-4 Audio",
+			"sampleRate":   float64(48000),
+			"channelCount": float64(2),
+		},
+	}
+
+	videoTracks = []interface{}{
+		map[string]interface{}{
+			"codec":  "H264",
+			"width":  float64(1920),
+			"height": float64(1080),
+		},
+	}
+)
+
 func TestOnList(t *testing.T) {
 	for _, ca := range []string{
 		"unfiltered",
@@ -57,7 +83,9 @@ func TestOnList(t *testing.T) {
 			}
 
 			s := &Server{
-				Address:     "127.0.0.1:9996",
+				Playback: conf.Playback{
+					Address: "127.0.0.1:9996",
+				},
 				ReadTimeout: conf.Duration(10 * time.Second),
 				PathConfs: map[string]*conf.Path{
 					"mypath": {
@@ -118,14 +146,18 @@ func TestOnList(t *testing.T) {
 			case "unfiltered", "start before first":
 				require.Equal(t, []interface{}{
 					map[string]interface{}{
-						"duration": float64(66),
-						"start":    time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano),
+						"duration":      float64(66),
+						"start":         time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano),
+						"tracks":        videoAudioTracks,
+						"tracksChanged": false,
 						"url": "http://localhost:9996/get?duration=66&path=mypath&start=" +
 							url.QueryEscape(time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano)),
 					},
 					map[string]interface{}{
-						"duration": float64(4),
-						"start":    time.Date(2009, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano),
+						"duration":      float64(4),
+						"start":         time.Date(2009, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano),
+						"tracks":        videoAudioTracks,
+						"tracksChanged": false,
 						"url": "http://localhost:9996/get?duration=4&path=mypath&start=" +
 							url.QueryEscape(time.Date(2009, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano)),
 					},
@@ -134,14 +166,18 @@ func TestOnList(t *testing.T) {
 			case "filtered":
 				require.Equal(t, []interface{}{
 					map[string]interface{}{
-						"duration": float64(65),
-						"start":    time.Date(2008, 11, 0o7, 11, 22, 1, 500000000, time.Local).Format(time.RFC3339Nano),
+						"duration":      float64(65),
+						"start":         time.Date(2008, 11, 0o7, 11, 22, 1, 500000000, time.Local).Format(time.RFC3339Nano),
+						"tracks":        videoAudioTracks,
+						"tracksChanged": false,
 						"url": "http://localhost:9996/get?duration=65&path=mypath&start=" +
 							url.QueryEscape(time.Date(2008, 11, 0o7, 11, 22, 1, 500000000, time.Local).Format(time.RFC3339Nano)),
 					},
 					map[string]interface{}{
-						"duration": float64(2),
-						"start":    time.Date(2009, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano),
+						"duration":      float64(2),
+						"start":         time.Date(2009, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano),
+						"tracks":        videoAudioTracks,
+						"tracksChanged": false,
 						"url": "http://localhost:9996/get?duration=2&path=mypath&start=" +
 							url.QueryEscape(time.Date(2009, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano)),
 					},
@@ -150,8 +186,10 @@ func TestOnList(t *testing.T) {
 			case "filtered and gap":
 				require.Equal(t, []interface{}{
 					map[string]interface{}{
-						"duration": float64(4),
-						"start":    time.Date(2008, 11, 0o7, 11, 24, 2, 500000000, time.Local).Format(time.RFC3339Nano),
+						"duration":      float64(4),
+						"start":         time.Date(2008, 11, 0o7, 11, 24, 2, 500000000, time.Local).Format(time.RFC3339Nano),
+						"tracks":        videoAudioTracks,
+						"tracksChanged": false,
 						"url": "http://localhost:9996/get?duration=4&path=mypath&start=" +
 							url.QueryEscape(time.Date(2008, 11, 0o7, 11, 24, 2, 500000000, time.Local).Format(time.RFC3339Nano)),
 					},
@@ -160,14 +198,18 @@ func TestOnList(t *testing.T) {
 			case "different init":
 				require.Equal(t, []interface{}{
 					map[string]interface{}{
-						"duration": float64(62),
-						"start":    time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano),
+						"duration":      float64(62),
+						"start":         time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano),
+						"tracks":        videoAudioTracks,
+						"tracksChanged": false,
 						"url": "http://localhost:9996/get?duration=62&path=mypath&start=" +
 							url.QueryEscape(time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano)),
 					},
 					map[string]interface{}{
-						"duration": float64(1),
-						"start":    time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano),
+						"duration":      float64(1),
+						"start":         time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano),
+						"tracks":        videoTracks,
+						"tracksChanged": true,
 						"url": "http://localhost:9996/get?duration=1&path=mypath&start=" +
 							url.QueryEscape(time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano)),
 					},
@@ -276,7 +318,9 @@ func TestOnListCachedDuration(t *testing.T) {
 	}()
 
 	s := &Server{
-		Address:     "127.0.0.1:9996",
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
 		ReadTimeout: conf.Duration(10 * time.Second),
 		PathConfs: map[string]*conf.Path{
 			"mypath": {
@@ -313,10 +357,214 @@ func TestOnListCachedDuration(t *testing.T) {
 
 	require.Equal(t, []interface{}{
 		map[string]interface{}{
-			"duration": float64(50),
-			"start":    time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano),
+			"duration":      float64(50),
+			"start":         time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano),
+			"tracks":        videoTracks,
+			"tracksChanged": false,
 			"url": "http://localhost:9996/get?duration=50&path=mypath&start=" +
 				url.QueryEscape(time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano)),
 		},
 	}, out)
 }
+
+func TestOnListCompression(t *testing.T) {
+	for _, encoding := range []string{
+		"gzip",
+		"deflate",
+	} {
+		t.Run(encoding, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mediamtx-playback")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+			require.NoError(t, err)
+
+			writeSegment1(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-500000.mp4"))
+
+			s := &Server{
+				Playback: conf.Playback{
+					Address: "127.0.0.1:9996",
+				},
+				ReadTimeout: conf.Duration(10 * time.Second),
+				PathConfs: map[string]*conf.Path{
+					"mypath": {
+						Name:       "mypath",
+						RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+					},
+				},
+				AuthManager: test.NilAuthManager,
+				Parent:      test.NilLogger,
+			}
+			err = s.Initialize()
+			require.NoError(t, err)
+			defer s.Close()
+
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:9996/list?path=mypath", nil)
+			require.NoError(t, err)
+
+			req.Header.Set("Accept-Encoding", encoding)
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.Equal(t, encoding, res.Header.Get("Content-Encoding"))
+
+			var r io.Reader
+			if encoding == "gzip" {
+				r, err = gzip.NewReader(res.Body)
+				require.NoError(t, err)
+			} else {
+				r = flate.NewReader(res.Body)
+			}
+
+			var out []interface{}
+			err = json.NewDecoder(r).Decode(&out)
+			require.NoError(t, err)
+			require.Len(t, out, 1)
+		})
+	}
+}
+
+func TestOnListValidators(t *testing.T) {
+	for _, ca := range []string{
+		"etag",
+		"last modified",
+		"changed",
+	} {
+		t.Run(ca, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mediamtx-playback")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+			require.NoError(t, err)
+
+			writeSegment1(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-500000.mp4"))
+
+			s := &Server{
+				Playback: conf.Playback{
+					Address: "127.0.0.1:9996",
+				},
+				ReadTimeout: conf.Duration(10 * time.Second),
+				PathConfs: map[string]*conf.Path{
+					"mypath": {
+						Name:       "mypath",
+						RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+					},
+				},
+				AuthManager: test.NilAuthManager,
+				Parent:      test.NilLogger,
+			}
+			err = s.Initialize()
+			require.NoError(t, err)
+			defer s.Close()
+
+			tr := &http.Transport{}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			list := func(header string, value string) *http.Response {
+				req, err2 := http.NewRequest(http.MethodGet, "http://localhost:9996/list?path=mypath", nil)
+				require.NoError(t, err2)
+
+				if header != "" {
+					req.Header.Set(header, value)
+				}
+
+				res, err2 := hc.Do(req)
+				require.NoError(t, err2)
+				defer res.Body.Close()
+
+				_, err2 = io.ReadAll(res.Body)
+				require.NoError(t, err2)
+
+				return res
+			}
+
+			res := list("", "")
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			// the same ETag is sent with all content codings
+			etag := res.Header.Get("ETag")
+			require.True(t, strings.HasPrefix(etag, `W/"`))
+			require.Contains(t, res.Header.Values("Vary"), "Accept-Encoding")
+
+			lastModified := res.Header.Get("Last-Modified")
+			require.NotEmpty(t, lastModified)
+
+			switch ca {
+			case "etag":
+				res = list("If-None-Match", etag)
+				require.Equal(t, http.StatusNotModified, res.StatusCode)
+				require.Equal(t, etag, res.Header.Get("ETag"))
+
+			case "last modified":
+				res = list("If-Modified-Since", lastModified)
+				require.Equal(t, http.StatusNotModified, res.StatusCode)
+
+			case "changed":
+				writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+				res = list("If-None-Match", etag)
+				require.Equal(t, http.StatusOK, res.StatusCode)
+				require.NotEqual(t, etag, res.Header.Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestOnListSegmentInProgress(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	fpath := filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4")
+	writeSegment2(t, fpath)
+
+	// simulate a part that is being written
+	fi, err := os.Stat(fpath)
+	require.NoError(t, err)
+	err = os.Truncate(fpath, fi.Size()-3)
+	require.NoError(t, err)
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	res, err := hc.Get("http://localhost:9996/list?path=mypath")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var out []map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&out)
+	require.NoError(t, err)
+
+	require.Len(t, out, 1)
+	require.Equal(t, float64(2), out[0]["duration"])
+}
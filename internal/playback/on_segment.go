@@ -0,0 +1,108 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// segmentHookFormat returns the format of a segment that is passed to hooks.
+func segmentHookFormat(recordFormat conf.RecordFormat) string {
+	if recordFormat == conf.RecordFormatMPEGTS {
+		return "ts"
+	}
+	return "fmp4"
+}
+
+func segmentContentType(recordFormat conf.RecordFormat) string {
+	if recordFormat == conf.RecordFormatMPEGTS {
+		return "video/mp2t"
+	}
+	return "video/mp4"
+}
+
+// onSegment sends a recorded segment as it is stored, without remuxing it.
+// Encrypted segments are decrypted. Range requests are supported.
+func (s *Server) onSegment(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDownload, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	hs, hreq, ok := s.startHooksFixed(ctx, pathName, segmentHookFormat(pathConf.RecordFormat), start)
+	if !ok {
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, nil)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	// start must be the one of a segment
+	seg := segments[0]
+	if !seg.Start.Equal(start) {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	fi, err := os.Stat(seg.Fpath)
+	if err != nil {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	f, err := seg.Open()
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+
+	ctx.Header("Content-Type", segmentContentType(pathConf.RecordFormat))
+	ctx.Header("Content-Disposition", `attachment; filename="`+filepath.Base(seg.Fpath)+`"`)
+
+	export := newHookExport(hs, hreq, ctx.Writer)
+	if export.passthrough() {
+		http.ServeContent(ctx.Writer, ctx.Request, filepath.Base(seg.Fpath), fi.ModTime(), f)
+		return
+	}
+
+	// the size of post-processed segments is unknown, therefore range requests are not supported
+	ctx.Status(http.StatusOK)
+
+	_, err = io.Copy(export, f)
+	if err == nil {
+		err = export.close()
+	}
+	if err != nil && !clientAborted(ctx, err) {
+		s.logRequest(ctx, logger.Error, err.Error())
+	}
+}
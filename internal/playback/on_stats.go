@@ -0,0 +1,253 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// default duration of each bucket.
+	statsDefaultResolution = 1 * time.Minute
+
+	// maximum number of buckets of a response.
+	statsMaxBuckets = 10000
+)
+
+type statsBucket struct {
+	Start            time.Time         `json:"start"`
+	Samples          int               `json:"samples"`
+	Bitrate          float64           `json:"bitrate"`
+	FrameRate        float64           `json:"frameRate,omitempty"`
+	Keyframes        int               `json:"keyframes,omitempty"`
+	KeyframeInterval listEntryDuration `json:"keyframeInterval,omitempty"`
+
+	size          uint64
+	duration      time.Duration
+	firstKeyframe time.Time
+	lastKeyframe  time.Time
+}
+
+type statsTrack struct {
+	ID      int           `json:"id"`
+	Codec   string        `json:"codec"`
+	Buckets []statsBucket `json:"buckets"`
+
+	isVideo bool
+}
+
+type stats struct {
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Resolution listEntryDuration `json:"resolution"`
+	Tracks     []*statsTrack     `json:"tracks"`
+}
+
+// readStats groups samples of segments into buckets of the given resolution,
+// by using part metadata only.
+func readStats(
+	segments []*recordstore.Segment,
+	start time.Time,
+	end time.Time,
+	resolution time.Duration,
+) (*stats, error) {
+	count := int((end.Sub(start) + resolution - 1) / resolution)
+	tracks := make(map[int]*statsTrack)
+
+	for _, seg := range segments {
+		err := func() error {
+			f, err := seg.Open()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			init, duration, err := segmentFMP4ReadHeader(f)
+			if err != nil {
+				return err
+			}
+
+			if duration == 0 {
+				duration, err = segmentFMP4ReadDurationFromParts(f, init)
+				if err != nil {
+					return err
+				}
+			}
+
+			// segment is in a gap before the requested window
+			if !seg.Start.Add(duration).After(start) {
+				return nil
+			}
+
+			for _, track := range init.Tracks {
+				if _, ok := tracks[track.ID]; !ok {
+					it := newInfoTrack(track.ID, track.Codec)
+					tracks[track.ID] = &statsTrack{
+						ID:      track.ID,
+						Codec:   it.Codec,
+						Buckets: make([]statsBucket, count),
+						isVideo: it.isVideo,
+					}
+				}
+			}
+
+			return segmentFMP4ReadSampleMetadata(f, init,
+				func(track *fmp4.InitTrack, dts time.Duration, duration time.Duration, size uint32, isSync bool) {
+					t := seg.Start.Add(dts)
+					if t.Before(start) || !t.Before(end) {
+						return
+					}
+
+					st := tracks[track.ID]
+					b := &st.Buckets[int(t.Sub(start)/resolution)]
+					b.Samples++
+					b.size += uint64(size)
+					b.duration += duration
+
+					if st.isVideo && isSync {
+						if b.Keyframes == 0 {
+							b.firstKeyframe = t
+						}
+						b.lastKeyframe = t
+						b.Keyframes++
+					}
+				})
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(tracks) == 0 {
+		return nil, recordstore.ErrNoSegmentsFound
+	}
+
+	out := &stats{
+		Start:      start,
+		End:        end,
+		Resolution: listEntryDuration(resolution),
+		Tracks:     make([]*statsTrack, 0, len(tracks)),
+	}
+
+	for _, t := range tracks {
+		for i := range t.Buckets {
+			b := &t.Buckets[i]
+			b.Start = start.Add(time.Duration(i) * resolution)
+
+			if b.duration > 0 {
+				secs := b.duration.Seconds()
+				b.Bitrate = float64(b.size*8) / secs
+				if t.isVideo {
+					b.FrameRate = float64(b.Samples) / secs
+				}
+			}
+
+			if b.Keyframes > 1 {
+				b.KeyframeInterval = listEntryDuration(b.lastKeyframe.Sub(b.firstKeyframe) /
+					time.Duration(b.Keyframes-1))
+			}
+		}
+		out.Tracks = append(out.Tracks, t)
+	}
+
+	sort.Slice(out.Tracks, func(i, j int) bool {
+		return out.Tracks[i].ID < out.Tracks[j].ID
+	})
+
+	return out, nil
+}
+
+func (s *Server) onStats(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackList, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, ctx.Query("end"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
+		return
+	}
+
+	if !end.After(start) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("end must be after start"))
+		return
+	}
+
+	resolution := statsDefaultResolution
+	if raw := ctx.Query("resolution"); raw != "" {
+		resolution, err = parseDuration(raw)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid resolution: %w", err))
+			return
+		}
+		if resolution <= 0 {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid resolution: %s", raw))
+			return
+		}
+	}
+
+	if int64(end.Sub(start)/resolution) >= statsMaxBuckets {
+		s.writeError(ctx, http.StatusBadRequest,
+			fmt.Errorf("too many buckets, maximum is %d: increase resolution", statsMaxBuckets))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("MPEG-TS format is not supported yet"))
+		return
+	}
+
+	start, end, ok := s.applyRangePolicy(ctx, pathConf, pathName, start, end)
+	if !ok {
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+	if len(segments) == 0 {
+		s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		return
+	}
+
+	out, err := readStats(segments, start, end, resolution)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, out)
+}
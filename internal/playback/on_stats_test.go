@@ -0,0 +1,96 @@
+package playback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnStats(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	start := time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local)
+
+	v := url.Values{}
+	v.Set("path", "mypath")
+	v.Set("start", start.Format(time.RFC3339Nano))
+	v.Set("end", start.Add(4*time.Second).Format(time.RFC3339Nano))
+	v.Set("resolution", "2")
+
+	res, err := hc.Get("http://localhost:9996/stats?" + v.Encode())
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	type bucket struct {
+		Samples          int     `json:"samples"`
+		Bitrate          float64 `json:"bitrate"`
+		FrameRate        float64 `json:"frameRate"`
+		Keyframes        int     `json:"keyframes"`
+		KeyframeInterval float64 `json:"keyframeInterval"`
+	}
+
+	var out struct {
+		Resolution float64 `json:"resolution"`
+		Tracks     []struct {
+			ID      int      `json:"id"`
+			Codec   string   `json:"codec"`
+			Buckets []bucket `json:"buckets"`
+		} `json:"tracks"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&out)
+	require.NoError(t, err)
+
+	require.Equal(t, float64(2), out.Resolution)
+	require.Len(t, out.Tracks, 2)
+
+	require.Equal(t, "H264", out.Tracks[0].Codec)
+	require.Equal(t, []bucket{
+		{Samples: 2, Bitrate: 16, FrameRate: 1, Keyframes: 2, KeyframeInterval: 1},
+		{Samples: 2, Bitrate: 16, FrameRate: 1, Keyframes: 2, KeyframeInterval: 1},
+	}, out.Tracks[0].Buckets)
+
+	require.Equal(t, "MPEG-4 Audio", out.Tracks[1].Codec)
+	require.Equal(t, []bucket{
+		{Samples: 2, Bitrate: 16},
+		{},
+	}, out.Tracks[1].Buckets)
+}
@@ -0,0 +1,200 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// parseTagFilter parses a filter in the format key or key:value.
+func parseTagFilter(raw string) (string, string, error) {
+	key, value, _ := strings.Cut(raw, ":")
+	if key == "" {
+		return "", "", fmt.Errorf("invalid tag: %s", raw)
+	}
+	return key, value, nil
+}
+
+// filterEntriesByTags returns the parts of entries that are covered by at least one tag.
+func filterEntriesByTags(entries []listEntry, tags []*recordstore.Tag) []listEntry {
+	out := []listEntry{}
+
+	for _, e := range entries {
+		entryEnd := e.Start.Add(time.Duration(e.Duration))
+
+		matching := recordstore.TagsOverlapping(tags, e.Start, entryEnd)
+		sort.Slice(matching, func(i, j int) bool {
+			return matching[i].Start.Before(matching[j].Start)
+		})
+
+		tracksChanged := e.TracksChanged
+
+		for i := 0; i < len(matching); {
+			start := matching[i].Start
+			end := matching[i].End
+
+			// merge overlapping tags
+			for i++; i < len(matching) && !matching[i].Start.After(end); i++ {
+				if matching[i].End.After(end) {
+					end = matching[i].End
+				}
+			}
+
+			if start.Before(e.Start) {
+				start = e.Start
+			}
+			if end.After(entryEnd) {
+				end = entryEnd
+			}
+
+			out = append(out, listEntry{
+				Start:         start,
+				Duration:      listEntryDuration(end.Sub(start)),
+				Tracks:        e.Tracks,
+				TracksChanged: tracksChanged,
+			})
+			tracksChanged = false
+		}
+	}
+
+	return out
+}
+
+func (s *Server) onTagsList(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackList, pathName) {
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	tags, err := recordstore.FindTags(pathConf, pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if raw := ctx.Query("tag"); raw != "" {
+		key, value, err2 := parseTagFilter(raw)
+		if err2 != nil {
+			s.writeError(ctx, http.StatusBadRequest, err2)
+			return
+		}
+
+		filtered := []*recordstore.Tag{}
+		for _, t := range tags {
+			if t.Matches(key, value) {
+				filtered = append(filtered, t)
+			}
+		}
+		tags = filtered
+	}
+
+	ctx.JSON(http.StatusOK, tags)
+}
+
+func (s *Server) onTagsAdd(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDelete, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, ctx.Query("end"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid end: %w", err))
+		return
+	}
+
+	if !end.After(start) {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("end must be after start"))
+		return
+	}
+
+	// the colon separates keys from values in filters
+	key := ctx.Query("key")
+	if key == "" || strings.Contains(key, ":") {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid key: '%s'", key))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	tag := &recordstore.Tag{
+		ID:      uuid.New(),
+		Path:    pathName,
+		Start:   start,
+		End:     end,
+		Key:     key,
+		Value:   ctx.Query("value"),
+		Created: time.Now(),
+	}
+
+	err = recordstore.AddTag(pathConf, tag)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.logRequest(ctx, logger.Info, "added tag %s to path '%s'", tag.ID, pathName)
+
+	ctx.JSON(http.StatusOK, tag)
+}
+
+func (s *Server) onTagsRemove(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackDelete, pathName) {
+		return
+	}
+
+	id, err := uuid.Parse(ctx.Query("id"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid id: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	err = recordstore.RemoveTag(pathConf, pathName, id)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrTagNotFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	s.logRequest(ctx, logger.Info, "removed tag %s from path '%s'", id, pathName)
+
+	ctx.Status(http.StatusOK)
+}
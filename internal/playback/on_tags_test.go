@@ -0,0 +1,168 @@
+package playback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterEntriesByTags(t *testing.T) {
+	base := time.Date(2008, 11, 0o7, 11, 23, 0, 0, time.UTC)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+
+	entries := []listEntry{
+		{Start: at(0), Duration: listEntryDuration(10 * time.Second)},
+		{Start: at(20), Duration: listEntryDuration(10 * time.Second), TracksChanged: true},
+	}
+
+	tags := []*recordstore.Tag{
+		{Start: at(5), End: at(7)},
+		{Start: at(-5), End: at(2)},
+		{Start: at(6), End: at(8)},
+		{Start: at(9), End: at(21)},
+	}
+
+	require.Equal(t, []listEntry{
+		{Start: at(0), Duration: listEntryDuration(2 * time.Second)},
+		{Start: at(5), Duration: listEntryDuration(3 * time.Second)},
+		{Start: at(9), Duration: listEntryDuration(1 * time.Second)},
+		{Start: at(20), Duration: listEntryDuration(1 * time.Second), TracksChanged: true},
+	}, filterEntriesByTags(entries, tags))
+}
+
+func TestOnTags(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	do := func(method string, endpoint string, v url.Values) *http.Response {
+		u := &url.URL{
+			Scheme:   "http",
+			Host:     "localhost:9996",
+			Path:     endpoint,
+			RawQuery: v.Encode(),
+		}
+
+		req, err2 := http.NewRequest(method, u.String(), nil)
+		require.NoError(t, err2)
+
+		res, err2 := hc.Do(req)
+		require.NoError(t, err2)
+
+		return res
+	}
+
+	tagStart := time.Date(2008, 11, 0o7, 11, 23, 3, 0, time.Local)
+	tagEnd := time.Date(2008, 11, 0o7, 11, 23, 4, 0, time.Local)
+
+	res := do(http.MethodPost, "/tags", url.Values{
+		"path":  []string{"mypath"},
+		"start": []string{tagStart.Format(time.RFC3339)},
+		"end":   []string{tagEnd.Format(time.RFC3339)},
+		"key":   []string{"licenseplate"},
+		"value": []string{"AB123CD"},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var tag recordstore.Tag
+	err = json.NewDecoder(res.Body).Decode(&tag)
+	require.NoError(t, err)
+	require.Equal(t, "licenseplate", tag.Key)
+	require.Equal(t, "AB123CD", tag.Value)
+
+	res = do(http.MethodPost, "/tags", url.Values{
+		"path":  []string{"mypath"},
+		"start": []string{tagStart.Format(time.RFC3339)},
+		"end":   []string{tagEnd.Format(time.RFC3339)},
+		"key":   []string{"a:b"},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res = do(http.MethodGet, "/tags", url.Values{
+		"path": []string{"mypath"},
+		"tag":  []string{"licenseplate"},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var tags []*recordstore.Tag
+	err = json.NewDecoder(res.Body).Decode(&tags)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	require.Equal(t, tag.ID, tags[0].ID)
+
+	res = do(http.MethodGet, "/list", url.Values{
+		"path": []string{"mypath"},
+		"tag":  []string{"licenseplate:AB123CD"},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var entries []map[string]any
+	err = json.NewDecoder(res.Body).Decode(&entries)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, tagStart.Format(time.RFC3339Nano), entries[0]["start"])
+	require.Equal(t, float64(1), entries[0]["duration"])
+
+	res = do(http.MethodGet, "/list", url.Values{
+		"path": []string{"mypath"},
+		"tag":  []string{"licenseplate:XY987ZW"},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res = do(http.MethodDelete, "/tags", url.Values{
+		"path": []string{"mypath"},
+		"id":   []string{tag.ID.String()},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = do(http.MethodDelete, "/tags", url.Values{
+		"path": []string{"mypath"},
+		"id":   []string{tag.ID.String()},
+	})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,144 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+func vttTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, ms%1000)
+}
+
+// contiguousEnd returns the end of the recording that can be exported
+// in a single /get request, that stops at the first gap.
+func contiguousEnd(segments []infoSegment) time.Time {
+	end := segments[0].Start.Add(time.Duration(segments[0].Duration))
+
+	for _, seg := range segments[1:] {
+		if seg.Start.After(end.Add(concatenationTolerance)) {
+			break
+		}
+		end = seg.Start.Add(time.Duration(seg.Duration))
+	}
+
+	return end
+}
+
+// writeTimestampsVTT writes a WebVTT track with a cue for each second of wall-clock time.
+// Cue times are relative to start, that is the start of the /get request.
+func writeTimestampsVTT(w io.Writer, start time.Time, from time.Time, to time.Time) error {
+	_, err := io.WriteString(w, "WEBVTT\n")
+	if err != nil {
+		return err
+	}
+
+	for cur := from; cur.Before(to); {
+		next := cur.Truncate(time.Second).Add(time.Second)
+		if next.After(to) {
+			next = to
+		}
+
+		_, err = fmt.Fprintf(w, "\n%s --> %s\n%s\n",
+			vttTimestamp(cur.Sub(start)),
+			vttTimestamp(next.Sub(start)),
+			cur.UTC().Truncate(time.Second).Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+
+		cur = next
+	}
+
+	return nil
+}
+
+func (s *Server) onTimestamps(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, conf.AuthActionPlaybackList, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := start.Add(duration)
+
+	start, end, ok := s.applyRangePolicy(ctx, pathConf, pathName, start, end)
+	if !ok {
+		return
+	}
+
+	hs, hreq, ok := s.startHooks(ctx, pathName, "vtt", start, end.Sub(start))
+	if !ok {
+		return
+	}
+	start, end = hreq.Start, hreq.Start.Add(hreq.Duration)
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	segments = s.dropIncompleteSegment(pathConf.RecordFormat, segments)
+	if len(segments) == 0 {
+		s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		return
+	}
+
+	in, err := readInfo(pathConf.RecordFormat, segments, start, end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		} else {
+			s.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	to := contiguousEnd(in.Segments)
+	if to.After(end) {
+		to = end
+	}
+
+	ctx.Header("Content-Type", "text/vtt")
+	ctx.Status(http.StatusOK)
+
+	export := newHookExport(hs, hreq, ctx.Writer)
+	err = writeTimestampsVTT(export, start, in.Start, to)
+	if err == nil {
+		err = export.close()
+	}
+	if err != nil {
+		s.logRequest(ctx, logger.Error, err.Error())
+	}
+}
@@ -0,0 +1,19 @@
+package playback
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed ui.html
+var uiPage []byte
+
+// onUI serves a page that allows to browse and play recordings.
+// The page contains no data, that is fetched from the other endpoints,
+// therefore it doesn't require authentication.
+func (s *Server) onUI(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "max-age=3600")
+	ctx.Data(http.StatusOK, "text/html", uiPage)
+}
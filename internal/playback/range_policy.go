@@ -0,0 +1,92 @@
+package playback
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// clampWindow moves a window that starts in the future, or that ends before the oldest recording,
+// inside available recordings, preserving its duration.
+func (s *Server) clampWindow(
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	end time.Time,
+) (time.Time, time.Time) {
+	duration := end.Sub(start)
+
+	if start.After(time.Now()) {
+		before, _ := s.nearestEntries(pathConf, pathName, start, end)
+		if before == nil {
+			return start, end
+		}
+
+		end = before.Start.Add(time.Duration(before.Duration))
+		return end.Add(-duration), end
+	}
+
+	_, err := recordstore.FindSegments(pathConf, pathName, nil, &end)
+	if !errors.Is(err, recordstore.ErrNoSegmentsFound) {
+		return start, end
+	}
+
+	_, after := s.nearestEntries(pathConf, pathName, start, end)
+	if after == nil {
+		return start, end
+	}
+
+	return after.Start, after.Start.Add(duration)
+}
+
+// applyRangePolicy handles windows that start in the future or that end
+// before the oldest recording, that would otherwise result in a generic
+// not found error, and returns the window to use.
+// It returns false when a response has already been written.
+func (s *Server) applyRangePolicy(
+	ctx *gin.Context,
+	pathConf *conf.Path,
+	pathName string,
+	start time.Time,
+	end time.Time,
+) (time.Time, time.Time, bool) {
+	switch s.RangePolicy {
+	case conf.PlaybackRangePolicyClamp:
+		newStart, newEnd := s.clampWindow(pathConf, pathName, start, end)
+		if !newStart.Equal(start) {
+			s.logRequest(ctx, logger.Debug, "window moved from %v to %v", start, newStart)
+		}
+		return newStart, newEnd, true
+
+	case conf.PlaybackRangePolicyWait:
+		d := time.Until(start)
+		if d <= 0 || d > time.Duration(s.MaxClockSkew) {
+			break
+		}
+
+		s.logRequest(ctx, logger.Debug, "start is in the future, waiting %v", d)
+
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+			return start, end, true
+
+		case <-ctx.Request.Context().Done():
+			return time.Time{}, time.Time{}, false
+		}
+	}
+
+	// windows that end before the oldest recording are described by writeNoSegments
+	if start.After(time.Now()) {
+		s.writeNoSegments(ctx, pathConf, pathName, start, end)
+		return time.Time{}, time.Time{}, false
+	}
+
+	return start, end, true
+}
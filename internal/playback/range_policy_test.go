@@ -0,0 +1,170 @@
+package playback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangePolicy(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	for _, ca := range []struct {
+		name   string
+		policy conf.PlaybackRangePolicy
+		start  time.Time
+		status int
+		err    string
+		out    time.Time
+	}{
+		{
+			"reject future",
+			conf.PlaybackRangePolicyReject,
+			time.Now().Add(1 * time.Hour),
+			http.StatusNotFound,
+			"no recording segments found: start is in the future",
+			time.Time{},
+		},
+		{
+			"clamp future",
+			conf.PlaybackRangePolicyClamp,
+			time.Now().Add(1 * time.Hour),
+			http.StatusOK,
+			"",
+			time.Date(2008, 11, 0o7, 11, 23, 4, 500000000, time.Local),
+		},
+		{
+			"clamp past",
+			conf.PlaybackRangePolicyClamp,
+			time.Date(2008, 11, 0o7, 10, 0, 0, 0, time.Local),
+			http.StatusOK,
+			"",
+			time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local),
+		},
+		{
+			"wait too far",
+			conf.PlaybackRangePolicyWait,
+			time.Now().Add(1 * time.Hour),
+			http.StatusNotFound,
+			"no recording segments found: start is in the future",
+			time.Time{},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			s := &Server{
+				Playback: conf.Playback{
+					Address:      "127.0.0.1:9996",
+					RangePolicy:  ca.policy,
+					MaxClockSkew: conf.Duration(10 * time.Second),
+				},
+				ReadTimeout: conf.Duration(10 * time.Second),
+				PathConfs: map[string]*conf.Path{
+					"mypath": {
+						Name:       "mypath",
+						RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+					},
+				},
+				AuthManager: test.NilAuthManager,
+				Parent:      test.NilLogger,
+			}
+			err := s.Initialize()
+			require.NoError(t, err)
+			defer s.Close()
+
+			tr := &http.Transport{}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			v := url.Values{}
+			v.Set("path", "mypath")
+			v.Set("start", ca.start.Format(time.RFC3339Nano))
+			v.Set("duration", "2")
+
+			res, err := hc.Get("http://localhost:9996/info?" + v.Encode())
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, ca.status, res.StatusCode)
+
+			var out struct {
+				Error string    `json:"error"`
+				Start time.Time `json:"start"`
+			}
+			err = json.NewDecoder(res.Body).Decode(&out)
+			require.NoError(t, err)
+
+			require.Equal(t, ca.err, out.Error)
+			require.True(t, ca.out.Equal(out.Start))
+		})
+	}
+}
+
+func TestRangePolicyWait(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address:      "127.0.0.1:9996",
+			RangePolicy:  conf.PlaybackRangePolicyWait,
+			MaxClockSkew: conf.Duration(10 * time.Second),
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	start := time.Now().Add(500 * time.Millisecond)
+
+	v := url.Values{}
+	v.Set("path", "mypath")
+	v.Set("start", start.Format(time.RFC3339Nano))
+	v.Set("duration", "2")
+
+	res, err := hc.Get("http://localhost:9996/info?" + v.Encode())
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	// the start has been reached, but recordings are still missing
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	require.False(t, time.Now().Before(start))
+
+	var out noSegmentsResponse
+	err = json.NewDecoder(res.Body).Decode(&out)
+	require.NoError(t, err)
+	require.Equal(t, "no recording segments found", out.Error)
+}
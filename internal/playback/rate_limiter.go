@@ -0,0 +1,82 @@
+package playback
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
+	"github.com/gin-gonic/gin"
+)
+
+// period in which the number of started downloads is counted.
+const rateLimitWindow = 1 * time.Minute
+
+var errRateLimited = errors.New("too many downloads, retry later")
+
+// downloadRateLimiter limits the number of downloads that each client can start
+// in a sliding window. Differently from lanes, that limit concurrency, this
+// protects the server from clients that start a new download at every seek.
+type downloadRateLimiter struct {
+	max int
+
+	mutex   sync.Mutex
+	entries map[string][]time.Time
+}
+
+func (l *downloadRateLimiter) initialize() {
+	l.entries = make(map[string][]time.Time)
+}
+
+// take registers a download of a client.
+// It returns zero if the download can start, otherwise how long the client has to wait.
+func (l *downloadRateLimiter) take(key string) time.Duration {
+	if l.max == 0 {
+		return 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+
+	for k, starts := range l.entries {
+		i := 0
+		for i < len(starts) && now.Sub(starts[i]) >= rateLimitWindow {
+			i++
+		}
+
+		if i == len(starts) {
+			delete(l.entries, k)
+		} else {
+			l.entries[k] = starts[i:]
+		}
+	}
+
+	starts := l.entries[key]
+	if len(starts) >= l.max {
+		return starts[0].Add(rateLimitWindow).Sub(now)
+	}
+
+	l.entries[key] = append(starts, now)
+	return 0
+}
+
+// admitRate applies the rate limit before a download starts.
+// Clients are identified by IP and user.
+func (s *Server) admitRate(ctx *gin.Context) bool {
+	key := httpp.ClientIP(ctx) + "/" + httpp.Credentials(ctx.Request).User
+
+	d := s.downloadRate.take(key)
+	if d == 0 {
+		return true
+	}
+
+	ctx.Header("Retry-After", strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10))
+	s.logRequest(ctx, logger.Debug, "rate limit reached by %s", s.logIP(ctx))
+	s.writeError(ctx, http.StatusTooManyRequests, errRateLimited)
+	return false
+}
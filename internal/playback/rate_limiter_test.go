@@ -0,0 +1,97 @@
+package playback
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadRateLimiter(t *testing.T) {
+	l := &downloadRateLimiter{max: 2}
+	l.initialize()
+
+	require.Zero(t, l.take("a"))
+	require.Zero(t, l.take("a"))
+	require.Zero(t, l.take("b"))
+
+	d := l.take("a")
+	require.Greater(t, d, time.Duration(0))
+	require.LessOrEqual(t, d, rateLimitWindow)
+
+	// downloads that are older than the window are forgotten
+	l.entries["a"][0] = l.entries["a"][0].Add(-rateLimitWindow)
+	require.Zero(t, l.take("a"))
+}
+
+func TestRateLimit(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address:   "127.0.0.1:9996",
+			RateLimit: 1,
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	get := func(user string) *http.Response {
+		u, err2 := url.Parse("http://" + user + ":mypass@localhost:9996/get")
+		require.NoError(t, err2)
+
+		v := url.Values{}
+		v.Set("path", "mypath")
+		v.Set("start", time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano))
+		v.Set("duration", "1")
+		u.RawQuery = v.Encode()
+
+		res, err2 := hc.Get(u.String())
+		require.NoError(t, err2)
+
+		_, err2 = io.ReadAll(res.Body)
+		require.NoError(t, err2)
+		res.Body.Close()
+
+		return res
+	}
+
+	res := get("myuser")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = get("myuser")
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	require.Equal(t, "60", res.Header.Get("Retry-After"))
+
+	// limits are separate for each user
+	res = get("myuser2")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+}
@@ -0,0 +1,54 @@
+package playback
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// middlewareWritable rejects requests that modify recordings
+// when the server is in read-only mode.
+func (s *Server) middlewareWritable(ctx *gin.Context) {
+	if s.ReadOnly {
+		s.writeError(ctx, http.StatusForbidden, fmt.Errorf("server is in read-only mode"))
+		ctx.Abort()
+		return
+	}
+}
+
+func (s *Server) faultsEnabled() bool {
+	return s.FaultDelay != 0 || s.FaultFailures != 0 || s.FaultCorrupt != 0
+}
+
+// middlewareNoFaults rejects requests that rewrite recordings
+// when faults are injected, since faulty reads would replace original recordings
+// with corrupted ones.
+func (s *Server) middlewareNoFaults(ctx *gin.Context) {
+	if s.faultsEnabled() {
+		s.writeError(ctx, http.StatusForbidden, fmt.Errorf("recordings can't be modified while faults are injected"))
+		ctx.Abort()
+		return
+	}
+}
+
+// dropIncompleteSegment removes the last segment when it can't be parsed.
+// In read-only mode, recordings are written by another instance,
+// therefore the last segment may be partially written.
+func (s *Server) dropIncompleteSegment(
+	recordFormat conf.RecordFormat,
+	segments []*recordstore.Segment,
+) []*recordstore.Segment {
+	if !s.ReadOnly || recordFormat != conf.RecordFormatFMP4 || len(segments) == 0 {
+		return segments
+	}
+
+	_, err := parseSegment(segments[len(segments)-1])
+	if err != nil {
+		return segments[:len(segments)-1]
+	}
+
+	return segments
+}
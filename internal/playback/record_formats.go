@@ -0,0 +1,99 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+// segmentsFormat returns the format of segments,
+// and whether segments have been recorded in multiple formats.
+func segmentsFormat(segments []*recordstore.Segment) (conf.RecordFormat, bool) {
+	for _, seg := range segments[1:] {
+		if seg.Format != segments[0].Format {
+			return segments[0].Format, true
+		}
+	}
+	return segments[0].Format, false
+}
+
+// formatRuns groups consecutive segments that have the same format.
+func formatRuns(segments []*recordstore.Segment) [][]*recordstore.Segment {
+	var runs [][]*recordstore.Segment
+
+	for i, seg := range segments {
+		if i == 0 || seg.Format != segments[i-1].Format {
+			runs = append(runs, nil)
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], seg)
+	}
+
+	return runs
+}
+
+// seekAndMuxMixed muxes segments that have been recorded in multiple formats.
+// With the MPEG-TS output format, runs of MPEG-TS segments are copied and runs of fMP4 segments
+// are remuxed, each into a separate stream that is appended to the previous ones.
+// Other output formats are produced from fMP4 segments only, and therefore stop
+// at the first change of format, as when segments can't be concatenated.
+func seekAndMuxMixed(
+	ctx context.Context,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+	m muxer,
+) error {
+	runs := formatRuns(segments)
+
+	tm, ok := m.(*muxerTS)
+	if !ok {
+		return seekAndMux(ctx, runs[0][0].Format, runs[0], start, duration, m)
+	}
+
+	end := start.Add(duration)
+	written := false
+
+	for _, run := range runs {
+		runStart := start
+		if run[0].Start.After(start) {
+			runStart = run[0].Start
+		}
+
+		if !runStart.Before(end) {
+			break
+		}
+
+		if run[0].Format == conf.RecordFormatMPEGTS {
+			err := tm.copySegments(ctx, run)
+			if err != nil {
+				return err
+			}
+
+			written = true
+			continue
+		}
+
+		sub := &muxerTS{w: tm.w}
+		err := seekAndMux(ctx, conf.RecordFormatFMP4, run, runStart, end.Sub(runStart), sub)
+		tm.bytesWritten += sub.progress().bytesWritten
+		if err != nil {
+			// the run doesn't contain any sample inside the window
+			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				continue
+			}
+			return err
+		}
+
+		tm.mediaTime = runStart.Sub(start) + sub.mediaTime
+		written = true
+	}
+
+	if !written {
+		return recordstore.ErrNoSegmentsFound
+	}
+
+	return nil
+}
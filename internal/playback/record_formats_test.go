@@ -0,0 +1,95 @@
+package playback
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mpegts"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnGetMixedFormats(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	// the record format has been changed from MPEG-TS to fMP4
+	seg1 := bytes.Repeat([]byte{0x47, 1}, 94)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.ts"), seg1, 0o644)
+	require.NoError(t, err)
+
+	writeSegmentAVCC(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-04-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:         "mypath",
+				RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat: conf.RecordFormatFMP4,
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for _, ca := range []struct {
+		name   string
+		format string
+		status int
+	}{
+		{"ts", "ts", http.StatusOK},
+		{"mp4", "mp4", http.StatusBadRequest},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			v := url.Values{}
+			v.Set("path", "mypath")
+			v.Set("start", time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano))
+			v.Set("duration", "6")
+			v.Set("format", ca.format)
+
+			res, err := hc.Get("http://localhost:9996/get?" + v.Encode())
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, ca.status, res.StatusCode)
+
+			if ca.status != http.StatusOK {
+				return
+			}
+
+			buf, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			// the MPEG-TS segment is copied, then the fMP4 segment is remuxed
+			require.Equal(t, seg1, buf[:len(seg1)])
+
+			r := &mpegts.Reader{R: bytes.NewReader(buf[len(seg1):])}
+			err = r.Initialize()
+			require.NoError(t, err)
+			require.Len(t, r.Tracks(), 2)
+		})
+	}
+}
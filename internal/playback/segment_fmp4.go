@@ -2,6 +2,7 @@ package playback
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -150,11 +151,85 @@ func segmentFMP4ReadHeader(r io.ReadSeeker) (*fmp4.Init, time.Duration, error) {
 	return &init, d, nil
 }
 
+// segmentFMP4CompleteSize returns the size of the initial portion of a segment
+// that contains complete boxes only. Segments that are being written
+// may end with a part whose moof or mdat box is partially written.
+func segmentFMP4CompleteSize(r io.ReadSeeker) (int64, error) {
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 8)
+	var pos int64
+	var completeSize int64
+
+	for pos+8 <= fileSize {
+		_, err = r.Seek(pos, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = io.ReadFull(r, buf)
+		if err != nil {
+			return 0, err
+		}
+
+		boxSize := int64(uint32(buf[0])<<24 | uint32(buf[1])<<16 | uint32(buf[2])<<8 | uint32(buf[3]))
+		if boxSize < 8 || pos+boxSize > fileSize {
+			break
+		}
+
+		pos += boxSize
+
+		// a moof is complete only when it is followed by its mdat
+		if !bytes.Equal(buf[4:], []byte{'m', 'o', 'o', 'f'}) {
+			completeSize = pos
+		}
+	}
+
+	return completeSize, nil
+}
+
+// segmentFMP4PartsReader returns a reader of the parts of a segment.
+// When the segment is being written, its duration is not in the header yet,
+// and the reader is limited to complete parts.
+func segmentFMP4PartsReader(r readSeekerAt, headerDuration time.Duration) (readSeekerAt, error) {
+	if headerDuration != 0 {
+		return r, nil
+	}
+
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := segmentFMP4CompleteSize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sr := io.NewSectionReader(r, 0, size)
+
+	_, err = sr.Seek(pos, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return sr, nil
+}
+
 func segmentFMP4ReadDurationFromParts(
 	r io.ReadSeeker,
 	init *fmp4.Init,
 ) (time.Duration, error) {
-	_, err := r.Seek(0, io.SeekStart)
+	// parts that are being written are skipped
+	completeSize, err := segmentFMP4CompleteSize(r)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
 	if err != nil {
 		return 0, err
 	}
@@ -234,6 +309,10 @@ func segmentFMP4ReadDurationFromParts(
 
 		mdatSize := uint32(buf[0])<<24 | uint32(buf[1])<<16 | uint32(buf[2])<<8 | uint32(buf[3])
 
+		if moofPos+int64(moofSize)+int64(mdatSize) > completeSize {
+			break
+		}
+
 		_, err = r.Seek(int64(mdatSize)-8, io.SeekCurrent)
 		if err != nil {
 			break
@@ -388,6 +467,7 @@ outer:
 }
 
 func segmentFMP4MuxParts(
+	ctx context.Context,
 	r readSeekerAt,
 	dtsOffset time.Duration,
 	duration time.Duration,
@@ -406,6 +486,11 @@ func segmentFMP4MuxParts(
 	_, err := mp4.ReadBoxStructure(r, func(h *mp4.ReadHandle) (interface{}, error) {
 		switch h.BoxInfo.Type.String() {
 		case "moof":
+			// stop reading from disk when the client goes away
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
 			moofOffset = h.BoxInfo.Offset
 			return h.Expand()
 
@@ -443,41 +528,28 @@ func segmentFMP4MuxParts(
 			}
 			trun := box.(*mp4.Trun)
 
-			dataOffset := moofOffset + uint64(trun.DataOffset)
+			samples := newSegmentFMP4SampleReader(r, moofOffset+uint64(trun.DataOffset), trun.Entries)
 			muxerDTS := int64(tfdt.BaseMediaDecodeTimeV1) + dtsOffsetMP4
 
-			for _, e := range trun.Entries {
+			for i, e := range trun.Entries {
 				if muxerDTS >= durationMP4 {
 					breakAtNextMdat = true
 					break
 				}
 
-				sampleOffset := dataOffset
-				sampleSize := e.SampleSize
-
 				err = m.writeSample(
 					muxerDTS,
 					e.SampleCompositionTimeOffsetV1,
 					(e.SampleFlags&sampleFlagIsNonSyncSample) != 0,
 					e.SampleSize,
 					func() ([]byte, error) {
-						payload := make([]byte, sampleSize)
-						n, err2 := r.ReadAt(payload, int64(sampleOffset))
-						if err2 != nil {
-							return nil, err2
-						}
-						if n != int(sampleSize) {
-							return nil, fmt.Errorf("partial read")
-						}
-
-						return payload, nil
+						return samples.read(i)
 					},
 				)
 				if err != nil {
 					return nil, err
 				}
 
-				dataOffset += uint64(e.SampleSize)
 				muxerDTS += int64(e.SampleDuration)
 			}
 
@@ -502,3 +574,203 @@ func segmentFMP4MuxParts(
 
 	return maxMuxerDTS, nil
 }
+
+type segmentFMP4TrackStats struct {
+	samples  int
+	size     uint64
+	duration time.Duration
+}
+
+// segmentFMP4ReadTrackStats computes statistics of each track,
+// by using samples whose DTS is between dtsStart and dtsEnd.
+// Sample payloads are not read.
+func segmentFMP4ReadTrackStats(
+	r io.ReadSeeker,
+	init *fmp4.Init,
+	dtsStart time.Duration,
+	dtsEnd time.Duration,
+) (map[int]*segmentFMP4TrackStats, error) {
+	stats := make(map[int]*segmentFMP4TrackStats)
+	var tfhd *mp4.Tfhd
+	var tfdt *mp4.Tfdt
+
+	_, err := mp4.ReadBoxStructure(r, func(h *mp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type.String() {
+		case "moof", "traf":
+			return h.Expand()
+
+		case "tfhd":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			tfhd = box.(*mp4.Tfhd)
+
+		case "tfdt":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			tfdt = box.(*mp4.Tfdt)
+
+		case "trun":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			trun := box.(*mp4.Trun)
+
+			track := findInitTrack(init.Tracks, int(tfhd.TrackID))
+			if track == nil {
+				return nil, fmt.Errorf("invalid track ID: %v", tfhd.TrackID)
+			}
+
+			ts, ok := stats[track.ID]
+			if !ok {
+				ts = &segmentFMP4TrackStats{}
+				stats[track.ID] = ts
+			}
+
+			dts := int64(tfdt.BaseMediaDecodeTimeV1)
+			dtsStartMP4 := durationGoToMp4(dtsStart, track.TimeScale)
+			dtsEndMP4 := durationGoToMp4(dtsEnd, track.TimeScale)
+
+			for _, e := range trun.Entries {
+				if dts >= dtsStartMP4 && dts < dtsEndMP4 {
+					ts.samples++
+					ts.size += uint64(e.SampleSize)
+					ts.duration += durationMp4ToGo(int64(e.SampleDuration), track.TimeScale)
+				}
+				dts += int64(e.SampleDuration)
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// segmentFMP4ReadSampleMetadata calls cb with the DTS, duration, size and sync flag of each sample,
+// in the order in which samples are stored. Sample payloads are not read.
+func segmentFMP4ReadSampleMetadata(
+	r io.ReadSeeker,
+	init *fmp4.Init,
+	cb func(track *fmp4.InitTrack, dts time.Duration, duration time.Duration, size uint32, isSync bool),
+) error {
+	var tfhd *mp4.Tfhd
+	var tfdt *mp4.Tfdt
+
+	_, err := mp4.ReadBoxStructure(r, func(h *mp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type.String() {
+		case "moof", "traf":
+			return h.Expand()
+
+		case "tfhd":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			tfhd = box.(*mp4.Tfhd)
+
+		case "tfdt":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			tfdt = box.(*mp4.Tfdt)
+
+		case "trun":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			trun := box.(*mp4.Trun)
+
+			track := findInitTrack(init.Tracks, int(tfhd.TrackID))
+			if track == nil {
+				return nil, fmt.Errorf("invalid track ID: %v", tfhd.TrackID)
+			}
+
+			dts := int64(tfdt.BaseMediaDecodeTimeV1)
+
+			for _, e := range trun.Entries {
+				cb(track,
+					durationMp4ToGo(dts, track.TimeScale),
+					durationMp4ToGo(int64(e.SampleDuration), track.TimeScale),
+					e.SampleSize,
+					(e.SampleFlags&sampleFlagIsNonSyncSample) == 0)
+				dts += int64(e.SampleDuration)
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// segmentFMP4FindSyncSample returns the DTS of the last sync sample of a track
+// whose DTS is less or equal than maxDTS.
+func segmentFMP4FindSyncSample(
+	r io.ReadSeeker,
+	track *fmp4.InitTrack,
+	maxDTS time.Duration,
+) (time.Duration, bool, error) {
+	maxDTSMP4 := durationGoToMp4(maxDTS, track.TimeScale)
+	var tfhd *mp4.Tfhd
+	var tfdt *mp4.Tfdt
+	found := false
+	var syncDTS int64
+
+	_, err := mp4.ReadBoxStructure(r, func(h *mp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type.String() {
+		case "moof", "traf":
+			return h.Expand()
+
+		case "tfhd":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			tfhd = box.(*mp4.Tfhd)
+
+		case "tfdt":
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			tfdt = box.(*mp4.Tfdt)
+
+		case "trun":
+			if int(tfhd.TrackID) != track.ID {
+				return nil, nil
+			}
+
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+			trun := box.(*mp4.Trun)
+
+			dts := int64(tfdt.BaseMediaDecodeTimeV1)
+
+			for _, e := range trun.Entries {
+				if dts > maxDTSMP4 {
+					break
+				}
+				if (e.SampleFlags & sampleFlagIsNonSyncSample) == 0 {
+					syncDTS = dts
+					found = true
+				}
+				dts += int64(e.SampleDuration)
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	return durationMp4ToGo(syncDTS, track.TimeScale), found, nil
+}
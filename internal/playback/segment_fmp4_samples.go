@@ -0,0 +1,107 @@
+package playback
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/abema/go-mp4"
+)
+
+// maximum size of the samples read with a single system call.
+const sampleReadBatchMaxSize = 1024 * 1024
+
+// segmentFMP4SampleReader reads samples of a track run.
+// When the platform allows it, consecutive samples are read with a single system call,
+// and kept until they are requested by the muxer.
+type segmentFMP4SampleReader struct {
+	r       io.ReaderAt
+	offsets []int64
+	sizes   []uint32
+
+	cacheStart int
+	cache      [][]byte
+}
+
+func newSegmentFMP4SampleReader(r io.ReaderAt, dataOffset uint64, entries []mp4.TrunEntry) *segmentFMP4SampleReader {
+	sr := &segmentFMP4SampleReader{
+		r:       r,
+		offsets: make([]int64, len(entries)),
+		sizes:   make([]uint32, len(entries)),
+	}
+
+	for i, e := range entries {
+		sr.offsets[i] = int64(dataOffset)
+		sr.sizes[i] = e.SampleSize
+		dataOffset += uint64(e.SampleSize)
+	}
+
+	return sr
+}
+
+func (sr *segmentFMP4SampleReader) read(i int) ([]byte, error) {
+	if i >= sr.cacheStart && i < sr.cacheStart+len(sr.cache) && sr.cache[i-sr.cacheStart] != nil {
+		payload := sr.cache[i-sr.cacheStart]
+		sr.cache[i-sr.cacheStart] = nil
+		return payload, nil
+	}
+
+	// samples are contiguous, therefore they can be read together
+	end := i + 1
+	size := uint64(sr.sizes[i])
+	for end < len(sr.sizes) && end-i < sampleReadBatchMaxCount &&
+		size+uint64(sr.sizes[end]) <= sampleReadBatchMaxSize {
+		size += uint64(sr.sizes[end])
+		end++
+	}
+
+	bufs := make([][]byte, end-i)
+	for j := range bufs {
+		bufs[j] = make([]byte, sr.sizes[i+j])
+	}
+
+	err := readSamplesAt(sr.r, sr.offsets[i], bufs)
+	if err != nil {
+		sr.cache = nil
+
+		// following samples may be unreadable while the requested one is not
+		if len(bufs) == 1 {
+			return nil, err
+		}
+		err = readSampleAt(sr.r, bufs[0], sr.offsets[i])
+		if err != nil {
+			return nil, err
+		}
+		return bufs[0], nil
+	}
+
+	sr.cacheStart = i
+	sr.cache = bufs
+
+	payload := sr.cache[0]
+	sr.cache[0] = nil
+	return payload, nil
+}
+
+// readSampleAt fills a buffer by reading from a position.
+func readSampleAt(r io.ReaderAt, buf []byte, off int64) error {
+	n, err := r.ReadAt(buf, off)
+	if err != nil {
+		return err
+	}
+	if n != len(buf) {
+		return fmt.Errorf("partial read")
+	}
+	return nil
+}
+
+// readSamplesAtEach fills buffers with consecutive data by reading them one at a time.
+func readSamplesAtEach(r io.ReaderAt, off int64, bufs [][]byte) error {
+	for _, buf := range bufs {
+		err := readSampleAt(r, buf, off)
+		if err != nil {
+			return err
+		}
+		off += int64(len(buf))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+//go:build linux
+
+package playback
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// maximum number of samples read with a single system call.
+// It is lower than IOV_MAX (1024).
+const sampleReadBatchMaxCount = 64
+
+// unwrapFile returns the file that a reader reads from,
+// together with the offset and the size of the readable area.
+func unwrapFile(r io.ReaderAt) (*os.File, int64, int64, bool) {
+	switch v := r.(type) {
+	case *os.File:
+		return v, 0, math.MaxInt64, true
+
+	case *io.SectionReader:
+		outer, off, n := v.Outer()
+		f, ok := outer.(*os.File)
+		return f, off, n, ok
+	}
+
+	return nil, 0, 0, false
+}
+
+// readSamplesAt fills buffers with consecutive data that starts from a position.
+// When reading from a file, all buffers are filled with a single preadv call,
+// that reduces system calls without sharing memory between samples.
+// io_uring is not used, since it requires a ring per reader and a considerable amount
+// of code, while preadv already provides most of the gain.
+func readSamplesAt(r io.ReaderAt, off int64, bufs [][]byte) error {
+	total := int64(0)
+	for _, buf := range bufs {
+		total += int64(len(buf))
+	}
+
+	f, base, limit, ok := unwrapFile(r)
+	if !ok || off+total > limit {
+		return readSamplesAtEach(r, off, bufs)
+	}
+
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return readSamplesAtEach(r, off, bufs)
+	}
+
+	var n int
+	var rerr error
+
+	err = rc.Read(func(fd uintptr) bool {
+		for {
+			n, rerr = unix.Preadv(int(fd), bufs, base+off)
+			if !errors.Is(rerr, unix.EINTR) {
+				return true
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if rerr != nil {
+		return rerr
+	}
+
+	// complete short reads
+	for _, buf := range bufs {
+		if n >= len(buf) {
+			n -= len(buf)
+			off += int64(len(buf))
+			continue
+		}
+
+		err = readSampleAt(r, buf[n:], off+int64(n))
+		if err != nil {
+			return err
+		}
+
+		off += int64(len(buf))
+		n = 0
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package playback
+
+import (
+	"io"
+)
+
+// samples are read one at a time.
+const sampleReadBatchMaxCount = 1
+
+func readSamplesAt(r io.ReaderAt, off int64, bufs [][]byte) error {
+	return readSamplesAtEach(r, off, bufs)
+}
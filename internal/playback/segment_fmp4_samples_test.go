@@ -0,0 +1,74 @@
+package playback
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abema/go-mp4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentFMP4SampleReader(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	entries := make([]mp4.TrunEntry, 100)
+	var data []byte
+	for i := range entries {
+		entries[i].SampleSize = uint32(i%7 + 1)
+		data = append(data, bytes.Repeat([]byte{byte(i)}, int(entries[i].SampleSize))...)
+	}
+
+	// samples start after a header
+	content := append([]byte{0xAA, 0xBB}, data...)
+
+	fpath := filepath.Join(dir, "segment")
+	err = os.WriteFile(fpath, content, 0o644)
+	require.NoError(t, err)
+
+	f, err := os.Open(fpath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, ca := range []struct {
+		name string
+		r    io.ReaderAt
+	}{
+		{"file", f},
+		{"section", io.NewSectionReader(f, 0, int64(len(content)))},
+		{"memory", bytes.NewReader(content)},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			sr := newSegmentFMP4SampleReader(ca.r, 2, entries)
+
+			// samples can be requested in any order
+			order := []int{0, 1, 2, 50, 3, 99, 98}
+			for i := 4; i < 98; i++ {
+				if i != 50 {
+					order = append(order, i)
+				}
+			}
+
+			for _, i := range order {
+				payload, err := sr.read(i)
+				require.NoError(t, err)
+				require.Equal(t, bytes.Repeat([]byte{byte(i)}, i%7+1), payload)
+			}
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		sr := newSegmentFMP4SampleReader(io.NewSectionReader(f, 0, 20), 2, entries)
+
+		payload, err := sr.read(0)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0}, payload)
+
+		_, err = sr.read(5)
+		require.Error(t, err)
+	})
+}
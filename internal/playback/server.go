@@ -4,74 +4,232 @@ package playback
 import (
 	"net"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/bluenviron/mediamtx/internal/audit"
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/restrictnetwork"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	apiVersion      = "v1"
+	requestIDKey    = "requestID"
+	requestIDMaxLen = 128
 )
 
 type serverAuthManager interface {
 	Authenticate(req *auth.Request) error
 }
 
+type serverPathManager interface {
+	APIPathsList() (*defs.APIPathList, error)
+}
+
 // Server is the playback server.
 type Server struct {
-	Address        string
-	Encryption     bool
-	ServerKey      string
-	ServerCert     string
-	AllowOrigin    string
-	TrustedProxies conf.IPNetworks
-	ReadTimeout    conf.Duration
-	PathConfs      map[string]*conf.Path
-	AuthManager    serverAuthManager
-	Parent         logger.Writer
+	conf.Playback
+	AuthMethod      conf.AuthMethod
+	ReadTimeout     conf.Duration
+	WriteTimeout    conf.Duration
+	PathConfs       map[string]*conf.Path
+	AuthManager     serverAuthManager
+	PathManager     serverPathManager
+	AuditLog        *audit.Log
+	ExternalCmdPool *externalcmd.Pool
+	Version         string
+	Parent          logger.Writer
 
-	httpServer *httpp.Server
-	mutex      sync.RWMutex
+	httpServers     []*httpp.Server
+	budget          *memoryBudget
+	interactiveLane *lane
+	bulkLane        *lane
+	downloadRate    *downloadRateLimiter
+	peerClient      *http.Client
+	authCache       *authCache
+	bans            *banList
+	load            *loadShedder
+	logIPs          *ipAnonymizer
+	removeFaults    func()
+	mutex           sync.RWMutex
 }
 
 // Initialize initializes Server.
 func (s *Server) Initialize() error {
+	s.logIPs = &ipAnonymizer{mode: s.LogIPs}
+	err := s.logIPs.initialize()
+	if err != nil {
+		return err
+	}
+
+	s.budget = &memoryBudget{max: uint64(s.MemoryBudget)}
+	s.interactiveLane = newLane("interactive", s.MaxInteractive)
+	s.bulkLane = newLane("bulk", s.MaxBulk)
+	s.downloadRate = &downloadRateLimiter{max: s.RateLimit}
+	s.downloadRate.initialize()
+	s.authCache = &authCache{
+		ttl:       time.Duration(s.AuthCacheTTL),
+		withQuery: s.AuthMethod == conf.AuthMethodHTTP,
+	}
+	s.authCache.initialize()
+	s.bans = &banList{}
+	s.bans.initialize()
+	s.load = &loadShedder{
+		pathManager: s.PathManager,
+		threshold:   uint64(s.LoadThreshold),
+		parent:      s,
+	}
+	s.load.initialize()
+	s.peerClient = &http.Client{
+		Transport: &http.Transport{
+			ResponseHeaderTimeout: time.Duration(s.ReadTimeout),
+		},
+	}
+
 	router := gin.New()
 	router.SetTrustedProxies(s.TrustedProxies.ToTrustedProxies()) //nolint:errcheck
 
+	router.Use(s.middlewareRequestID)
 	router.Use(s.middlewareOrigin)
+	router.Use(s.middlewareCompression)
 
-	router.GET("/list", s.onList)
-	router.GET("/get", s.onGet)
-
-	network, address := restrictnetwork.Restrict("tcp", s.Address)
+	router.GET("/version", s.onVersion)
 
-	s.httpServer = &httpp.Server{
-		Network:     network,
-		Address:     address,
-		ReadTimeout: time.Duration(s.ReadTimeout),
-		Encryption:  s.Encryption,
-		ServerCert:  s.ServerCert,
-		ServerKey:   s.ServerKey,
-		Handler:     router,
-		Parent:      s,
+	// routes are exposed under the current API version and,
+	// for backward compatibility, without any prefix.
+	for _, group := range []*gin.RouterGroup{
+		router.Group("/" + apiVersion),
+		&router.RouterGroup,
+	} {
+		group.GET("/ui", s.onUI)
+		group.GET("/bans", s.onBansList)
+		group.DELETE("/bans", s.onBansRemove)
+		group.GET("/audit", s.onAuditList)
+		group.GET("/grafana", s.onGrafanaTest)
+		group.POST("/grafana/search", s.onGrafanaSearch)
+		group.POST("/grafana/query", s.onGrafanaQuery)
+		group.GET("/list", s.middlewareValidate(listParams), s.onList)
+		group.GET("/get", s.middlewareAudit(audit.ActionExport), s.middlewareValidate(getParams), s.onGet)
+		group.POST("/get", s.middlewareAudit(audit.ActionExport), s.onGetRanges)
+		group.GET("/info", s.middlewareValidate(infoParams), s.onInfo)
+		group.GET("/estimate", s.middlewareValidate(estimateParams), s.onEstimate)
+		group.GET("/timestamps", s.middlewareValidate(timestampsParams), s.onTimestamps)
+		group.GET("/stats", s.middlewareValidate(statsParams), s.onStats)
+		group.GET("/init", s.middlewareValidate(initParams), s.onInit)
+		group.GET("/segment", s.middlewareAudit(audit.ActionExport), s.middlewareValidate(segmentParams), s.onSegment)
+		group.GET("/archive", s.middlewareAudit(audit.ActionExport), s.middlewareValidate(archiveParams), s.onArchive)
+		group.POST("/batch", s.middlewareAudit(audit.ActionExport), s.onGetBatch)
+		group.POST("/compact", s.middlewareWritable, s.middlewareNoFaults, s.middlewareAudit(audit.ActionCompact),
+			s.middlewareValidate(compactParams), s.onCompact)
+		group.POST("/import", s.middlewareWritable, s.middlewareNoFaults, s.middlewareAudit(audit.ActionImport),
+			s.middlewareValidate(importParams), s.onImport)
+		group.DELETE("/recordings", s.middlewareWritable, s.middlewareNoFaults, s.middlewareAudit(audit.ActionDelete),
+			s.middlewareValidate(deleteParams), s.onDelete)
+		group.GET("/holds", s.middlewareValidate(holdsListParams), s.onHoldsList)
+		group.POST("/holds", s.middlewareWritable, s.middlewareAudit(audit.ActionHoldAdd),
+			s.middlewareValidate(holdsAddParams), s.onHoldsAdd)
+		group.DELETE("/holds", s.middlewareWritable, s.middlewareAudit(audit.ActionHoldRemove),
+			s.middlewareValidate(holdsRemoveParams), s.onHoldsRemove)
+		group.GET("/tags", s.middlewareValidate(tagsListParams), s.onTagsList)
+		group.POST("/tags", s.middlewareWritable, s.middlewareAudit(audit.ActionTagAdd),
+			s.middlewareValidate(tagsAddParams), s.onTagsAdd)
+		group.DELETE("/tags", s.middlewareWritable, s.middlewareAudit(audit.ActionTagRemove),
+			s.middlewareValidate(tagsRemoveParams), s.onTagsRemove)
 	}
-	err := s.httpServer.Initialize()
-	if err != nil {
-		return err
+
+	if s.faultsEnabled() {
+		s.Log(logger.Warn, "fault injection is enabled, recordings will be served with errors")
+
+		var err error
+		s.removeFaults, err = recordstore.InjectFaults(&recordstore.Faults{
+			OpenDelay:    time.Duration(s.FaultDelay),
+			OpenFailures: s.FaultFailures,
+			CorruptReads: s.FaultCorrupt,
+		})
+		if err != nil {
+			s.load.close()
+			return err
+		}
 	}
 
-	s.Log(logger.Info, "listener opened on "+address)
+	for _, entry := range strings.Split(s.Address, ",") {
+		network, address := listenerNetwork(strings.TrimSpace(entry))
+
+		// remove the socket left by a previous instance
+		if fi, err := os.Lstat(address); network == "unix" && err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+
+		httpServer := &httpp.Server{
+			Network:          network,
+			Address:          address,
+			ReadTimeout:      time.Duration(s.ReadTimeout),
+			Encryption:       s.Encryption,
+			ServerCert:       s.ServerCert,
+			ServerKey:        s.ServerKey,
+			UnencryptedHTTP2: s.HTTP2,
+			DisableHTTP2:     !s.HTTP2,
+			ProxyProtocol:    s.ProxyProtocol,
+			Handler:          router,
+			Parent:           s,
+
+			// the load balancer is a trusted proxy
+			ProxyProtocolTrusted: s.TrustedProxies,
+		}
+		if s.LogIPs != conf.PlaybackLogIPsFull {
+			httpServer.AnonymizeIP = s.logIPs.ip
+		}
+		err := httpServer.Initialize()
+		if err != nil {
+			for _, hs := range s.httpServers {
+				hs.Close()
+			}
+			s.load.close()
+			if s.removeFaults != nil {
+				s.removeFaults()
+			}
+			return err
+		}
+
+		s.httpServers = append(s.httpServers, httpServer)
+
+		s.Log(logger.Info, "listener opened on "+entry)
+	}
 
 	return nil
 }
 
+// listenerNetwork returns network and address of a listener.
+// Addresses that start with unix:// are Unix sockets.
+func listenerNetwork(entry string) (string, string) {
+	if path, ok := strings.CutPrefix(entry, "unix://"); ok {
+		return "unix", path
+	}
+	return restrictnetwork.Restrict("tcp", entry)
+}
+
 // Close closes Server.
 func (s *Server) Close() {
 	s.Log(logger.Info, "listener is closing")
-	s.httpServer.Close()
+	for _, hs := range s.httpServers {
+		hs.Close()
+	}
+	s.load.close()
+	s.peerClient.CloseIdleConnections()
+
+	if s.removeFaults != nil {
+		s.removeFaults()
+	}
 }
 
 // Log implements logger.Writer.
@@ -86,14 +244,43 @@ func (s *Server) ReloadPathConfs(pathConfs map[string]*conf.Path) {
 	s.PathConfs = pathConfs
 }
 
+// ReloadCredentials is called when users or API keys are reloaded.
+func (s *Server) ReloadCredentials() {
+	s.authCache.flush()
+}
+
+// logRequest writes a log entry related to a request.
+func (s *Server) logRequest(ctx *gin.Context, level logger.Level, format string, args ...interface{}) {
+	s.Log(level, "[req %s] "+format, append([]interface{}{ctx.GetString(requestIDKey)}, args...)...)
+}
+
 func (s *Server) writeError(ctx *gin.Context, status int, err error) {
 	// show error in logs
-	s.Log(logger.Error, err.Error())
+	s.logRequest(ctx, logger.Error, err.Error())
 
-	// add error to response
+	// add error to response and to the audit log
+	ctx.Error(err) //nolint:errcheck
 	ctx.String(status, err.Error())
 }
 
+// routePrefix returns the prefix of the route that has been used to reach a handler.
+func routePrefix(ctx *gin.Context) string {
+	if strings.HasPrefix(ctx.FullPath(), "/"+apiVersion+"/") {
+		return "/" + apiVersion
+	}
+	return ""
+}
+
+func (s *Server) onVersion(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, struct {
+		Version     string   `json:"version"`
+		APIVersions []string `json:"apiVersions"`
+	}{
+		Version:     s.Version,
+		APIVersions: []string{apiVersion},
+	})
+}
+
 func (s *Server) safeFindPathConf(name string) (*conf.Path, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -102,27 +289,145 @@ func (s *Server) safeFindPathConf(name string) (*conf.Path, error) {
 	return pathConf, err
 }
 
+// originMatches checks whether an origin matches a pattern.
+// The pattern can contain one or more wildcards (*), each one matching any sequence of characters.
+func originMatches(pattern string, origin string) bool {
+	parts := strings.Split(pattern, "*")
+
+	if len(parts) == 1 {
+		return pattern == origin
+	}
+
+	if !strings.HasPrefix(origin, parts[0]) {
+		return false
+	}
+	origin = origin[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(origin, part)
+		if i < 0 {
+			return false
+		}
+		origin = origin[i+len(part):]
+	}
+
+	return len(origin) >= len(last) && strings.HasSuffix(origin, last)
+}
+
+func (s *Server) allowedOrigin(origin string) string {
+	for _, pattern := range s.AllowOrigins {
+		if pattern == "*" {
+			return "*"
+		}
+	}
+
+	if origin == "" {
+		return ""
+	}
+
+	for _, pattern := range s.AllowOrigins {
+		if originMatches(pattern, origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// requestIDIsValid checks whether a request ID provided by a client or proxy can be used.
+func requestIDIsValid(id string) bool {
+	if id == "" || len(id) > requestIDMaxLen {
+		return false
+	}
+
+	for _, c := range id {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') &&
+			c != '-' && c != '_' && c != '.' && c != ':' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// middlewareRequestID assigns an ID to each request.
+// IDs provided by clients are accepted only from trusted proxies,
+// in the same way of X-Forwarded-For, in order to prevent clients from
+// forging log entries or impersonating other requests.
+func (s *Server) middlewareRequestID(ctx *gin.Context) {
+	var id string
+	if s.fromTrustedProxy(ctx) {
+		id = ctx.Request.Header.Get("X-Request-ID")
+	}
+
+	if !requestIDIsValid(id) {
+		id = uuid.New().String()
+	}
+
+	ctx.Set(requestIDKey, id)
+	ctx.Header("X-Request-ID", id)
+}
+
+// headers of responses that can be read by cross-origin clients,
+// in addition to the CORS-safelisted ones.
+var exposeHeaders = []string{
+	"Content-Disposition",
+	"ETag",
+	"Retry-After",
+	"X-Playback-Actual-Start",
+	"X-Playback-Bytes-Written",
+	"X-Playback-Lane",
+	"X-Playback-Media-Time",
+	"X-Playback-Segment-Start",
+	"X-Request-ID",
+}
+
 func (s *Server) middlewareOrigin(ctx *gin.Context) {
-	ctx.Header("Access-Control-Allow-Origin", s.AllowOrigin)
-	ctx.Header("Access-Control-Allow-Credentials", "true")
+	origin := s.allowedOrigin(ctx.Request.Header.Get("Origin"))
+
+	if origin != "*" {
+		ctx.Writer.Header().Add("Vary", "Origin")
+	}
+
+	if origin != "" {
+		ctx.Header("Access-Control-Allow-Origin", origin)
+		ctx.Header("Access-Control-Allow-Credentials", "true")
+		ctx.Header("Access-Control-Expose-Headers", strings.Join(exposeHeaders, ", "))
+	}
 
 	// preflight requests
 	if ctx.Request.Method == http.MethodOptions &&
 		ctx.Request.Header.Get("Access-Control-Request-Method") != "" {
-		ctx.Header("Access-Control-Allow-Methods", "OPTIONS, GET")
-		ctx.Header("Access-Control-Allow-Headers", "Authorization")
+		if origin != "" {
+			ctx.Header("Access-Control-Allow-Methods", strings.Join(s.AllowMethods, ", "))
+			ctx.Header("Access-Control-Allow-Headers", strings.Join(s.AllowHeaders, ", "))
+		}
 		ctx.AbortWithStatus(http.StatusNoContent)
 		return
 	}
 }
 
-func (s *Server) doAuth(ctx *gin.Context, pathName string) bool {
+func (s *Server) doAuth(ctx *gin.Context, action conf.AuthAction, pathName string) bool {
 	req := &auth.Request{
-		Action:      conf.AuthActionPlayback,
+		Action:      action,
 		Path:        pathName,
 		Query:       ctx.Request.URL.RawQuery,
 		Credentials: httpp.Credentials(ctx.Request),
-		IP:          net.ParseIP(ctx.ClientIP()),
+		IP:          net.ParseIP(httpp.ClientIP(ctx)),
+	}
+
+	if d := s.bans.bannedFor(httpp.ClientIP(ctx)); d > 0 {
+		writeBanned(ctx, d)
+		return false
+	}
+
+	if s.authCache.get(req) {
+		if req.APIKeyName != "" {
+			audit.SetActor(ctx, req.APIKeyName)
+		}
+		return true
 	}
 
 	err := s.AuthManager.Authenticate(req)
@@ -133,15 +438,24 @@ func (s *Server) doAuth(ctx *gin.Context, pathName string) bool {
 			return false
 		}
 
-		s.Log(logger.Info, "connection %v failed to authenticate: %v",
-			httpp.RemoteAddr(ctx), err.(*auth.Error).Message) //nolint:errorlint
+		s.logRequest(ctx, logger.Info, "connection %v failed to authenticate: %v",
+			s.logAddr(ctx), err.(auth.Error).Wrapped) //nolint:errorlint
 
-		// wait some seconds to mitigate brute force attacks
-		<-time.After(auth.PauseAfterError)
+		// ban the IP after too many failures, in order to mitigate brute force attacks
+		if d := s.bans.addFailure(httpp.ClientIP(ctx)); d > 0 {
+			s.logRequest(ctx, logger.Warn, "banning %s for %v", s.logIP(ctx), d)
+		}
 
 		ctx.Writer.WriteHeader(http.StatusUnauthorized)
 		return false
 	}
 
+	s.bans.resetFailures(httpp.ClientIP(ctx))
+	s.authCache.add(req)
+
+	if req.APIKeyName != "" {
+		audit.SetActor(ctx, req.APIKeyName)
+	}
+
 	return true
 }
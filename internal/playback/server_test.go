@@ -1,8 +1,15 @@
 package playback
 
 import (
+	"context"
+	"encoding/json"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,8 +20,12 @@ import (
 
 func TestPreflightRequest(t *testing.T) {
 	s := &Server{
-		Address:     "127.0.0.1:9996",
-		AllowOrigin: "*",
+		Playback: conf.Playback{
+			Address:      "127.0.0.1:9996",
+			AllowOrigins: []string{"*"},
+			AllowHeaders: []string{"Authorization"},
+			AllowMethods: []string{"OPTIONS", "GET"},
+		},
 		ReadTimeout: conf.Duration(10 * time.Second),
 		Parent:      test.NilLogger,
 	}
@@ -46,3 +57,385 @@ func TestPreflightRequest(t *testing.T) {
 	require.Equal(t, "Authorization", res.Header.Get("Access-Control-Allow-Headers"))
 	require.Equal(t, byts, []byte{})
 }
+
+func TestPreflightRequestOriginList(t *testing.T) {
+	s := &Server{
+		Playback: conf.Playback{
+			Address:      "127.0.0.1:9996",
+			AllowOrigins: []string{"http://myhost1", "https://*.myhost2.com"},
+			AllowHeaders: []string{"Authorization", "Content-Type"},
+			AllowMethods: []string{"OPTIONS", "GET", "POST"},
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		Parent:      test.NilLogger,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for _, ca := range []struct {
+		origin  string
+		allowed bool
+	}{
+		{"http://myhost1", true},
+		{"https://sub.myhost2.com", true},
+		{"https://myhost2.com", false},
+		{"http://other", false},
+	} {
+		t.Run(ca.origin, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodOptions, "http://localhost:9996", nil)
+			require.NoError(t, err)
+
+			req.Header.Add("Origin", ca.origin)
+			req.Header.Add("Access-Control-Request-Method", "GET")
+
+			res, err := hc.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusNoContent, res.StatusCode)
+			require.Equal(t, "Origin", res.Header.Get("Vary"))
+
+			if ca.allowed {
+				require.Equal(t, ca.origin, res.Header.Get("Access-Control-Allow-Origin"))
+				require.Equal(t, "true", res.Header.Get("Access-Control-Allow-Credentials"))
+				require.Equal(t, "OPTIONS, GET, POST", res.Header.Get("Access-Control-Allow-Methods"))
+				require.Equal(t, "Authorization, Content-Type", res.Header.Get("Access-Control-Allow-Headers"))
+			} else {
+				require.Equal(t, "", res.Header.Get("Access-Control-Allow-Origin"))
+				require.Equal(t, "", res.Header.Get("Access-Control-Allow-Methods"))
+			}
+		})
+	}
+}
+
+func TestExposeHeaders(t *testing.T) {
+	s := &Server{
+		Playback: conf.Playback{
+			Address:      "127.0.0.1:9996",
+			AllowOrigins: []string{"*"},
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		Version:     "v1.2.3",
+		Parent:      test.NilLogger,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:9996/version", nil)
+	require.NoError(t, err)
+
+	req.Header.Add("Origin", "http://myhost")
+
+	res, err := hc.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "Content-Disposition, ETag, Retry-After, X-Playback-Actual-Start, X-Playback-Bytes-Written, "+
+		"X-Playback-Lane, X-Playback-Media-Time, X-Playback-Segment-Start, X-Request-ID",
+		res.Header.Get("Access-Control-Expose-Headers"))
+}
+
+func TestVersion(t *testing.T) {
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		Version:     "v1.2.3",
+		Parent:      test.NilLogger,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	res, err := hc.Get("http://localhost:9996/version")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var out map[string]interface{}
+	err = json.NewDecoder(res.Body).Decode(&out)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]interface{}{
+		"version":     "v1.2.3",
+		"apiVersions": []interface{}{"v1"},
+	}, out)
+}
+
+func TestUI(t *testing.T) {
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	res, err := hc.Get("http://localhost:9996/ui?path=mypath")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/html", res.Header.Get("Content-Type"))
+
+	buf, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, uiPage, buf)
+}
+
+func TestMultipleAddresses(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "playback.sock")
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996, unix://" + socketPath,
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		Version:     "v1.2.3",
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	for _, ca := range []string{"tcp", "unix"} {
+		t.Run(ca, func(t *testing.T) {
+			tr := &http.Transport{}
+			if ca == "unix" {
+				tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+				}
+			}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			res, err := hc.Get("http://localhost:9996/version")
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		})
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment1(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address: "127.0.0.1:9996",
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for _, prefix := range []string{"", "/v1"} {
+		t.Run("prefix "+prefix, func(t *testing.T) {
+			res, err := hc.Get("http://localhost:9996" + prefix + "/list?path=mypath")
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			var out []map[string]interface{}
+			err = json.NewDecoder(res.Body).Decode(&out)
+			require.NoError(t, err)
+			require.Len(t, out, 1)
+
+			u, err := url.Parse(out[0]["url"].(string))
+			require.NoError(t, err)
+			require.Equal(t, prefix+"/get", u.Path)
+
+			res2, err := hc.Get(u.String())
+			require.NoError(t, err)
+			defer res2.Body.Close()
+
+			require.Equal(t, http.StatusOK, res2.StatusCode)
+
+			for _, route := range []string{"/bans", "/grafana"} {
+				func() {
+					res3, err2 := hc.Get("http://localhost:9996" + prefix + route)
+					require.NoError(t, err2)
+					defer res3.Body.Close()
+
+					require.Equal(t, http.StatusOK, res3.StatusCode)
+				}()
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	for _, ca := range []struct {
+		name    string
+		trusted bool
+		inbound string
+	}{
+		{"generated", true, ""},
+		{"inbound", true, "my-proxy-id.123"},
+		{"invalid inbound", true, "invalid id"},
+		{"too long inbound", true, strings.Repeat("a", 129)},
+		{"untrusted inbound", false, "my-proxy-id.123"},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			s := &Server{
+				Playback: conf.Playback{
+					Address: "127.0.0.1:9996",
+				},
+				ReadTimeout: conf.Duration(10 * time.Second),
+				Parent:      test.NilLogger,
+			}
+			if ca.trusted {
+				s.TrustedProxies = conf.IPNetworks{{IP: net.IPv4(127, 0, 0, 1).To4(), Mask: net.CIDRMask(32, 32)}}
+			}
+			err := s.Initialize()
+			require.NoError(t, err)
+			defer s.Close()
+
+			tr := &http.Transport{}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:9996/version", nil)
+			require.NoError(t, err)
+
+			if ca.inbound != "" {
+				req.Header.Set("X-Request-ID", ca.inbound)
+			}
+
+			res, err := hc.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			id := res.Header.Get("X-Request-ID")
+
+			if ca.name == "inbound" {
+				require.Equal(t, ca.inbound, id)
+			} else {
+				require.Len(t, id, 36)
+			}
+		})
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	s := &Server{
+		Playback: conf.Playback{
+			Address:       "127.0.0.1:9996",
+			FaultFailures: 100,
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:       "mypath",
+				RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			},
+		},
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	v := url.Values{}
+	v.Set("path", "mypath")
+	v.Set("start", time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano))
+	v.Set("duration", "2")
+
+	res, err := hc.Get("http://localhost:9996/get?" + v.Encode())
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	buf, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(buf), "injected fault")
+
+	// recordings can't be rewritten with faulty data
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:9996/recordings?"+v.Encode(), nil)
+	require.NoError(t, err)
+
+	res2, err := hc.Do(req)
+	require.NoError(t, err)
+	defer res2.Body.Close()
+
+	require.Equal(t, http.StatusForbidden, res2.StatusCode)
+
+	// a second server can't inject faults at the same time
+	s2 := &Server{
+		Playback: conf.Playback{
+			Address:       "127.0.0.1:9997",
+			FaultFailures: 100,
+		},
+		ReadTimeout: conf.Duration(10 * time.Second),
+		AuthManager: test.NilAuthManager,
+		Parent:      test.NilLogger,
+	}
+	err = s2.Initialize()
+	require.EqualError(t, err, "faults are already being injected")
+}
@@ -0,0 +1,66 @@
+package playback
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// stallWriter detects clients that don't read the response in time.
+// Depending on the configured action, a stalled client is disconnected
+// or the server keeps waiting for it. In both cases, the stall is logged.
+type stallWriter struct {
+	s   *Server
+	ctx *gin.Context
+	w   io.Writer
+	rc  *http.ResponseController
+}
+
+func (s *Server) newStallWriter(ctx *gin.Context, w io.Writer) io.Writer {
+	if s.WriteTimeout == 0 {
+		return w
+	}
+
+	return &stallWriter{
+		s:   s,
+		ctx: ctx,
+		w:   w,
+		rc:  http.NewResponseController(ctx.Writer),
+	}
+}
+
+func (w *stallWriter) Write(p []byte) (int, error) {
+	timeout := time.Duration(w.s.WriteTimeout)
+
+	if w.s.StallAction == conf.PlaybackStallActionAbort {
+		w.rc.SetWriteDeadline(time.Now().Add(timeout)) //nolint:errcheck
+
+		n, err := w.w.Write(p)
+		if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+			w.s.logRequest(w.ctx, logger.Warn, "client %s stalled for more than %v, aborting",
+				w.s.logIP(w.ctx), timeout)
+		}
+		return n, err
+	}
+
+	stallStart := time.Now()
+	t := time.AfterFunc(timeout, func() {
+		w.s.logRequest(w.ctx, logger.Warn, "client %s stalled for more than %v, waiting",
+			w.s.logIP(w.ctx), timeout)
+	})
+
+	n, err := w.w.Write(p)
+
+	if !t.Stop() {
+		w.s.logRequest(w.ctx, logger.Info, "client %s resumed reading after %v",
+			w.s.logIP(w.ctx), time.Since(stallStart))
+	}
+
+	return n, err
+}
@@ -0,0 +1,146 @@
+package playback
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func writeLargeSegment(t *testing.T, fpath string) {
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{
+			{
+				ID:        1,
+				TimeScale: 90000,
+				Codec: &mp4.CodecH264{
+					SPS: test.FormatH264.SPS,
+					PPS: test.FormatH264.PPS,
+				},
+			},
+		},
+	}
+
+	var buf1 seekablebuffer.Buffer
+	err := init.Marshal(&buf1)
+	require.NoError(t, err)
+
+	var samples []*fmp4.Sample
+	for i := 0; i < 30; i++ {
+		samples = append(samples, &fmp4.Sample{
+			Duration: 90000,
+			Payload:  bytes.Repeat([]byte{1}, 1024*1024),
+		})
+	}
+
+	var buf2 seekablebuffer.Buffer
+	parts := fmp4.Parts{{
+		Tracks: []*fmp4.PartTrack{{
+			ID:      1,
+			Samples: samples,
+		}},
+	}}
+	err = parts.Marshal(&buf2)
+	require.NoError(t, err)
+
+	err = os.WriteFile(fpath, append(buf1.Bytes(), buf2.Bytes()...), 0o644)
+	require.NoError(t, err)
+}
+
+func TestStall(t *testing.T) {
+	for _, ca := range []string{"abort", "throttle"} {
+		t.Run(ca, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mediamtx-playback")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+			require.NoError(t, err)
+
+			writeLargeSegment(t, filepath.Join(dir, "mypath", "2008-11-07_11-22-00-500000.mp4"))
+
+			var stallAction conf.PlaybackStallAction
+			var expected []string
+
+			if ca == "abort" {
+				stallAction = conf.PlaybackStallActionAbort
+				expected = []string{"stalled for more than 500ms, aborting"}
+			} else {
+				stallAction = conf.PlaybackStallActionThrottle
+				expected = []string{"stalled for more than 500ms, waiting", "resumed reading"}
+			}
+
+			logged := make(chan string, 10)
+
+			s := &Server{
+				Playback: conf.Playback{
+					Address:     "127.0.0.1:9996",
+					StallAction: stallAction,
+				},
+				ReadTimeout:  conf.Duration(10 * time.Second),
+				WriteTimeout: conf.Duration(500 * time.Millisecond),
+				PathConfs: map[string]*conf.Path{
+					"mypath": {
+						Name:       "mypath",
+						RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+					},
+				},
+				AuthManager: test.NilAuthManager,
+				Parent: test.Logger(func(_ logger.Level, format string, args ...interface{}) {
+					msg := fmt.Sprintf(format, args...)
+					if strings.Contains(msg, "client 127.0.0.1") {
+						select {
+						case logged <- msg:
+						default:
+						}
+					}
+				}),
+			}
+			err = s.Initialize()
+			require.NoError(t, err)
+			defer s.Close()
+
+			conn, err := net.Dial("tcp", "localhost:9996")
+			require.NoError(t, err)
+			defer conn.Close()
+
+			v := url.Values{}
+			v.Set("path", "mypath")
+			v.Set("start", time.Date(2008, 11, 0o7, 11, 22, 0, 500000000, time.Local).Format(time.RFC3339Nano))
+			v.Set("duration", "30")
+
+			// send the request and do not read the response
+			_, err = conn.Write([]byte("GET /get?" + v.Encode() + " HTTP/1.1\r\n" +
+				"Host: localhost\r\n\r\n"))
+			require.NoError(t, err)
+
+			for i, exp := range expected {
+				select {
+				case msg := <-logged:
+					require.Contains(t, msg, exp)
+				case <-time.After(10 * time.Second):
+					t.Fatal("log entry not received")
+				}
+
+				// start reading
+				if i == 0 && ca == "throttle" {
+					go io.Copy(io.Discard, conn) //nolint:errcheck
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var errInvalidRequest = errors.New("invalid request")
+
+const queryValuesKey = "queryValues"
+
+// queryParam is a query parameter accepted by a route.
+type queryParam struct {
+	name     string
+	required bool
+
+	// validate checks a value of the parameter and returns the parsed value.
+	// It is called for each value. It can be nil.
+	validate func(raw string) (interface{}, error)
+}
+
+// queryValues are the parsed values of query parameters.
+type queryValues map[string][]interface{}
+
+// queryValue returns the first parsed value of a query parameter, or nil if the parameter is not set.
+// Handlers can use it in place of parsing the query again, since parameters have already been validated.
+func queryValue(ctx *gin.Context, name string) interface{} {
+	if vals := ctx.MustGet(queryValuesKey).(queryValues)[name]; len(vals) != 0 {
+		return vals[0]
+	}
+	return nil
+}
+
+// queryArray returns all parsed values of a query parameter.
+func queryArray(ctx *gin.Context, name string) []interface{} {
+	return ctx.MustGet(queryValuesKey).(queryValues)[name]
+}
+
+// queryRule is a check that involves multiple parameters.
+// It returns the parameter that is wrong and the error.
+type queryRule func(q url.Values) (string, error)
+
+// queryParams are the query parameters accepted by a route.
+type queryParams struct {
+	// action of the route, that is authenticated before replying
+	// with validation errors.
+	action conf.AuthAction
+
+	params []queryParam
+	rules  []queryRule
+}
+
+type validationFieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+type validationErrorResponse struct {
+	Error  string                 `json:"error"`
+	Fields []validationFieldError `json:"fields"`
+}
+
+func validateTime(raw string) (interface{}, error) {
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("must be a date in RFC3339 format")
+	}
+	return t, nil
+}
+
+func validateDuration(raw string) (interface{}, error) {
+	d, err := parseDuration(raw)
+	if err != nil {
+		return nil, fmt.Errorf("must be a duration in %s", durationFormats)
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("must be greater than zero")
+	}
+	return d, nil
+}
+
+func validateUUID(raw string) (interface{}, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("must be an UUID")
+	}
+	return id, nil
+}
+
+func validateOneOf(values ...string) func(string) (interface{}, error) {
+	return func(raw string) (interface{}, error) {
+		for _, v := range values {
+			if raw == v {
+				return raw, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}
+}
+
+func validateBool(raw string) (interface{}, error) {
+	_, err := validateOneOf("true", "false")(raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw == "true", nil
+}
+
+// ruleAfter checks that a date parameter is after another one.
+func ruleAfter(name string, other string) queryRule {
+	return func(q url.Values) (string, error) {
+		t1, err1 := time.Parse(time.RFC3339, q.Get(other))
+		t2, err2 := time.Parse(time.RFC3339, q.Get(name))
+		if err1 == nil && err2 == nil && !t2.After(t1) {
+			return name, fmt.Errorf("must be after %s", other)
+		}
+		return "", nil
+	}
+}
+
+var (
+	paramPath     = queryParam{name: "path", required: true}
+	paramStart    = queryParam{name: "start", required: true, validate: validateTime}
+	paramEnd      = queryParam{name: "end", required: true, validate: validateTime}
+	paramDuration = queryParam{name: "duration", required: true, validate: validateDuration}
+	paramID       = queryParam{name: "id", required: true, validate: validateUUID}
+	paramTag      = queryParam{name: "tag", validate: func(raw string) (interface{}, error) {
+		_, _, err := parseTagFilter(raw)
+		return raw, err
+	}}
+	ruleEndAfterStart = ruleAfter("end", "start")
+)
+
+var (
+	listParams = queryParams{
+		action: conf.AuthActionPlaybackList,
+		params: []queryParam{
+			paramPath,
+			{name: "start", validate: validateTime},
+			{name: "end", validate: validateTime},
+			{name: "detail", validate: validateOneOf("timespans", "segments")},
+			paramTag,
+		},
+		rules: []queryRule{ruleEndAfterStart},
+	}
+
+	getParams = queryParams{
+		action: conf.AuthActionPlaybackDownload,
+		params: []queryParam{
+			paramPath,
+			paramStart,
+			paramDuration,
+			{name: "format", validate: validateOneOf("fmp4", "mp4", "ts")},
+			{name: "snap", validate: validateOneOf("none", "keyframe", "segment")},
+			{name: "split", validate: validateBool},
+			{name: "plan", validate: validateBool},
+			{name: "lowLatency", validate: validateBool},
+			{name: "redact", validate: func(raw string) (interface{}, error) {
+				return parseRedactRange(raw)
+			}},
+			{name: "compat", validate: func(raw string) (interface{}, error) {
+				return parseCompat(raw, conf.PlaybackCompatDefault)
+			}},
+		},
+		rules: []queryRule{
+			func(q url.Values) (string, error) {
+				if q.Get("lowLatency") != "true" {
+					return "", nil
+				}
+				if f := q.Get("format"); f != "" && f != "fmp4" {
+					return "lowLatency", fmt.Errorf("requires the fmp4 format")
+				}
+				if q.Get("split") == "true" {
+					return "lowLatency", fmt.Errorf("cannot be used with split")
+				}
+				return "", nil
+			},
+		},
+	}
+
+	infoParams = queryParams{
+		action: conf.AuthActionPlaybackList,
+		params: []queryParam{paramPath, paramStart, paramDuration},
+	}
+
+	estimateParams = queryParams{
+		action: conf.AuthActionPlaybackList,
+		params: []queryParam{
+			paramPath,
+			paramStart,
+			paramDuration,
+			{name: "format", validate: validateOneOf("fmp4", "mp4", "ts")},
+		},
+	}
+
+	timestampsParams = queryParams{
+		action: conf.AuthActionPlaybackList,
+		params: []queryParam{paramPath, paramStart, paramDuration},
+	}
+
+	statsParams = queryParams{
+		action: conf.AuthActionPlaybackList,
+		params: []queryParam{
+			paramPath,
+			paramStart,
+			paramEnd,
+			{name: "resolution", validate: validateDuration},
+		},
+		rules: []queryRule{ruleEndAfterStart},
+	}
+
+	initParams = queryParams{
+		action: conf.AuthActionPlaybackDownload,
+		params: []queryParam{
+			paramPath,
+			{name: "time", required: true, validate: validateTime},
+		},
+	}
+
+	segmentParams = queryParams{
+		action: conf.AuthActionPlaybackDownload,
+		params: []queryParam{paramPath, paramStart},
+	}
+
+	archiveParams = queryParams{
+		action: conf.AuthActionPlaybackExport,
+		params: []queryParam{paramPath, paramStart, paramEnd},
+		rules:  []queryRule{ruleEndAfterStart},
+	}
+
+	compactParams = queryParams{
+		action: conf.AuthActionPlaybackDelete,
+		params: []queryParam{
+			paramPath,
+			paramStart,
+			paramEnd,
+			{name: "maxDuration", validate: validateDuration},
+		},
+		rules: []queryRule{ruleEndAfterStart},
+	}
+
+	importParams = queryParams{
+		action: conf.AuthActionPublish,
+		params: []queryParam{paramPath, paramStart},
+	}
+
+	deleteParams = queryParams{
+		action: conf.AuthActionPlaybackDelete,
+		params: []queryParam{
+			paramPath,
+			paramStart,
+			paramEnd,
+			{name: "split", validate: validateBool},
+		},
+		rules: []queryRule{ruleEndAfterStart},
+	}
+
+	holdsListParams = queryParams{
+		action: conf.AuthActionPlaybackList,
+		params: []queryParam{paramPath},
+	}
+
+	holdsAddParams = queryParams{
+		action: conf.AuthActionPlaybackDelete,
+		params: []queryParam{paramPath, paramStart, paramEnd},
+		rules:  []queryRule{ruleEndAfterStart},
+	}
+
+	holdsRemoveParams = queryParams{
+		action: conf.AuthActionPlaybackDelete,
+		params: []queryParam{paramPath, paramID},
+	}
+
+	tagsListParams = queryParams{
+		action: conf.AuthActionPlaybackList,
+		params: []queryParam{paramPath, paramTag},
+	}
+
+	tagsAddParams = queryParams{
+		action: conf.AuthActionPlaybackDelete,
+		params: []queryParam{
+			paramPath,
+			paramStart,
+			paramEnd,
+			{name: "key", required: true, validate: func(raw string) (interface{}, error) {
+				// the colon separates keys from values in filters
+				if strings.Contains(raw, ":") {
+					return nil, fmt.Errorf("must not contain ':'")
+				}
+				return raw, nil
+			}},
+		},
+		rules: []queryRule{ruleEndAfterStart},
+	}
+
+	tagsRemoveParams = queryParams{
+		action: conf.AuthActionPlaybackDelete,
+		params: []queryParam{paramPath, paramID},
+	}
+)
+
+// validate checks query parameters and returns their parsed values
+// and an error for each wrong parameter.
+func (p *queryParams) validate(q url.Values) (queryValues, []validationFieldError) {
+	parsed := make(queryValues)
+	var out []validationFieldError
+
+	for _, param := range p.params {
+		values, ok := q[param.name]
+
+		if !ok || (len(values) == 1 && values[0] == "") {
+			if param.required {
+				out = append(out, validationFieldError{Field: param.name, Error: "is required"})
+			}
+			continue
+		}
+
+		for _, v := range values {
+			if param.validate == nil {
+				parsed[param.name] = append(parsed[param.name], v)
+				continue
+			}
+
+			pv, err := param.validate(v)
+			if err != nil {
+				out = append(out, validationFieldError{Field: param.name, Error: err.Error()})
+				break
+			}
+			parsed[param.name] = append(parsed[param.name], pv)
+		}
+	}
+
+	// rules are checked only when single parameters are valid
+	if out != nil {
+		return nil, out
+	}
+
+	for _, rule := range p.rules {
+		if field, err := rule(q); err != nil {
+			out = append(out, validationFieldError{Field: field, Error: err.Error()})
+		}
+	}
+
+	if out != nil {
+		return nil, out
+	}
+
+	return parsed, nil
+}
+
+// middlewareValidate checks query parameters before a request reaches its handler,
+// replying with the list of parameters that are missing or wrong.
+// Users are authenticated before receiving the list, in order not to expose
+// the API to unauthenticated users. Valid requests are authenticated by handlers.
+func (s *Server) middlewareValidate(p queryParams) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		parsed, fields := p.validate(ctx.Request.URL.Query())
+		if fields == nil {
+			ctx.Set(queryValuesKey, parsed)
+			return
+		}
+
+		if !s.doAuth(ctx, p.action, ctx.Query("path")) {
+			ctx.Abort()
+			return
+		}
+
+		msgs := make([]string, len(fields))
+		for i, f := range fields {
+			msgs[i] = f.Field + " " + f.Error
+		}
+		err := fmt.Errorf("%w: %s", errInvalidRequest, strings.Join(msgs, ", "))
+
+		// show error in logs and in the audit log
+		s.logRequest(ctx, logger.Error, err.Error())
+		ctx.Error(err) //nolint:errcheck
+
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, validationErrorResponse{
+			Error:  errInvalidRequest.Error(),
+			Fields: fields,
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package playback
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+// segmentsModTime returns the most recent modification time of segments.
+func segmentsModTime(segments []*recordstore.Segment) time.Time {
+	var modTime time.Time
+
+	for _, seg := range segments {
+		fi, err := os.Stat(seg.Fpath)
+		if err != nil {
+			return time.Time{}
+		}
+
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+	}
+
+	return modTime
+}
+
+// notModified checks whether the client already owns the current version of a response.
+// If-None-Match takes precedence over If-Modified-Since and uses the weak comparison.
+func notModified(req *http.Request, etag string, modTime time.Time) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err == nil && !modTime.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeJSONWithValidators writes a JSON response together with an ETag,
+// that is computed from the content, and with a Last-Modified header, when modTime is known.
+// The ETag is weak since the same content is sent with different content codings.
+// Clients that already own the response receive status 304.
+func (s *Server) writeJSONWithValidators(ctx *gin.Context, v interface{}, modTime time.Time) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	sum := sha256.Sum256(buf)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	ctx.Header("ETag", etag)
+	if !modTime.IsZero() {
+		ctx.Header("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(ctx.Request, etag, modTime) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", buf)
+}
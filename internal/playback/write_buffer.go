@@ -0,0 +1,39 @@
+package playback
+
+import (
+	"bufio"
+	"io"
+)
+
+// writeBuffer coalesces small writes of muxers (boxes, samples of MP4 files, short parts)
+// into bigger ones, in order to reduce the number of system calls.
+type writeBuffer struct {
+	w  io.Writer
+	bw *bufio.Writer
+}
+
+func (s *Server) newWriteBuffer(w io.Writer, lowLatency bool) *writeBuffer {
+	b := &writeBuffer{w: w}
+
+	// in low-latency mode, data is sent to the client immediately
+	if s.WriteBuffer != 0 && !lowLatency {
+		b.bw = bufio.NewWriterSize(w, int(s.WriteBuffer))
+	}
+
+	return b
+}
+
+func (b *writeBuffer) Write(p []byte) (int, error) {
+	if b.bw == nil {
+		return b.w.Write(p)
+	}
+	return b.bw.Write(p)
+}
+
+// flush sends buffered data to the underlying writer.
+func (b *writeBuffer) flush() error {
+	if b.bw == nil {
+		return nil
+	}
+	return b.bw.Flush()
+}
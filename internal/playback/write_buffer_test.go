@@ -0,0 +1,120 @@
+package playback
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+type countingWrites struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWrites) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestWriteBuffer(t *testing.T) {
+	for _, ca := range []struct {
+		name       string
+		size       conf.StringSize
+		lowLatency bool
+		writes     int
+	}{
+		{"disabled", 0, false, 100},
+		{"enabled", 1024, false, 1},
+		{"low latency", 1024, true, 100},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			s := &Server{Playback: conf.Playback{WriteBuffer: ca.size}}
+
+			var out countingWrites
+			b := s.newWriteBuffer(&out, ca.lowLatency)
+
+			for i := 0; i < 100; i++ {
+				_, err := b.Write([]byte{byte(i)})
+				require.NoError(t, err)
+			}
+
+			err := b.flush()
+			require.NoError(t, err)
+
+			require.Equal(t, ca.writes, out.writes)
+			require.Equal(t, 100, out.Len())
+		})
+	}
+}
+
+func TestOnGetWriteBuffer(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-playback")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment2(t, filepath.Join(dir, "mypath", "2008-11-07_11-23-02-500000.mp4"))
+
+	var outs [][]byte
+
+	for _, size := range []conf.StringSize{0, 16} {
+		func() {
+			s := &Server{
+				Playback: conf.Playback{
+					Address:     "127.0.0.1:9996",
+					WriteBuffer: size,
+				},
+				ReadTimeout: conf.Duration(10 * time.Second),
+				PathConfs: map[string]*conf.Path{
+					"mypath": {
+						Name:       "mypath",
+						RecordPath: filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+					},
+				},
+				AuthManager: test.NilAuthManager,
+				Parent:      test.NilLogger,
+			}
+			err = s.Initialize()
+			require.NoError(t, err)
+			defer s.Close()
+
+			tr := &http.Transport{}
+			defer tr.CloseIdleConnections()
+			hc := &http.Client{Transport: tr}
+
+			v := url.Values{}
+			v.Set("path", "mypath")
+			v.Set("start", time.Date(2008, 11, 0o7, 11, 23, 2, 500000000, time.Local).Format(time.RFC3339Nano))
+			v.Set("duration", "3")
+			v.Set("format", "mp4")
+
+			res, err := hc.Get("http://localhost:9996/get?" + v.Encode())
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			buf, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			// buffered data is sent before trailers
+			require.Equal(t, strconv.Itoa(len(buf)), res.Trailer.Get("X-Playback-Bytes-Written"))
+			outs = append(outs, buf)
+		}()
+	}
+
+	require.NotEmpty(t, outs[0])
+	require.Equal(t, outs[0], outs[1])
+}
@@ -11,6 +11,12 @@ import (
 func Credentials(h *http.Request) *auth.Credentials {
 	c := &auth.Credentials{}
 
+	// API key in X-API-Key
+	if v := h.Header.Get("X-API-Key"); v != "" {
+		c.APIKey = v
+		return c
+	}
+
 	for _, auth := range h.Header["Authorization"] {
 		if strings.HasPrefix(auth, "Bearer ") {
 			// user:pass in Authorization Bearer
@@ -3,8 +3,10 @@ package httpp
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"strings"
 
 	"github.com/bluenviron/mediamtx/internal/logger"
 )
@@ -32,6 +34,16 @@ func (w *loggerWriter) WriteHeader(statusCode int) {
 	w.w.WriteHeader(statusCode)
 }
 
+// Flush implements http.Flusher.
+func (w *loggerWriter) Flush() {
+	http.NewResponseController(w.w).Flush() //nolint:errcheck
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *loggerWriter) Unwrap() http.ResponseWriter {
+	return w.w
+}
+
 func (w *loggerWriter) dump() string {
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "%s %d %s\n", "HTTP/1.1", w.status, http.StatusText(w.status))
@@ -46,16 +58,81 @@ func (w *loggerWriter) dump() string {
 // log requests and responses.
 type handlerLogger struct {
 	http.Handler
-	log logger.Writer
+	log         logger.Writer
+	anonymizeIP func(string) string
+}
+
+func (h *handlerLogger) remoteAddr(r *http.Request) string {
+	if h.anonymizeIP == nil {
+		return r.RemoteAddr
+	}
+
+	host, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return h.anonymizeIP(r.RemoteAddr)
+	}
+	return net.JoinHostPort(h.anonymizeIP(host), port)
+}
+
+// dumpRequest dumps the request, replacing IPs of clients into headers set by proxies.
+func (h *handlerLogger) dumpRequest(r *http.Request) []byte {
+	if h.anonymizeIP == nil {
+		byts, _ := httputil.DumpRequest(r, true)
+		return byts
+	}
+
+	r2 := *r
+	r2.Header = r.Header.Clone()
+
+	for _, key := range []string{"X-Forwarded-For", "X-Real-Ip", "Forwarded"} {
+		for i, v := range r2.Header[key] {
+			r2.Header[key][i] = h.anonymizeHeader(key, v)
+		}
+	}
+
+	byts, _ := httputil.DumpRequest(&r2, true)
+
+	// DumpRequest replaces the body with a copy that can be read again.
+	r.Body = r2.Body
+
+	return byts
+}
+
+func (h *handlerLogger) anonymizeHeader(key string, v string) string {
+	parts := strings.Split(v, ",")
+
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+
+		if key != "Forwarded" {
+			parts[i] = h.anonymizeIP(part)
+			continue
+		}
+
+		pairs := strings.Split(part, ";")
+		for j, pair := range pairs {
+			if k, val, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && strings.EqualFold(k, "for") {
+				val = strings.Trim(val, `"`)
+				if host, _, err := net.SplitHostPort(val); err == nil {
+					val = host
+				}
+				val = strings.Trim(val, "[]")
+				pairs[j] = k + `="` + h.anonymizeIP(val) + `"`
+			}
+		}
+		parts[i] = strings.Join(pairs, ";")
+	}
+
+	return strings.Join(parts, ", ")
 }
 
 func (h *handlerLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	byts, _ := httputil.DumpRequest(r, true)
-	h.log.Log(logger.Debug, "[conn %v] [c->s] %s", r.RemoteAddr, string(byts))
+	byts := h.dumpRequest(r)
+	h.log.Log(logger.Debug, "[conn %v] [c->s] %s", h.remoteAddr(r), string(byts))
 
 	logw := &loggerWriter{w: w}
 
 	h.Handler.ServeHTTP(logw, r)
 
-	h.log.Log(logger.Debug, "[conn %v] [s->c] %s", r.RemoteAddr, logw.dump())
+	h.log.Log(logger.Debug, "[conn %v] [s->c] %s", h.remoteAddr(r), logw.dump())
 }
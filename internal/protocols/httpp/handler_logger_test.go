@@ -0,0 +1,59 @@
+package httpp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+type captureLogger struct {
+	lines []string
+}
+
+func (l *captureLogger) Log(_ logger.Level, format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestHandlerLoggerAnonymizeIP(t *testing.T) {
+	l := &captureLogger{}
+
+	var body []byte
+
+	h := &handlerLogger{
+		Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			// the handler still receives full IPs and the body
+			require.Equal(t, "192.168.3.4, 10.0.0.1", r.Header.Get("X-Forwarded-For"))
+			body, _ = io.ReadAll(r.Body)
+		}),
+		log: l,
+		anonymizeIP: func(string) string {
+			return "anon"
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("content"))
+	r.RemoteAddr = "192.168.3.4:1234"
+	r.Header.Set("X-Forwarded-For", "192.168.3.4, 10.0.0.1")
+	r.Header.Set("X-Real-Ip", "192.168.3.4")
+	r.Header.Set("Forwarded", `for=192.168.3.4;proto=http, for="[2001:db8::1]:4711"`)
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	require.Equal(t, []byte("content"), body)
+	require.Len(t, l.lines, 2)
+
+	for _, line := range l.lines {
+		require.NotContains(t, line, "192.168.3.4")
+		require.NotContains(t, line, "2001:db8::1")
+	}
+
+	require.Contains(t, l.lines[0], "anon:1234")
+	require.Contains(t, l.lines[0], "X-Forwarded-For: anon, anon")
+	require.Contains(t, l.lines[0], `Forwarded: for="anon";proto=http, for="anon"`)
+}
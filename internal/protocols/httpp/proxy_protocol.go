@@ -0,0 +1,217 @@
+package httpp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const proxyProtocolV1MaxLen = 107
+
+var proxyProtocolV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// readProxyProtocolHeader reads a PROXY protocol header, version 1 or 2,
+// and returns the address of the client, or nil if the connection
+// has been opened by the proxy itself.
+func readProxyProtocolHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(sig, proxyProtocolV2Signature) {
+		return readProxyProtocolV2(r)
+	}
+
+	if bytes.HasPrefix(sig, []byte("PROXY ")) {
+		return readProxyProtocolV1(r)
+	}
+
+	return nil, fmt.Errorf("PROXY protocol header not found")
+}
+
+func readProxyProtocolV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+
+		if len(line) >= proxyProtocolV1MaxLen {
+			return nil, fmt.Errorf("PROXY protocol header is too long")
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("invalid PROXY protocol header")
+	}
+
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+
+	if len(parts) >= 2 && parts[1] == "UNKNOWN" {
+		return nil, nil //nolint:nilnil
+	}
+
+	if len(parts) != 6 || (parts[1] != "TCP4" && parts[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY protocol header")
+	}
+
+	ip := net.ParseIP(parts[2])
+	if ip == nil {
+		return nil, fmt.Errorf("invalid source address: %s", parts[2])
+	}
+
+	port, err := strconv.ParseUint(parts[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source port: %s", parts[4])
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyProtocolV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version: %d", header[12]>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// LOCAL command: the connection has been opened by the proxy itself
+	if header[12]&0x0F == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	switch header[13] >> 4 {
+	case 1: // IPv4
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("invalid PROXY protocol header")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:4]),
+			Port: int(binary.BigEndian.Uint16(payload[8:])),
+		}, nil
+
+	case 2: // IPv6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("invalid PROXY protocol header")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:16]),
+			Port: int(binary.BigEndian.Uint16(payload[32:])),
+		}, nil
+	}
+
+	// unspecified or unix families
+	return nil, nil //nolint:nilnil
+}
+
+// proxyProtocolConn is a connection that starts with a PROXY protocol header.
+// The header is read at the first call to Read() or RemoteAddr(),
+// that are called by the routine that serves the connection.
+type proxyProtocolConn struct {
+	net.Conn
+	readTimeout time.Duration
+
+	once       sync.Once
+	r          *bufio.Reader
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		c.r = bufio.NewReader(c.Conn)
+
+		if c.readTimeout != 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)) //nolint:errcheck
+		}
+		c.remoteAddr, c.err = readProxyProtocolHeader(c.r)
+		c.Conn.SetReadDeadline(time.Time{}) //nolint:errcheck
+
+		if c.err != nil {
+			c.Conn.Close()
+		}
+	})
+}
+
+// Read implements net.Conn.
+func (c *proxyProtocolConn) Read(p []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(p)
+}
+
+// RemoteAddr implements net.Conn.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// proxyProtocolListener is a listener that accepts connections
+// that start with a PROXY protocol header, replacing their remote address
+// with the one of the client.
+// The header is read only from trusted peers, since it allows to impersonate any IP.
+// Connections of other peers are served as they are.
+type proxyProtocolListener struct {
+	net.Listener
+	readTimeout time.Duration
+	trusted     conf.IPNetworks
+}
+
+func (l *proxyProtocolListener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		// Unix sockets can be reached by local peers only
+		return true
+	}
+	return l.trusted.Contains(tcpAddr.IP)
+}
+
+// Accept implements net.Listener.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+
+	return &proxyProtocolConn{
+		Conn:        conn,
+		readTimeout: l.readTimeout,
+	}, nil
+}
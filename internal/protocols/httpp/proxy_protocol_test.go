@@ -0,0 +1,165 @@
+package httpp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+)
+
+func mustParseIPNetworks(t *testing.T, cidrs ...string) conf.IPNetworks {
+	var out conf.IPNetworks
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		out = append(out, *ipnet)
+	}
+	return out
+}
+
+func TestProxyProtocol(t *testing.T) {
+	s := &Server{
+		Network:       "tcp",
+		Address:       "localhost:4555",
+		ReadTimeout:   10 * time.Second,
+		ProxyProtocol: true,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.RemoteAddr)) //nolint:errcheck
+		}),
+		Parent:               test.NilLogger,
+		ProxyProtocolTrusted: mustParseIPNetworks(t, "127.0.0.1/32", "::1/128"),
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	for _, ca := range []struct {
+		name   string
+		header []byte
+		addr   string
+	}{
+		{
+			"v1 tcp4",
+			[]byte("PROXY TCP4 192.168.0.12 10.0.0.1 56324 443\r\n"),
+			"192.168.0.12:56324",
+		},
+		{
+			"v1 tcp6",
+			[]byte("PROXY TCP6 2001:db8::12 2001:db8::1 56324 443\r\n"),
+			"[2001:db8::12]:56324",
+		},
+		{
+			"v1 unknown",
+			[]byte("PROXY UNKNOWN\r\n"),
+			"",
+		},
+		{
+			"v2 tcp4",
+			append([]byte{
+				0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A,
+				0x21, 0x11, 0x00, 0x0C,
+				192, 168, 0, 12,
+				10, 0, 0, 1,
+			}, 0xDC, 0x04, 0x01, 0xBB),
+			"192.168.0.12:56324",
+		},
+		{
+			"v2 local",
+			[]byte{
+				0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A,
+				0x20, 0x00, 0x00, 0x00,
+			},
+			"",
+		},
+		{
+			"missing header",
+			nil,
+			"",
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", "localhost:4555")
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write(append(ca.header, []byte("GET /test HTTP/1.1\r\n"+
+				"Host: localhost:4555\r\n\r\n")...))
+			require.NoError(t, err)
+
+			res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+
+			if ca.name == "missing header" {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			buf := make([]byte, 64)
+			n, _ := res.Body.Read(buf)
+
+			if ca.addr == "" {
+				require.Equal(t, conn.LocalAddr().String(), string(buf[:n]))
+			} else {
+				require.Equal(t, ca.addr, string(buf[:n]))
+			}
+		})
+	}
+}
+
+func TestProxyProtocolUntrustedPeer(t *testing.T) {
+	s := &Server{
+		Network:       "tcp",
+		Address:       "localhost:4555",
+		ReadTimeout:   10 * time.Second,
+		ProxyProtocol: true,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.RemoteAddr)) //nolint:errcheck
+		}),
+		Parent:               test.NilLogger,
+		ProxyProtocolTrusted: mustParseIPNetworks(t, "10.0.0.0/8"),
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	for _, ca := range []string{"header", "no header"} {
+		t.Run(ca, func(t *testing.T) {
+			conn, err := net.Dial("tcp", "127.0.0.1:4555")
+			require.NoError(t, err)
+			defer conn.Close()
+
+			var header []byte
+			if ca == "header" {
+				header = []byte("PROXY TCP4 192.168.0.12 10.0.0.1 56324 443\r\n")
+			}
+
+			_, err = conn.Write(append(header, []byte("GET /test HTTP/1.1\r\n"+
+				"Host: localhost:4555\r\n\r\n")...))
+			require.NoError(t, err)
+
+			res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			// the header is not read and the request is invalid
+			if ca == "header" {
+				require.Equal(t, http.StatusBadRequest, res.StatusCode)
+				return
+			}
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			buf := make([]byte, 64)
+			n, _ := res.Body.Read(buf)
+			require.Equal(t, conn.LocalAddr().String(), string(buf[:n]))
+		})
+	}
+}
@@ -6,10 +6,27 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// ClientIP returns the IP of an HTTP client, with the real IP passed by any proxy in between,
+// in canonical form. IPv4-mapped IPv6 addresses are converted into IPv4 addresses,
+// in order to obtain the same identity regardless of the listener.
+func ClientIP(ctx *gin.Context) string {
+	raw := ctx.ClientIP()
+
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return raw
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	return ip.String()
+}
+
 // RemoteAddr returns the remote address of an HTTP client,
 // with the IP replaced by the real IP passed by any proxy in between.
 func RemoteAddr(ctx *gin.Context) string {
-	ip := ctx.ClientIP()
+	ip := ClientIP(ctx)
 	_, port, _ := net.SplitHostPort(ctx.Request.RemoteAddr)
 	return net.JoinHostPort(ip, port)
 }
@@ -0,0 +1,28 @@
+package httpp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	for _, ca := range []struct {
+		remoteAddr string
+		ip         string
+	}{
+		{"192.168.0.1:1234", "192.168.0.1"},
+		{"[::ffff:192.168.0.1]:1234", "192.168.0.1"},
+		{"[2001:0db8:0000:0000:0000:0000:0000:0001]:1234", "2001:db8::1"},
+	} {
+		t.Run(ca.remoteAddr, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = &http.Request{RemoteAddr: ca.remoteAddr, Header: http.Header{}}
+
+			require.Equal(t, ca.ip, ClientIP(ctx))
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/certloader"
+	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
 )
 
@@ -27,15 +28,27 @@ func (nilWriter) Write(p []byte) (int, error) {
 // - logging
 // - server header
 // - filtering of invalid requests
+// - optional PROXY protocol, in order to obtain the address of clients behind a load balancer
+// - HTTP/2, negotiated with ALPN on TLS connections and optionally available
+// on unencrypted connections (h2c with prior knowledge)
 type Server struct {
-	Network     string
-	Address     string
-	ReadTimeout time.Duration
-	Encryption  bool
-	ServerCert  string
-	ServerKey   string
-	Handler     http.Handler
-	Parent      logger.Writer
+	Network          string
+	Address          string
+	ReadTimeout      time.Duration
+	Encryption       bool
+	ServerCert       string
+	ServerKey        string
+	UnencryptedHTTP2 bool
+	DisableHTTP2     bool
+	ProxyProtocol    bool
+	Handler          http.Handler
+	Parent           logger.Writer
+
+	// optional function that converts IPs of clients before they are written into logs.
+	AnonymizeIP func(string) string
+
+	// peers that are allowed to send PROXY protocol headers.
+	ProxyProtocolTrusted conf.IPNetworks
 
 	ln     net.Listener
 	inner  *http.Server
@@ -71,18 +84,36 @@ func (s *Server) Initialize() error {
 		return err
 	}
 
+	if s.ProxyProtocol {
+		s.ln = &proxyProtocolListener{
+			Listener:    s.ln,
+			readTimeout: s.ReadTimeout,
+			trusted:     s.ProxyProtocolTrusted,
+		}
+	}
+
 	h := s.Handler
 	h = &handlerFilterRequests{h}
 	h = &handlerFilterRequests{h}
 	h = &handlerServerHeader{h}
-	h = &handlerLogger{h, s.Parent}
+	h = &handlerLogger{h, s.Parent, s.AnonymizeIP}
 	h = &handlerExitOnPanic{h}
 
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(!s.DisableHTTP2)
+	protocols.SetUnencryptedHTTP2(!s.DisableHTTP2 && s.UnencryptedHTTP2)
+
+	if tlsConfig != nil && !s.DisableHTTP2 {
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+
 	s.inner = &http.Server{
 		Handler:           h,
 		TLSConfig:         tlsConfig,
 		ReadHeaderTimeout: s.ReadTimeout,
 		ErrorLog:          log.New(&nilWriter{}, "", 0),
+		Protocols:         &protocols,
 	}
 
 	if tlsConfig != nil {
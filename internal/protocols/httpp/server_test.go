@@ -3,6 +3,7 @@ package httpp
 import (
 	"io"
 	"net"
+	"net/http"
 	"testing"
 	"time"
 
@@ -36,3 +37,33 @@ func TestFilterEmptyPath(t *testing.T) {
 	_, err = io.ReadFull(conn, buf)
 	require.NoError(t, err)
 }
+
+func TestUnencryptedHTTP2(t *testing.T) {
+	s := &Server{
+		Network:          "tcp",
+		Address:          "localhost:4555",
+		ReadTimeout:      10 * time.Second,
+		UnencryptedHTTP2: true,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+		Parent: test.NilLogger,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+
+	tr := &http.Transport{Protocols: &protocols}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	res, err := hc.Get("http://localhost:4555/test")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, 2, res.ProtoMajor)
+}
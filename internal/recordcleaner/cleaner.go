@@ -3,23 +3,30 @@ package recordcleaner
 
 import (
 	"context"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/bluenviron/mediamtx/internal/audit"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
+	"github.com/bluenviron/mediamtx/internal/hooks"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
 var timeNow = time.Now
 
-// Cleaner removes expired recording segments from disk.
+// Cleaner removes expired recording segments from disk
+// and moves old segments to the archive path.
 type Cleaner struct {
-	PathConfs map[string]*conf.Path
-	Parent    logger.Writer
+	PathConfs       map[string]*conf.Path
+	AuditLog        *audit.Log
+	ExternalCmdPool *externalcmd.Pool
+	Parent          logger.Writer
 
 	ctx       context.Context
 	ctxCancel func()
@@ -83,6 +90,11 @@ func (c *Cleaner) cleanInterval() time.Duration {
 			interval > (time.Duration(e.RecordDeleteAfter)/2) {
 			interval = time.Duration(e.RecordDeleteAfter) / 2
 		}
+
+		if e.RecordArchiveAfter != 0 &&
+			interval > (time.Duration(e.RecordArchiveAfter)/2) {
+			interval = time.Duration(e.RecordArchiveAfter) / 2
+		}
 	}
 
 	return interval
@@ -104,37 +116,133 @@ func (c *Cleaner) processPath(now time.Time, pathName string) error {
 		return err
 	}
 
-	if pathConf.RecordDeleteAfter == 0 {
+	if pathConf.RecordDeleteAfter == 0 && pathConf.RecordArchiveAfter == 0 {
 		return nil
 	}
 
-	err = c.deleteExpiredSegments(now, pathName, pathConf)
-	if err != nil {
-		return err
+	if pathConf.RecordDeleteAfter != 0 {
+		err = c.deleteExpiredSegments(now, pathName, pathConf)
+		if err != nil {
+			return err
+		}
 	}
 
-	c.deleteEmptyDirs(pathConf)
+	if pathConf.RecordArchiveAfter != 0 {
+		err = c.archiveSegments(now, pathName, pathConf)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.deleteEmptyDirs(pathConf, pathConf.RecordPath)
+	if pathConf.RecordArchivePath != "" {
+		c.deleteEmptyDirs(pathConf, pathConf.RecordArchivePath)
+	}
 
 	return nil
 }
 
 func (c *Cleaner) deleteExpiredSegments(now time.Time, pathName string, pathConf *conf.Path) error {
 	end := now.Add(-time.Duration(pathConf.RecordDeleteAfter))
-	segments, err := recordstore.FindSegments(pathConf, pathName, nil, &end)
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	holds, err := recordstore.FindHolds(pathConf, pathName)
 	if err != nil {
+		c.Log(logger.Warn, "unable to read holds of path '%s': %v", pathName, err)
 		return err
 	}
 
-	for _, seg := range segments {
+	for i, seg := range segments {
+		if seg.Start.After(end) {
+			break
+		}
+
+		// the segment lasts until the beginning of the next one
+		var segEnd time.Time
+		if i < (len(segments) - 1) {
+			segEnd = segments[i+1].Start
+		}
+
+		if recordstore.HoldsOverlap(holds, seg.Start, segEnd) {
+			c.Log(logger.Debug, "skipping %s since it's protected by a hold", seg.Fpath)
+			continue
+		}
+
 		c.Log(logger.Debug, "removing %s", seg.Fpath)
-		os.Remove(seg.Fpath)
+		err = os.Remove(seg.Fpath)
+
+		e := &audit.Entry{
+			Action:     audit.ActionRetentionPurge,
+			Path:       pathName,
+			Parameters: map[string][]string{"segment": {seg.Fpath}},
+			Outcome:    audit.OutcomeSuccess,
+		}
+		if err != nil {
+			e.Outcome = audit.OutcomeFailure
+			e.Error = err.Error()
+		} else {
+			hooks.OnRecordSegmentDelete(hooks.OnRecordSegmentDeleteParams{
+				Logger:          c,
+				ExternalCmdPool: c.ExternalCmdPool,
+				Conf:            pathConf,
+				PathName:        pathName,
+				SegmentPath:     seg.Fpath,
+			})
+		}
+
+		err = c.AuditLog.Write(e)
+		if err != nil {
+			c.Log(logger.Error, "unable to write audit log: %v", err)
+		}
 	}
 
 	return nil
 }
 
-func (c *Cleaner) deleteEmptyDirs(pathConf *conf.Path) {
-	recordPath := strings.ReplaceAll(pathConf.RecordPath, "%path", pathConf.Name)
+// archiveSegments moves segments that are older than recordArchiveAfter
+// from the record path to the archive path.
+func (c *Cleaner) archiveSegments(now time.Time, pathName string, pathConf *conf.Path) error {
+	end := now.Add(-time.Duration(pathConf.RecordArchiveAfter))
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	archiveFormat := recordstore.PathAddExtension(
+		strings.ReplaceAll(pathConf.RecordArchivePath, "%path", pathName),
+		pathConf.RecordFormat,
+	)
+
+	for i, seg := range segments[:len(segments)-1] {
+		// the segment lasts until the beginning of the next one.
+		// The last segment may still be written and is never moved.
+		if segments[i+1].Start.After(end) {
+			break
+		}
+
+		if recordstore.SegmentPathFormat(pathConf, pathName, seg.Fpath) == archiveFormat {
+			continue
+		}
+
+		dest := recordstore.Path{Start: seg.Start, Path: pathName}.Encode(archiveFormat)
+
+		c.Log(logger.Debug, "moving %s to %s", seg.Fpath, dest)
+
+		err = moveFile(seg.Fpath, dest)
+		if err != nil {
+			c.Log(logger.Warn, "unable to move %s: %v", seg.Fpath, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cleaner) deleteEmptyDirs(pathConf *conf.Path, format string) {
+	recordPath := strings.ReplaceAll(format, "%path", pathConf.Name)
 	commonPath := recordstore.CommonPath(recordPath)
 
 	filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error { //nolint:errcheck
@@ -149,3 +257,55 @@ func (c *Cleaner) deleteEmptyDirs(pathConf *conf.Path) {
 		return nil
 	})
 }
+
+// moveFile moves a file, even between different file systems.
+// When a copy is needed, it is performed into a temporary file,
+// in order not to expose partial segments.
+func moveFile(src string, dest string) error {
+	err := os.MkdirAll(filepath.Dir(dest), 0o755)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(src, dest)
+	if err == nil {
+		return nil
+	}
+
+	tmp := dest + ".tmp"
+
+	err = copyFile(src, tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	err = os.Rename(tmp, dest)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
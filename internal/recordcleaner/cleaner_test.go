@@ -8,7 +8,10 @@ import (
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -108,3 +111,166 @@ func TestCleanerMultipleEntriesSamePath(t *testing.T) {
 	_, err = os.Stat(filepath.Join(dir, "path2", "2009-05-19_22-15-25-000427.mp4"))
 	require.NoError(t, err)
 }
+
+func TestCleanerHold(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000125.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-06-20_22-15-25-000125.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2009-05-20_22-15-25-000427.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:              "mypath",
+		RecordPath:        filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:      conf.RecordFormatFMP4,
+		RecordDeleteAfter: conf.Duration(10 * time.Second),
+	}
+
+	err = recordstore.AddHold(pathConf, &recordstore.Hold{
+		ID:    uuid.New(),
+		Path:  "mypath",
+		Start: time.Date(2008, 5, 20, 22, 15, 30, 0, time.Local),
+		End:   time.Date(2008, 5, 20, 22, 16, 0, 0, time.Local),
+	})
+	require.NoError(t, err)
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": pathConf,
+		},
+		Parent: test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000125.mp4"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2008-06-20_22-15-25-000125.mp4"))
+	require.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2009-05-20_22-15-25-000427.mp4"))
+	require.NoError(t, err)
+}
+
+func TestCleanerArchive(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		"2009-05-20_18-00-00-000000.mp4",
+		"2009-05-20_20-00-00-000000.mp4",
+		"2009-05-20_21-00-00-000000.mp4",
+		"2009-05-20_22-00-00-000000.mp4",
+	} {
+		err = os.WriteFile(filepath.Join(dir, "mypath", name), []byte{1}, 0o644)
+		require.NoError(t, err)
+	}
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:               "mypath",
+				RecordPath:         filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordArchivePath:  filepath.Join(dir, "archive/%path/%Y/%m/%d/%H-%M-%S-%f"),
+				RecordFormat:       conf.RecordFormatFMP4,
+				RecordArchiveAfter: conf.Duration(1 * time.Hour),
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	// segments that ended before 21:15 are moved
+	for _, name := range []string{
+		"2009-05-20_18-00-00-000000.mp4",
+		"2009-05-20_20-00-00-000000.mp4",
+	} {
+		_, err = os.Stat(filepath.Join(dir, "mypath", name))
+		require.Error(t, err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "archive", "mypath", "2009", "05", "20", "18-00-00-000000.mp4"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "archive", "mypath", "2009", "05", "20", "20-00-00-000000.mp4"))
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		"2009-05-20_21-00-00-000000.mp4",
+		"2009-05-20_22-00-00-000000.mp4",
+	} {
+		_, err = os.Stat(filepath.Join(dir, "mypath", name))
+		require.NoError(t, err)
+	}
+}
+
+func TestCleanerRunOnRecordSegmentDelete(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000125.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	externalCmdPool := &externalcmd.Pool{}
+	externalCmdPool.Initialize()
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:                     "mypath",
+				RecordPath:               filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat:             conf.RecordFormatFMP4,
+				RecordDeleteAfter:        conf.Duration(10 * time.Second),
+				RunOnRecordSegmentDelete: "sh -c 'echo \"$MTX_PATH $MTX_SEGMENT_PATH\" > " + filepath.Join(dir, "out") + "'",
+			},
+		},
+		ExternalCmdPool: externalCmdPool,
+		Parent:          test.NilLogger,
+	}
+	c.Initialize()
+
+	time.Sleep(500 * time.Millisecond)
+
+	c.Close()
+	externalCmdPool.Close()
+
+	byts, err := os.ReadFile(filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	require.Equal(t, "mypath "+filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000125.mp4")+"\n", string(byts))
+}
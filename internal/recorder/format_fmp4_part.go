@@ -63,7 +63,7 @@ func (p *formatFMP4Part) close() error {
 			return err
 		}
 
-		fi, err := os.Create(p.s.path)
+		fi, err := recordstore.CreateSegmentFile(p.s.path, p.s.f.ri.encryptionKey)
 		if err != nil {
 			return err
 		}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os"
 	"time"
 
 	"github.com/abema/go-mp4"
@@ -12,6 +11,7 @@ import (
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
 
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
 func writeInit(f io.Writer, tracks []*formatFMP4Track) error {
@@ -104,7 +104,7 @@ type formatFMP4Segment struct {
 	startNTP time.Time
 
 	path    string
-	fi      *os.File
+	fi      recordstore.SegmentFile
 	curPart *formatFMP4Part
 	endDTS  time.Duration
 }
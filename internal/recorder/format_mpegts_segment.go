@@ -15,7 +15,7 @@ type formatMPEGTSSegment struct {
 	startNTP time.Time
 
 	path      string
-	fi        *os.File
+	fi        recordstore.SegmentFile
 	lastFlush time.Duration
 	lastDTS   time.Duration
 }
@@ -55,7 +55,7 @@ func (s *formatMPEGTSSegment) Write(p []byte) (int, error) {
 			return 0, err
 		}
 
-		fi, err := os.Create(s.path)
+		fi, err := recordstore.CreateSegmentFile(s.path, s.f.ri.encryptionKey)
 		if err != nil {
 			return 0, err
 		}
@@ -21,6 +21,7 @@ type Recorder struct {
 	Format            conf.RecordFormat
 	PartDuration      time.Duration
 	SegmentDuration   time.Duration
+	EncryptionKey     []byte // optional
 	PathName          string
 	Stream            *stream.Stream
 	OnSegmentCreate   OnSegmentCreateFunc
@@ -57,6 +58,7 @@ func (r *Recorder) Initialize() {
 		format:            r.Format,
 		partDuration:      r.PartDuration,
 		segmentDuration:   r.SegmentDuration,
+		encryptionKey:     r.EncryptionKey,
 		pathName:          r.PathName,
 		stream:            r.Stream,
 		onSegmentCreate:   r.OnSegmentCreate,
@@ -103,6 +105,7 @@ func (r *Recorder) run() {
 			format:            r.Format,
 			partDuration:      r.PartDuration,
 			segmentDuration:   r.SegmentDuration,
+			encryptionKey:     r.EncryptionKey,
 			pathName:          r.PathName,
 			stream:            r.Stream,
 			onSegmentCreate:   r.OnSegmentCreate,
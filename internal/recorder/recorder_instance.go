@@ -23,6 +23,7 @@ type recorderInstance struct {
 	format            conf.RecordFormat
 	partDuration      time.Duration
 	segmentDuration   time.Duration
+	encryptionKey     []byte
 	pathName          string
 	stream            *stream.Stream
 	onSegmentCreate   OnSegmentCreateFunc
@@ -0,0 +1,527 @@
+package recordstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const (
+	encryptedMagic = "MTXENC01"
+
+	// size of the random ID of a file.
+	encryptedFileIDSize = 16
+
+	// magic number and file ID.
+	encryptedFileHeaderSize = len(encryptedMagic) + encryptedFileIDSize
+
+	// maximum size of the plaintext of a record.
+	encryptedMaxRecordSize = 1024 * 1024
+
+	// plaintext size and nonce.
+	encryptedRecordHeaderSize = 4 + 12
+)
+
+// SegmentFile is an open segment file.
+// When recordings are encrypted, data is encrypted and decrypted transparently.
+type SegmentFile interface {
+	io.ReadWriteSeeker
+	io.ReaderAt
+	io.Closer
+}
+
+// EncryptionKey returns the key used to encrypt recordings of a path, or nil.
+func EncryptionKey(pathConf *conf.Path) []byte {
+	if pathConf.RecordEncryptionKey == "" {
+		return nil
+	}
+
+	// the key has already been validated by conf
+	key, _ := hex.DecodeString(pathConf.RecordEncryptionKey)
+	return key
+}
+
+// ErrSegmentNotEncrypted is returned when a segment is not encrypted while a key is provided.
+var ErrSegmentNotEncrypted = errors.New("segment is not encrypted")
+
+// OpenSegmentFile opens a segment file for reading.
+// When a key is provided, segments that are not encrypted are rejected, since they may have been
+// replaced by anyone that can write into the storage, unless allowPlaintext is true,
+// in order to read segments that have been recorded before setting the key.
+func OpenSegmentFile(fpath string, key []byte, allowPlaintext bool) (SegmentFile, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return f, nil
+	}
+
+	header := make([]byte, encryptedFileHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		f.Close()
+		return nil, err
+	}
+
+	if n < len(encryptedMagic) || string(header[:len(encryptedMagic)]) != encryptedMagic {
+		if !allowPlaintext {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", fpath, ErrSegmentNotEncrypted)
+		}
+
+		_, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+
+	if n != encryptedFileHeaderSize {
+		f.Close()
+		return nil, fmt.Errorf("segment %s is truncated", fpath)
+	}
+
+	ef, err := newEncryptedFile(f, key, header[len(encryptedMagic):])
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return ef, nil
+}
+
+// NewSegmentFile wraps a segment file that has just been created.
+func NewSegmentFile(f *os.File, key []byte) (SegmentFile, error) {
+	if key == nil {
+		return f, nil
+	}
+
+	fileID := make([]byte, encryptedFileIDSize)
+	_, err := rand.Read(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	ef, err := newEncryptedFile(f, key, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	trailer, err := ef.seal(0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := append([]byte(encryptedMagic), fileID...)
+	buf = append(buf, trailer...)
+
+	_, err = f.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	ef.complete = true
+
+	return ef, nil
+}
+
+// CreateSegmentFile creates a segment file.
+func CreateSegmentFile(fpath string, key []byte) (SegmentFile, error) {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	sf, err := NewSegmentFile(f, key)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return sf, nil
+}
+
+type encryptedRecord struct {
+	fileOffset  int64
+	plainOffset int64
+	size        int64
+}
+
+func (r *encryptedRecord) plainEnd() int64 {
+	return r.plainOffset + r.size
+}
+
+// encryptedFile is a segment file encrypted with AES-GCM.
+// Each file is encrypted with its own key, that is derived from the key of the path
+// and from the file ID with HKDF, limiting the number of random nonces that are used with a key.
+// After a magic number and a random file ID, the file contains a sequence of records,
+// each with the size of a chunk of plaintext, a random nonce and the ciphertext of the chunk,
+// followed by a trailer, that is an empty record.
+// The ID of the file, the size and the offset of the plaintext are authenticated
+// together with each record, preventing records from being moved inside the file
+// or into other files. The trailer authenticates the size of the plaintext,
+// and is rewritten after every write, allowing to detect files that have been truncated.
+// Records are indexed when needed, allowing random access without decrypting the entire file.
+type encryptedFile struct {
+	f      *os.File
+	aead   cipher.AEAD
+	fileID []byte
+
+	records  []encryptedRecord
+	indexEnd int64
+	complete bool
+	pos      int64
+
+	cacheIdx  int
+	cacheData []byte
+}
+
+func newEncryptedFile(f *os.File, key []byte, fileID []byte) (*encryptedFile, error) {
+	fileKey, err := hkdf.Key(sha256.New, key, fileID, "mediamtx segment", len(key))
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedFile{
+		f:        f,
+		aead:     aead,
+		fileID:   fileID,
+		indexEnd: int64(encryptedFileHeaderSize),
+		cacheIdx: -1,
+	}, nil
+}
+
+// Close implements io.Closer.
+func (e *encryptedFile) Close() error {
+	return e.f.Close()
+}
+
+func (e *encryptedFile) plainSize() int64 {
+	if len(e.records) == 0 {
+		return 0
+	}
+	return e.records[len(e.records)-1].plainEnd()
+}
+
+// indexUntil indexes records until the one that contains plainOffset,
+// or until the trailer.
+func (e *encryptedFile) indexUntil(plainOffset int64) error {
+	if e.plainSize() > plainOffset {
+		return nil
+	}
+
+	fi, err := e.f.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := fi.Size()
+
+	header := make([]byte, 4)
+	e.complete = false
+
+	for e.plainSize() <= plainOffset && e.indexEnd+encryptedRecordHeaderSize <= fileSize {
+		_, err = e.f.ReadAt(header, e.indexEnd)
+		if err != nil {
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header))
+		recordSize := encryptedRecordHeaderSize + size + int64(e.aead.Overhead())
+
+		if size > encryptedMaxRecordSize || e.indexEnd+recordSize > fileSize {
+			break
+		}
+
+		if size == 0 {
+			e.complete = e.checkTrailer()
+			break
+		}
+
+		e.records = append(e.records, encryptedRecord{
+			fileOffset:  e.indexEnd,
+			plainOffset: e.plainSize(),
+			size:        size,
+		})
+		e.indexEnd += recordSize
+	}
+
+	return nil
+}
+
+func (e *encryptedFile) additionalData(size int64, plainOffset int64) []byte {
+	ad := make([]byte, encryptedFileIDSize+4+8)
+	copy(ad, e.fileID)
+	binary.BigEndian.PutUint32(ad[encryptedFileIDSize:], uint32(size))
+	binary.BigEndian.PutUint64(ad[encryptedFileIDSize+4:], uint64(plainOffset))
+	return ad
+}
+
+// checkTrailer checks whether the trailer placed after indexed records is valid.
+func (e *encryptedFile) checkTrailer() bool {
+	buf := make([]byte, encryptedRecordHeaderSize+e.aead.Overhead())
+
+	_, err := e.f.ReadAt(buf, e.indexEnd)
+	if err != nil {
+		return false
+	}
+
+	_, err = e.aead.Open(nil, buf[4:encryptedRecordHeaderSize], buf[encryptedRecordHeaderSize:],
+		e.additionalData(0, e.plainSize()))
+	return err == nil
+}
+
+func (e *encryptedFile) readRecord(idx int) ([]byte, error) {
+	if idx == e.cacheIdx {
+		return e.cacheData, nil
+	}
+
+	rec := &e.records[idx]
+	buf := make([]byte, encryptedRecordHeaderSize+rec.size+int64(e.aead.Overhead()))
+
+	var data []byte
+
+	// a record may be rewritten while it is being read. In this case, read it again.
+	for i := 0; i < 2; i++ {
+		_, err := e.f.ReadAt(buf, rec.fileOffset)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = e.aead.Open(nil, buf[4:encryptedRecordHeaderSize], buf[encryptedRecordHeaderSize:],
+			e.additionalData(rec.size, rec.plainOffset))
+		if err == nil {
+			break
+		}
+
+		if i == 1 {
+			return nil, fmt.Errorf("unable to decrypt segment: %w", err)
+		}
+	}
+
+	e.cacheIdx = idx
+	e.cacheData = data
+
+	return data, nil
+}
+
+// seal encrypts a record.
+func (e *encryptedFile) seal(size int64, plainOffset int64, data []byte) ([]byte, error) {
+	buf := make([]byte, encryptedRecordHeaderSize, encryptedRecordHeaderSize+len(data)+e.aead.Overhead())
+	binary.BigEndian.PutUint32(buf, uint32(size))
+
+	// nonces are random, since records can be rewritten
+	_, err := rand.Read(buf[4:encryptedRecordHeaderSize])
+	if err != nil {
+		return nil, err
+	}
+
+	return e.aead.Seal(buf, buf[4:encryptedRecordHeaderSize], data, e.additionalData(size, plainOffset)), nil
+}
+
+func (e *encryptedFile) findRecord(plainOffset int64) int {
+	return sort.Search(len(e.records), func(i int) bool {
+		return e.records[i].plainEnd() > plainOffset
+	})
+}
+
+// ReadAt implements io.ReaderAt.
+func (e *encryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		cur := off + int64(n)
+
+		err := e.indexUntil(cur)
+		if err != nil {
+			return n, err
+		}
+
+		idx := e.findRecord(cur)
+
+		// the trailer may be missing since it is being rewritten. In this case, index the file again.
+		if idx == len(e.records) && !e.complete {
+			err = e.indexUntil(cur)
+			if err != nil {
+				return n, err
+			}
+			idx = e.findRecord(cur)
+		}
+
+		if idx == len(e.records) {
+			if !e.complete {
+				return n, fmt.Errorf("segment is truncated")
+			}
+			return n, io.EOF
+		}
+
+		data, err := e.readRecord(idx)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], data[cur-e.records[idx].plainOffset:])
+	}
+
+	return n, nil
+}
+
+// Read implements io.Reader.
+func (e *encryptedFile) Read(p []byte) (int, error) {
+	n, err := e.ReadAt(p, e.pos)
+	e.pos += int64(n)
+
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (e *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+
+	case io.SeekCurrent:
+		offset += e.pos
+
+	case io.SeekEnd:
+		err := e.indexUntil(math.MaxInt64)
+		if err != nil {
+			return 0, err
+		}
+		offset += e.plainSize()
+
+	default:
+		return 0, fmt.Errorf("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+
+	e.pos = offset
+	return offset, nil
+}
+
+// Write implements io.Writer.
+// Data can be appended to the end of the file, or can replace data inside a single record.
+func (e *encryptedFile) Write(p []byte) (int, error) {
+	err := e.indexUntil(math.MaxInt64)
+	if err != nil {
+		return 0, err
+	}
+
+	if e.pos < e.plainSize() {
+		return e.overwrite(p)
+	}
+
+	if e.pos > e.plainSize() {
+		return 0, fmt.Errorf("writing after the end of an encrypted segment is not supported")
+	}
+
+	// records and the new trailer are written at once, replacing the previous trailer
+	var buf []byte
+	var records []encryptedRecord
+	fileOffset := e.indexEnd
+	plainOffset := e.plainSize()
+
+	for n := 0; n < len(p); {
+		size := len(p) - n
+		if size > encryptedMaxRecordSize {
+			size = encryptedMaxRecordSize
+		}
+
+		var sealed []byte
+		sealed, err = e.seal(int64(size), plainOffset, p[n:n+size])
+		if err != nil {
+			return 0, err
+		}
+
+		records = append(records, encryptedRecord{
+			fileOffset:  fileOffset,
+			plainOffset: plainOffset,
+			size:        int64(size),
+		})
+		buf = append(buf, sealed...)
+		fileOffset += int64(len(sealed))
+		plainOffset += int64(size)
+		n += size
+	}
+
+	trailer, err := e.seal(0, plainOffset, nil)
+	if err != nil {
+		return 0, err
+	}
+	buf = append(buf, trailer...)
+
+	_, err = e.f.WriteAt(buf, e.indexEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	e.records = append(e.records, records...)
+	e.indexEnd = fileOffset
+	e.complete = true
+	e.pos += int64(len(p))
+
+	return len(p), nil
+}
+
+func (e *encryptedFile) overwrite(p []byte) (int, error) {
+	idx := e.findRecord(e.pos)
+	rec := &e.records[idx]
+
+	if e.pos+int64(len(p)) > rec.plainEnd() {
+		return 0, fmt.Errorf("writing across multiple records of an encrypted segment is not supported")
+	}
+
+	data, err := e.readRecord(idx)
+	if err != nil {
+		return 0, err
+	}
+
+	data = append([]byte(nil), data...)
+	copy(data[e.pos-rec.plainOffset:], p)
+
+	sealed, err := e.seal(rec.size, rec.plainOffset, data)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = e.f.WriteAt(sealed, rec.fileOffset)
+	if err != nil {
+		return 0, err
+	}
+
+	e.cacheIdx = idx
+	e.cacheData = data
+	e.pos += int64(len(p))
+
+	return len(p), nil
+}
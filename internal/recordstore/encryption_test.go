@@ -0,0 +1,183 @@
+package recordstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{1}, 32)
+
+func TestEncryptedSegmentFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "segment.mp4")
+
+	f, err := CreateSegmentFile(fpath, testEncryptionKey)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("0123456789"))
+	require.NoError(t, err)
+
+	big := bytes.Repeat([]byte{2}, encryptedMaxRecordSize+10)
+	_, err = f.Write(big)
+	require.NoError(t, err)
+
+	// rewrite data inside the first record
+	_, err = f.Seek(2, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("ab"))
+	require.NoError(t, err)
+
+	// writes across records are not supported
+	_, err = f.Seek(8, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("abcd"))
+	require.Error(t, err)
+
+	err = f.Close()
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(raw, []byte("01ab456789")))
+
+	expected := append([]byte("01ab456789"), big...)
+
+	t.Run("read", func(t *testing.T) {
+		r, err := OpenSegmentFile(fpath, testEncryptionKey, false)
+		require.NoError(t, err)
+		defer r.Close()
+
+		size, err := r.Seek(0, io.SeekEnd)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(expected)), size)
+
+		_, err = r.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		buf, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, expected, buf)
+
+		buf = make([]byte, 6)
+		_, err = r.ReadAt(buf, 6)
+		require.NoError(t, err)
+		require.Equal(t, expected[6:12], buf)
+
+		_, err = r.ReadAt(buf, int64(len(expected))-2)
+		require.Equal(t, io.EOF, err)
+	})
+
+	trailerSize := encryptedRecordHeaderSize + 16
+
+	t.Run("truncated", func(t *testing.T) {
+		err := os.WriteFile(fpath+".2", raw[:len(raw)-trailerSize], 0o644)
+		require.NoError(t, err)
+
+		r, err := OpenSegmentFile(fpath+".2", testEncryptionKey, false)
+		require.NoError(t, err)
+		defer r.Close()
+
+		buf, err := io.ReadAll(r)
+		require.EqualError(t, err, "segment is truncated")
+		require.Equal(t, expected, buf)
+	})
+
+	t.Run("incomplete record", func(t *testing.T) {
+		err := os.WriteFile(fpath+".2", raw[:len(raw)-trailerSize-5], 0o644)
+		require.NoError(t, err)
+
+		r, err := OpenSegmentFile(fpath+".2", testEncryptionKey, false)
+		require.NoError(t, err)
+		defer r.Close()
+
+		size, err := r.Seek(0, io.SeekEnd)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(expected)-10), size)
+
+		_, err = r.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		// the last record of the large write is ignored
+		buf, err := io.ReadAll(r)
+		require.EqualError(t, err, "segment is truncated")
+		require.Equal(t, expected[:len(expected)-10], buf)
+	})
+
+	t.Run("records of another file", func(t *testing.T) {
+		f2, err := CreateSegmentFile(fpath+".4", testEncryptionKey)
+		require.NoError(t, err)
+		f2.Close()
+
+		raw2, err := os.ReadFile(fpath + ".4")
+		require.NoError(t, err)
+
+		err = os.WriteFile(fpath+".4", append(raw2[:encryptedFileHeaderSize:encryptedFileHeaderSize],
+			raw[encryptedFileHeaderSize:]...), 0o644)
+		require.NoError(t, err)
+
+		r, err := OpenSegmentFile(fpath+".4", testEncryptionKey, false)
+		require.NoError(t, err)
+		defer r.Close()
+
+		_, err = io.ReadAll(r)
+		require.Error(t, err)
+	})
+
+	t.Run("per-file key", func(t *testing.T) {
+		block, err := aes.NewCipher(testEncryptionKey)
+		require.NoError(t, err)
+
+		aead, err := cipher.NewGCM(block)
+		require.NoError(t, err)
+
+		e := &encryptedFile{fileID: raw[len(encryptedMagic):encryptedFileHeaderSize]}
+		rec := raw[encryptedFileHeaderSize:]
+		size := int64(binary.BigEndian.Uint32(rec))
+
+		// records must not be sealed with the configured key itself
+		_, err = aead.Open(nil, rec[4:encryptedRecordHeaderSize],
+			rec[encryptedRecordHeaderSize:encryptedRecordHeaderSize+size+int64(aead.Overhead())],
+			e.additionalData(size, 0))
+		require.Error(t, err)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		r, err := OpenSegmentFile(fpath, bytes.Repeat([]byte{2}, 32), false)
+		require.NoError(t, err)
+		defer r.Close()
+
+		_, err = io.ReadAll(r)
+		require.Error(t, err)
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		err := os.WriteFile(fpath+".3", []byte("0123456789"), 0o644)
+		require.NoError(t, err)
+
+		// segments that are not encrypted may have been replaced
+		_, err = OpenSegmentFile(fpath+".3", testEncryptionKey, false)
+		require.ErrorIs(t, err, ErrSegmentNotEncrypted)
+
+		// segments recorded before setting the key can be read as they are
+		r, err := OpenSegmentFile(fpath+".3", testEncryptionKey, true)
+		require.NoError(t, err)
+		defer r.Close()
+
+		buf, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, []byte("0123456789"), buf)
+	})
+}
@@ -0,0 +1,109 @@
+package recordstore
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned when a segment can't be opened because of an injected fault.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Faults are faults that are injected when segments are opened and read,
+// in order to check how clients handle failed or corrupted downloads.
+type Faults struct {
+	// delay of every segment open.
+	OpenDelay time.Duration
+
+	// percentage of segment opens that fail.
+	OpenFailures float64
+
+	// percentage of reads in which a byte is corrupted.
+	CorruptReads float64
+}
+
+var (
+	faultsMutex sync.RWMutex
+	faults      *Faults
+)
+
+// InjectFaults injects faults into every segment that is opened.
+// It returns a function that stops the injection.
+// Faults can be injected by a single caller at a time.
+func InjectFaults(f *Faults) (func(), error) {
+	faultsMutex.Lock()
+	defer faultsMutex.Unlock()
+
+	if faults != nil {
+		return nil, fmt.Errorf("faults are already being injected")
+	}
+	faults = f
+
+	return func() {
+		faultsMutex.Lock()
+		defer faultsMutex.Unlock()
+
+		if faults == f {
+			faults = nil
+		}
+	}, nil
+}
+
+func currentFaults() *Faults {
+	faultsMutex.RLock()
+	defer faultsMutex.RUnlock()
+	return faults
+}
+
+func faultHappens(percentage float64) bool {
+	return percentage > 0 && rand.Float64()*100 < percentage
+}
+
+func (f *Faults) open(s *Segment) (SegmentFile, error) {
+	if f.OpenDelay > 0 {
+		time.Sleep(f.OpenDelay)
+	}
+
+	if faultHappens(f.OpenFailures) {
+		return nil, fmt.Errorf("unable to open %s: %w", s.Fpath, ErrInjectedFault)
+	}
+
+	sf, err := OpenSegmentFile(s.Fpath, s.EncryptionKey, s.AllowPlaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.CorruptReads > 0 {
+		return &faultyFile{SegmentFile: sf, corruptReads: f.CorruptReads}, nil
+	}
+
+	return sf, nil
+}
+
+// faultyFile is a segment file that corrupts a byte of some reads.
+type faultyFile struct {
+	SegmentFile
+	corruptReads float64
+}
+
+func (f *faultyFile) corrupt(p []byte) {
+	if len(p) != 0 && faultHappens(f.corruptReads) {
+		p[rand.Intn(len(p))] ^= 0xFF
+	}
+}
+
+// Read implements io.Reader.
+func (f *faultyFile) Read(p []byte) (int, error) {
+	n, err := f.SegmentFile.Read(p)
+	f.corrupt(p[:n])
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *faultyFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.SegmentFile.ReadAt(p, off)
+	f.corrupt(p[:n])
+	return n, err
+}
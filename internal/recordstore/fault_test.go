@@ -0,0 +1,92 @@
+package recordstore
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaults(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	content := bytes.Repeat([]byte{1, 2, 3, 4}, 256)
+
+	err = os.WriteFile(filepath.Join(dir, "seg.mp4"), content, 0o644)
+	require.NoError(t, err)
+
+	seg := &Segment{Fpath: filepath.Join(dir, "seg.mp4")}
+
+	t.Run("open failures", func(t *testing.T) {
+		remove, err := InjectFaults(&Faults{OpenFailures: 100})
+		require.NoError(t, err)
+		defer remove()
+
+		_, err = seg.Open()
+		require.ErrorIs(t, err, ErrInjectedFault)
+	})
+
+	t.Run("open delay", func(t *testing.T) {
+		remove, err := InjectFaults(&Faults{OpenDelay: 50 * time.Millisecond})
+		require.NoError(t, err)
+		defer remove()
+
+		t1 := time.Now()
+		f, err := seg.Open()
+		require.NoError(t, err)
+		defer f.Close()
+
+		require.GreaterOrEqual(t, time.Since(t1), 50*time.Millisecond)
+	})
+
+	t.Run("corrupt reads", func(t *testing.T) {
+		remove, err := InjectFaults(&Faults{CorruptReads: 100})
+		require.NoError(t, err)
+		defer remove()
+
+		f, err := seg.Open()
+		require.NoError(t, err)
+		defer f.Close()
+
+		buf, err := io.ReadAll(f)
+		require.NoError(t, err)
+		require.Len(t, buf, len(content))
+		require.NotEqual(t, content, buf)
+
+		buf = make([]byte, 16)
+		_, err = f.ReadAt(buf, 32)
+		require.NoError(t, err)
+
+		diff := 0
+		for i := range buf {
+			if buf[i] != content[32+i] {
+				diff++
+			}
+		}
+		require.Equal(t, 1, diff)
+	})
+
+	t.Run("twice", func(t *testing.T) {
+		remove, err := InjectFaults(&Faults{OpenFailures: 100})
+		require.NoError(t, err)
+		defer remove()
+
+		_, err = InjectFaults(&Faults{OpenFailures: 100})
+		require.EqualError(t, err, "faults are already being injected")
+	})
+
+	// faults are not injected after removal
+	f, err := seg.Open()
+	require.NoError(t, err)
+	defer f.Close()
+
+	buf, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, content, buf)
+}
@@ -0,0 +1,148 @@
+package recordstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/google/uuid"
+)
+
+// ErrHoldNotFound is returned when a hold is not found.
+var ErrHoldNotFound = errors.New("hold not found")
+
+// holds are stored in a file placed in the recording directory,
+// next to segments.
+const holdsFileName = ".holds.json"
+
+var holdsMutex sync.Mutex
+
+// Hold is a time range of a path that is protected from deletion.
+type Hold struct {
+	ID      uuid.UUID `json:"id"`
+	Path    string    `json:"path"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+}
+
+func holdsFilePath(pathConf *conf.Path, pathName string) string {
+	recordPath := strings.ReplaceAll(pathConf.RecordPath, "%path", pathName)
+	recordPath, _ = filepath.Abs(recordPath)
+	return filepath.Join(CommonPath(recordPath), holdsFileName)
+}
+
+// the holds file may be shared by multiple paths.
+func readHoldsFile(fpath string) ([]*Hold, error) {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var holds []*Hold
+	err = json.Unmarshal(byts, &holds)
+	if err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+func writeFileAtomic(fpath string, byts []byte) error {
+	err := os.MkdirAll(filepath.Dir(fpath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(fpath+".tmp", byts, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(fpath+".tmp", fpath)
+}
+
+func writeHoldsFile(fpath string, holds []*Hold) error {
+	byts, err := json.MarshalIndent(holds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fpath, byts)
+}
+
+// FindHolds returns all holds of a path.
+func FindHolds(pathConf *conf.Path, pathName string) ([]*Hold, error) {
+	holdsMutex.Lock()
+	defer holdsMutex.Unlock()
+
+	all, err := readHoldsFile(holdsFilePath(pathConf, pathName))
+	if err != nil {
+		return nil, err
+	}
+
+	holds := []*Hold{}
+	for _, h := range all {
+		if h.Path == pathName {
+			holds = append(holds, h)
+		}
+	}
+
+	return holds, nil
+}
+
+// AddHold adds a hold.
+func AddHold(pathConf *conf.Path, hold *Hold) error {
+	holdsMutex.Lock()
+	defer holdsMutex.Unlock()
+
+	fpath := holdsFilePath(pathConf, hold.Path)
+
+	holds, err := readHoldsFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	return writeHoldsFile(fpath, append(holds, hold))
+}
+
+// RemoveHold removes a hold.
+func RemoveHold(pathConf *conf.Path, pathName string, id uuid.UUID) error {
+	holdsMutex.Lock()
+	defer holdsMutex.Unlock()
+
+	fpath := holdsFilePath(pathConf, pathName)
+
+	holds, err := readHoldsFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	for i, h := range holds {
+		if h.Path == pathName && h.ID == id {
+			return writeHoldsFile(fpath, append(holds[:i], holds[i+1:]...))
+		}
+	}
+
+	return ErrHoldNotFound
+}
+
+// HoldsOverlap checks whether a time range overlaps with any hold.
+// A zero end means that the range has no end.
+func HoldsOverlap(holds []*Hold, start time.Time, end time.Time) bool {
+	for _, h := range holds {
+		if (end.IsZero() || h.Start.Before(end)) && h.End.After(start) {
+			return true
+		}
+	}
+	return false
+}
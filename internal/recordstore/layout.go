@@ -0,0 +1,98 @@
+package recordstore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// Layout is an extension point that allows to discover segments
+// that have been written by external tools into custom directory layouts.
+// Layouts are registered at build time with RegisterLayout, usually by the init() function
+// of a package that is imported by the main package, and are selected with
+// the recordLayout parameter of paths.
+type Layout interface {
+	// FindPaths returns names of paths that have at least one segment.
+	// With paths that are not regular expressions, it can return pathConf.Name only.
+	FindPaths(pathConf *conf.Path) ([]string, error)
+
+	// FindSegments returns segments of a path, sorted by start date,
+	// with Fpath, Start and Format filled.
+	// Segments can be filtered by start date and end date: segments that start before
+	// start must be returned when they may contain it.
+	// It returns ErrNoSegmentsFound when there are no segments.
+	FindSegments(pathConf *conf.Path, pathName string, start *time.Time, end *time.Time) ([]*Segment, error)
+}
+
+var (
+	layoutsMutex sync.Mutex
+	layouts      = make(map[string]Layout)
+)
+
+// RegisterLayout registers a layout with a name.
+// It returns a function that removes the layout.
+func RegisterLayout(name string, l Layout) func() {
+	layoutsMutex.Lock()
+	defer layoutsMutex.Unlock()
+
+	if _, ok := layouts[name]; ok {
+		panic(fmt.Sprintf("layout '%s' registered twice", name))
+	}
+	layouts[name] = l
+
+	return func() {
+		layoutsMutex.Lock()
+		defer layoutsMutex.Unlock()
+
+		delete(layouts, name)
+	}
+}
+
+// findLayout returns the layout of a path, or nil when segments are written by the recorder.
+func findLayout(pathConf *conf.Path) (Layout, error) {
+	if pathConf.RecordLayout == "" {
+		return nil, nil
+	}
+
+	layoutsMutex.Lock()
+	defer layoutsMutex.Unlock()
+
+	l, ok := layouts[pathConf.RecordLayout]
+	if !ok {
+		return nil, fmt.Errorf("record layout '%s' is not registered", pathConf.RecordLayout)
+	}
+	return l, nil
+}
+
+func findLayoutSegments(
+	l Layout,
+	pathConf *conf.Path,
+	pathName string,
+	start *time.Time,
+	end *time.Time,
+	formats []conf.RecordFormat,
+) ([]*Segment, error) {
+	segments, err := l.FindSegments(pathConf, pathName, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	n := 0
+	for _, seg := range segments {
+		for _, f := range formats {
+			if seg.Format == f {
+				segments[n] = seg
+				n++
+				break
+			}
+		}
+	}
+
+	if n == 0 {
+		return nil, ErrNoSegmentsFound
+	}
+
+	return segments[:n], nil
+}
@@ -0,0 +1,73 @@
+package recordstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+type testLayout struct {
+	segments []*Segment
+}
+
+func (l *testLayout) FindPaths(pathConf *conf.Path) ([]string, error) {
+	return []string{pathConf.Name}, nil
+}
+
+func (l *testLayout) FindSegments(_ *conf.Path, _ string, _ *time.Time, _ *time.Time) ([]*Segment, error) {
+	if len(l.segments) == 0 {
+		return nil, ErrNoSegmentsFound
+	}
+	return l.segments, nil
+}
+
+func TestLayout(t *testing.T) {
+	l := &testLayout{
+		segments: []*Segment{
+			{
+				Fpath:  "/nvr/cam1/0001.mp4",
+				Start:  time.Date(2008, 11, 0o7, 11, 22, 0, 0, time.Local),
+				Format: conf.RecordFormatFMP4,
+			},
+			{
+				Fpath:  "/nvr/cam1/0002.ts",
+				Start:  time.Date(2008, 11, 0o7, 11, 23, 0, 0, time.Local),
+				Format: conf.RecordFormatMPEGTS,
+			},
+		},
+	}
+	remove := RegisterLayout("test", l)
+	defer remove()
+
+	pathConf := &conf.Path{
+		Name:         "cam1",
+		RecordFormat: conf.RecordFormatFMP4,
+		RecordLayout: "test",
+	}
+
+	segments, err := FindSegments(pathConf, "cam1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, l.segments[:1], segments)
+
+	segments, err = FindSegmentsAllFormats(pathConf, "cam1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, l.segments, segments)
+
+	require.Equal(t, []string{"cam1"}, FindAllPathsWithSegments(map[string]*conf.Path{"cam1": pathConf}))
+
+	segments, err = FindSegments(&conf.Path{
+		Name:         "cam1",
+		RecordFormat: conf.RecordFormatMPEGTS,
+		RecordLayout: "test",
+	}, "cam1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, l.segments[1:], segments)
+
+	_, err = FindSegments(&conf.Path{
+		Name:         "cam1",
+		RecordLayout: "other",
+	}, "cam1", nil, nil)
+	require.EqualError(t, err, "record layout 'other' is not registered")
+}
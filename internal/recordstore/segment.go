@@ -18,13 +18,87 @@ var errFound = errors.New("found")
 
 // Segment is a recording segment.
 type Segment struct {
-	Fpath string
-	Start time.Time
+	Fpath         string
+	Start         time.Time
+	Format        conf.RecordFormat
+	EncryptionKey []byte // optional
+	// read segments that are not encrypted even if EncryptionKey is set.
+	AllowPlaintext bool
+}
+
+// Open opens the segment for reading.
+func (s *Segment) Open() (SegmentFile, error) {
+	if f := currentFaults(); f != nil {
+		return f.open(s)
+	}
+	return OpenSegmentFile(s.Fpath, s.EncryptionKey, s.AllowPlaintext)
+}
+
+// isTemporaryFile checks whether a file is a temporary file.
+// Temporary files are still being written and are renamed once complete,
+// therefore they must not be served.
+func isTemporaryFile(fpath string) bool {
+	return strings.HasSuffix(fpath, ".tmp")
+}
+
+// recordPathFormats returns the record path of a path and, if present, its archive path.
+func recordPathFormats(pathConf *conf.Path) []string {
+	if pathConf.RecordArchivePath != "" {
+		return []string{pathConf.RecordPath, pathConf.RecordArchivePath}
+	}
+	return []string{pathConf.RecordPath}
+}
+
+// recordPathNames returns the name of a path and the previous names
+// whose recordings still belong to the path.
+func recordPathNames(pathConf *conf.Path, pathName string) []string {
+	if pathConf.Regexp != nil || len(pathConf.RecordAliases) == 0 {
+		return []string{pathName}
+	}
+	return append([]string{pathName}, pathConf.RecordAliases...)
+}
+
+// SegmentPathFormat returns the format of the storage that contains a segment,
+// with the extension and the path name, or the previous name the segment has been recorded with.
+func SegmentPathFormat(pathConf *conf.Path, pathName string, fpath string) string {
+	formats := recordPathFormats(pathConf)
+	names := recordPathNames(pathConf, pathName)
+	fabs, _ := filepath.Abs(fpath)
+
+	for i, name := range names {
+		for j, format := range formats {
+			// the record path of the current name is the fallback
+			if i == 0 && j == 0 {
+				continue
+			}
+
+			recordPath := PathAddExtension(strings.ReplaceAll(format, "%path", name), pathConf.RecordFormat)
+			abs, _ := filepath.Abs(recordPath)
+
+			var pa Path
+			if pa.Decode(abs, fabs) {
+				return recordPath
+			}
+		}
+	}
+
+	return PathAddExtension(strings.ReplaceAll(formats[0], "%path", pathName), pathConf.RecordFormat)
 }
 
 func fixedPathHasSegments(pathConf *conf.Path) bool {
+	for _, name := range recordPathNames(pathConf, pathConf.Name) {
+		for _, format := range recordPathFormats(pathConf) {
+			if fixedPathHasSegmentsInFormat(pathConf, name, format) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fixedPathHasSegmentsInFormat(pathConf *conf.Path, pathName string, format string) bool {
 	recordPath := PathAddExtension(
-		strings.ReplaceAll(pathConf.RecordPath, "%path", pathConf.Name),
+		strings.ReplaceAll(format, "%path", pathName),
 		pathConf.RecordFormat,
 	)
 
@@ -39,7 +113,7 @@ func fixedPathHasSegments(pathConf *conf.Path) bool {
 			return err
 		}
 
-		if !info.IsDir() {
+		if !info.IsDir() && !isTemporaryFile(fpath) {
 			var pa Path
 			ok := pa.Decode(recordPath, fpath)
 			if ok {
@@ -57,8 +131,18 @@ func fixedPathHasSegments(pathConf *conf.Path) bool {
 }
 
 func regexpPathFindPathsWithSegments(pathConf *conf.Path) map[string]struct{} {
+	ret := make(map[string]struct{})
+
+	for _, format := range recordPathFormats(pathConf) {
+		regexpPathFindPathsWithSegmentsInFormat(pathConf, format, ret)
+	}
+
+	return ret
+}
+
+func regexpPathFindPathsWithSegmentsInFormat(pathConf *conf.Path, format string, ret map[string]struct{}) {
 	recordPath := PathAddExtension(
-		pathConf.RecordPath,
+		format,
 		pathConf.RecordFormat,
 	)
 
@@ -68,14 +152,12 @@ func regexpPathFindPathsWithSegments(pathConf *conf.Path) map[string]struct{} {
 
 	commonPath := CommonPath(recordPath)
 
-	ret := make(map[string]struct{})
-
 	filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error { //nolint:errcheck
 		if err != nil {
 			return err
 		}
 
-		if !info.IsDir() {
+		if !info.IsDir() && !isTemporaryFile(fpath) {
 			var pa Path
 			if ok := pa.Decode(recordPath, fpath); ok {
 				if err := conf.IsValidPathName(pa.Path); err == nil {
@@ -88,8 +170,6 @@ func regexpPathFindPathsWithSegments(pathConf *conf.Path) map[string]struct{} {
 
 		return nil
 	})
-
-	return ret
 }
 
 // FindAllPathsWithSegments returns all paths that have at least one segment.
@@ -97,6 +177,19 @@ func FindAllPathsWithSegments(pathConfs map[string]*conf.Path) []string {
 	pathNames := make(map[string]struct{})
 
 	for _, pathConf := range pathConfs {
+		l, err := findLayout(pathConf)
+		if err != nil {
+			continue
+		}
+
+		if l != nil {
+			names, _ := l.FindPaths(pathConf)
+			for _, name := range names {
+				pathNames[name] = struct{}{}
+			}
+			continue
+		}
+
 		if pathConf.Regexp == nil {
 			if fixedPathHasSegments(pathConf) {
 				pathNames[pathConf.Name] = struct{}{}
@@ -119,7 +212,7 @@ func FindAllPathsWithSegments(pathConfs map[string]*conf.Path) []string {
 	return out
 }
 
-// FindSegments returns all segments of a path.
+// FindSegments returns all segments of a path, in the record path and in the archive path.
 // Segments can be filtered by start date and end date.
 func FindSegments(
 	pathConf *conf.Path,
@@ -127,50 +220,101 @@ func FindSegments(
 	start *time.Time,
 	end *time.Time,
 ) ([]*Segment, error) {
-	recordPath := PathAddExtension(
-		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
-		pathConf.RecordFormat,
-	)
+	return findSegments(pathConf, pathName, start, end, []conf.RecordFormat{pathConf.RecordFormat})
+}
 
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
+// FindSegmentsAllFormats is like FindSegments, but returns segments in any record format,
+// including the ones written before recordFormat was changed.
+// The format of each segment is stored into Segment.Format.
+func FindSegmentsAllFormats(
+	pathConf *conf.Path,
+	pathName string,
+	start *time.Time,
+	end *time.Time,
+) ([]*Segment, error) {
+	formats := []conf.RecordFormat{pathConf.RecordFormat}
+	for _, f := range []conf.RecordFormat{conf.RecordFormatFMP4, conf.RecordFormatMPEGTS} {
+		if f != pathConf.RecordFormat {
+			formats = append(formats, f)
+		}
+	}
 
-	commonPath := CommonPath(recordPath)
+	return findSegments(pathConf, pathName, start, end, formats)
+}
+
+func findSegments(
+	pathConf *conf.Path,
+	pathName string,
+	start *time.Time,
+	end *time.Time,
+	formats []conf.RecordFormat,
+) ([]*Segment, error) {
+	l, err := findLayout(pathConf)
+	if err != nil {
+		return nil, err
+	}
+
+	// segments have been written by an external tool
+	if l != nil {
+		return findLayoutSegments(l, pathConf, pathName, start, end, formats)
+	}
+
+	key := EncryptionKey(pathConf)
 	var segments []*Segment
+	var firstErr error
+	found := false
 
-	err := filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	for _, name := range recordPathNames(pathConf, pathName) {
+		for _, format := range recordPathFormats(pathConf) {
+			recordPath := strings.ReplaceAll(format, "%path", name)
 
-		if !info.IsDir() {
-			var pa Path
-			ok := pa.Decode(recordPath, fpath)
+			// we have to convert to absolute paths
+			// otherwise, recordPath and fpath inside Walk() won't have common elements
+			recordPath, _ = filepath.Abs(recordPath)
+
+			commonPath := CommonPath(recordPath)
 
-			// gather all segments that starts before the end of the playback
-			if ok && (end == nil || !end.Before(pa.Start)) {
-				segments = append(segments, &Segment{
-					Fpath: fpath,
-					Start: pa.Start,
-				})
+			segs, err := scanSegments(recordPath, formats, commonPath, start, end, key, pathConf.RecordAllowPlaintext)
+			if err != nil {
+				// a storage may not have been created yet
+				if !errors.Is(err, fs.ErrNotExist) {
+					return nil, err
+				}
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
 			}
+
+			found = true
+			segments = append(segments, segs...)
 		}
+	}
 
-		return nil
-	})
-	if err != nil {
-		return nil, err
+	if !found {
+		return nil, firstErr
 	}
 
 	if segments == nil {
 		return nil, ErrNoSegmentsFound
 	}
 
-	sort.Slice(segments, func(i, j int) bool {
+	// when a segment is being moved, it may be present in both storages.
+	// keep the one in the record path, that has been found first.
+	// segments recorded under the current name are preferred to the ones of aliases.
+	sort.SliceStable(segments, func(i, j int) bool {
 		return segments[i].Start.Before(segments[j].Start)
 	})
 
+	n := 1
+	for _, seg := range segments[1:] {
+		if !seg.Start.Equal(segments[n-1].Start) {
+			segments[n] = seg
+			n++
+		}
+	}
+	segments = segments[:n]
+
 	if start != nil {
 		if start.Before(segments[0].Start) {
 			return segments, nil
@@ -0,0 +1,235 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// maximum number of directories that are read in parallel.
+const segmentScannerWorkers = 8
+
+// units of time that can be used to name directories, from the largest one.
+var segmentScannerUnits = []string{"%Y", "%m", "%d", "%H", "%M", "%S"}
+
+// dirFormatUnits returns the number of units that are encoded in the name of a directory,
+// or zero if the time span of the directory can't be computed.
+func dirFormatUnits(format string) int {
+	if strings.Contains(format, "%s") {
+		return 0
+	}
+
+	n := 0
+	for n < len(segmentScannerUnits) && strings.Contains(format, segmentScannerUnits[n]) {
+		n++
+	}
+
+	// units must be contiguous, starting from the year
+	for _, u := range segmentScannerUnits[n:] {
+		if strings.Contains(format, u) {
+			return 0
+		}
+	}
+
+	return n
+}
+
+// dirSpanEnd returns the end of the time span of a directory that starts at t.
+func dirSpanEnd(t time.Time, units int) time.Time {
+	inc := make([]int, len(segmentScannerUnits))
+	inc[units-1] = 1
+
+	return time.Date(t.Year()+inc[0], t.Month()+time.Month(inc[1]), t.Day()+inc[2],
+		t.Hour()+inc[3], t.Minute()+inc[4], t.Second()+inc[5], 0, t.Location())
+}
+
+// segmentScanner finds segments by reading directories in parallel.
+// Directories whose name is outside the requested window are skipped.
+type segmentScanner struct {
+	recordPath string // without extension
+	formats    []conf.RecordFormat
+	commonPath string
+	start      *time.Time
+	end        *time.Time
+	key        []byte
+	plaintext  bool
+
+	segFormats []string
+	dirFormats []string
+	dirUnits   []int
+	sem        chan struct{}
+	mutex      sync.Mutex
+	segments   []*Segment
+	err        error
+}
+
+func (s *segmentScanner) initialize() {
+	// the format of each directory level is the part of recordPath that precedes a separator
+	for i := len(s.commonPath) + 1; i < len(s.recordPath); i++ {
+		if s.recordPath[i] == '/' || s.recordPath[i] == '\\' {
+			f := s.recordPath[:i]
+			s.dirFormats = append(s.dirFormats, f)
+			s.dirUnits = append(s.dirUnits, dirFormatUnits(f[len(s.commonPath):]))
+		}
+	}
+
+	for _, format := range s.formats {
+		s.segFormats = append(s.segFormats, PathAddExtension(s.recordPath, format))
+	}
+
+	s.sem = make(chan struct{}, segmentScannerWorkers)
+}
+
+func (s *segmentScanner) run() ([]*Segment, error) {
+	s.scanDir(s.commonPath, 0)
+	return s.segments, s.err
+}
+
+// dirSpan returns the time span of a directory, if it can be computed from its name.
+func (s *segmentScanner) dirSpan(fpath string, depth int) (time.Time, time.Time, bool) {
+	if depth >= len(s.dirFormats) || s.dirUnits[depth] == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	var pa Path
+	if !pa.Decode(s.dirFormats[depth], fpath) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return pa.Start, dirSpanEnd(pa.Start, s.dirUnits[depth]), true
+}
+
+type segmentScannerDir struct {
+	fpath string
+	start time.Time
+}
+
+// scanDir scans a directory and returns the number of segments found inside it.
+func (s *segmentScanner) scanDir(dir string, depth int) int {
+	s.sem <- struct{}{}
+	entries, err := os.ReadDir(dir)
+	<-s.sem
+
+	if err != nil {
+		s.mutex.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mutex.Unlock()
+		return 0
+	}
+
+	var count int64
+	var wg sync.WaitGroup
+	var before []segmentScannerDir
+
+	for _, entry := range entries {
+		fpath := filepath.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			spanStart, spanEnd, ok := s.dirSpan(fpath, depth)
+			if ok {
+				if s.end != nil && spanStart.After(*s.end) {
+					continue
+				}
+
+				if s.start != nil && !spanEnd.After(*s.start) {
+					before = append(before, segmentScannerDir{fpath: fpath, start: spanStart})
+					continue
+				}
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				atomic.AddInt64(&count, int64(s.scanDir(fpath, depth+1)))
+			}()
+			continue
+		}
+
+		if isTemporaryFile(fpath) {
+			continue
+		}
+
+		var pa Path
+		ok := false
+		var format conf.RecordFormat
+
+		for i, segFormat := range s.segFormats {
+			if pa.Decode(segFormat, fpath) {
+				ok = true
+				format = s.formats[i]
+				break
+			}
+		}
+
+		// gather all segments that starts before the end of the playback
+		if ok && (s.end == nil || !s.end.Before(pa.Start)) {
+			s.mutex.Lock()
+			s.segments = append(s.segments, &Segment{
+				Fpath:          fpath,
+				Start:          pa.Start,
+				Format:         format,
+				EncryptionKey:  s.key,
+				AllowPlaintext: s.plaintext,
+			})
+			s.mutex.Unlock()
+			atomic.AddInt64(&count, 1)
+		}
+	}
+
+	// among directories that end before start, only the last one that is not empty is needed,
+	// since it contains the segment that may contain start.
+	if before != nil {
+		sort.Slice(before, func(i, j int) bool {
+			return before[i].start.After(before[j].start)
+		})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, d := range before {
+				n := s.scanDir(d.fpath, depth+1)
+				if n != 0 {
+					atomic.AddInt64(&count, int64(n))
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return int(count)
+}
+
+// scanSegments returns segments that start before end, in any of the given formats.
+// When start is provided, segments that precede the one that may contain start
+// are not guaranteed to be returned.
+func scanSegments(
+	recordPath string,
+	formats []conf.RecordFormat,
+	commonPath string,
+	start *time.Time,
+	end *time.Time,
+	key []byte,
+	allowPlaintext bool,
+) ([]*Segment, error) {
+	s := &segmentScanner{
+		recordPath: recordPath,
+		formats:    formats,
+		commonPath: commonPath,
+		start:      start,
+		end:        end,
+		key:        key,
+		plaintext:  allowPlaintext,
+	}
+	s.initialize()
+	return s.run()
+}
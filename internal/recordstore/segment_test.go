@@ -86,6 +86,10 @@ func TestFindSegments(t *testing.T) {
 			err = os.WriteFile(filepath.Join(dir, "path1", "2016-05-19_22-15-25-000427.mp4"), []byte{1}, 0o644)
 			require.NoError(t, err)
 
+			// temporary files must be ignored
+			err = os.WriteFile(filepath.Join(dir, "path1", "2015-12-19_22-15-25-000427.mp4.tmp"), []byte{1}, 0o644)
+			require.NoError(t, err)
+
 			var start *time.Time
 			var end *time.Time
 
@@ -140,3 +144,248 @@ func TestFindSegments(t *testing.T) {
 		})
 	}
 }
+
+func TestFindSegmentsDateDirectories(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, fpath := range []string{
+		"2015/04/30/22-15-25-000000.mp4",
+		"2015/05/18/22-15-25-000000.mp4",
+		"2015/05/19/10-00-00-000000.mp4",
+		"2015/05/19/12-00-00-000000.mp4",
+		"2015/05/20/10-00-00-000000.mp4",
+		"2016/01/01/10-00-00-000000.mp4",
+	} {
+		err = os.MkdirAll(filepath.Join(dir, "mypath", filepath.Dir(fpath)), 0o755)
+		require.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(dir, "mypath", fpath), []byte{1}, 0o644)
+		require.NoError(t, err)
+	}
+
+	// directories without segments must not hide previous segments
+	err = os.MkdirAll(filepath.Join(dir, "mypath", "2015", "05", "19"), 0o755)
+	require.NoError(t, err)
+	err = os.MkdirAll(filepath.Join(dir, "mypath", "2015", "05", "20"), 0o755)
+	require.NoError(t, err)
+	err = os.MkdirAll(filepath.Join(dir, "mypath", "2015", "05", "21"), 0o755)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:         "mypath",
+		RecordPath:   filepath.Join(dir, "%path/%Y/%m/%d/%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	for _, ca := range []struct {
+		name  string
+		start time.Time
+		end   time.Time
+		out   []string
+	}{
+		{
+			"inside directory",
+			time.Date(2015, 5, 19, 11, 0, 0, 0, time.Local),
+			time.Date(2015, 5, 19, 13, 0, 0, 0, time.Local),
+			[]string{"2015/05/19/10-00-00-000000.mp4", "2015/05/19/12-00-00-000000.mp4"},
+		},
+		{
+			"start in previous directory",
+			time.Date(2015, 5, 19, 9, 0, 0, 0, time.Local),
+			time.Date(2015, 5, 19, 11, 0, 0, 0, time.Local),
+			[]string{"2015/05/18/22-15-25-000000.mp4", "2015/05/19/10-00-00-000000.mp4"},
+		},
+		{
+			"start after empty directory",
+			time.Date(2015, 5, 22, 9, 0, 0, 0, time.Local),
+			time.Date(2016, 1, 1, 11, 0, 0, 0, time.Local),
+			[]string{"2015/05/20/10-00-00-000000.mp4", "2016/01/01/10-00-00-000000.mp4"},
+		},
+		{
+			"start in previous year",
+			time.Date(2015, 12, 31, 9, 0, 0, 0, time.Local),
+			time.Date(2016, 1, 1, 9, 0, 0, 0, time.Local),
+			[]string{"2015/05/20/10-00-00-000000.mp4"},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			segments, err := FindSegments(pathConf, "mypath", &ca.start, &ca.end)
+			require.NoError(t, err)
+
+			out := make([]string, len(segments))
+			for i, seg := range segments {
+				out[i], err = filepath.Rel(filepath.Join(dir, "mypath"), seg.Fpath)
+				require.NoError(t, err)
+				out[i] = filepath.ToSlash(out[i])
+			}
+			require.Equal(t, ca.out, out)
+		})
+	}
+}
+
+func TestFindSegmentsArchivePath(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, fpath := range []string{
+		"archive/mypath/2015-05-19_22-15-25-000000.mp4",
+		"archive/mypath/2015-05-20_22-15-25-000000.mp4",
+		"recordings/mypath/2015-05-20_22-15-25-000000.mp4",
+		"recordings/mypath/2015-05-21_22-15-25-000000.mp4",
+	} {
+		err = os.MkdirAll(filepath.Join(dir, filepath.Dir(fpath)), 0o755)
+		require.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(dir, fpath), []byte{1}, 0o644)
+		require.NoError(t, err)
+	}
+
+	pathConf := &conf.Path{
+		Name:              "mypath",
+		RecordPath:        filepath.Join(dir, "recordings/%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordArchivePath: filepath.Join(dir, "archive/%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:      conf.RecordFormatFMP4,
+	}
+
+	segments, err := FindSegments(pathConf, "mypath", nil, nil)
+	require.NoError(t, err)
+
+	// segments present in both storages are returned once, from the record path
+	require.Equal(t, []*Segment{
+		{
+			Fpath: filepath.Join(dir, "archive", "mypath", "2015-05-19_22-15-25-000000.mp4"),
+			Start: time.Date(2015, 5, 19, 22, 15, 25, 0, time.Local),
+		},
+		{
+			Fpath: filepath.Join(dir, "recordings", "mypath", "2015-05-20_22-15-25-000000.mp4"),
+			Start: time.Date(2015, 5, 20, 22, 15, 25, 0, time.Local),
+		},
+		{
+			Fpath: filepath.Join(dir, "recordings", "mypath", "2015-05-21_22-15-25-000000.mp4"),
+			Start: time.Date(2015, 5, 21, 22, 15, 25, 0, time.Local),
+		},
+	}, segments)
+
+	require.Equal(t, filepath.Join(dir, "archive/mypath/%Y-%m-%d_%H-%M-%S-%f.mp4"),
+		SegmentPathFormat(pathConf, "mypath", segments[0].Fpath))
+	require.Equal(t, filepath.Join(dir, "recordings/mypath/%Y-%m-%d_%H-%M-%S-%f.mp4"),
+		SegmentPathFormat(pathConf, "mypath", segments[1].Fpath))
+
+	// a storage that does not exist yet is ignored
+	pathConf.RecordArchivePath = filepath.Join(dir, "missing/%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	segments, err = FindSegments(pathConf, "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+}
+
+func TestFindSegmentsAliases(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, fpath := range []string{
+		"oldname/2015-05-19_22-15-25-000000.mp4",
+		"oldname/2015-05-20_22-15-25-000000.mp4",
+		"newname/2015-05-20_22-15-25-000000.mp4",
+		"newname/2015-05-21_22-15-25-000000.mp4",
+	} {
+		err = os.MkdirAll(filepath.Join(dir, filepath.Dir(fpath)), 0o755)
+		require.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(dir, fpath), []byte{1}, 0o644)
+		require.NoError(t, err)
+	}
+
+	pathConf := &conf.Path{
+		Name:          "newname",
+		RecordPath:    filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:  conf.RecordFormatFMP4,
+		RecordAliases: []string{"oldname"},
+	}
+
+	segments, err := FindSegments(pathConf, "newname", nil, nil)
+	require.NoError(t, err)
+
+	// segments present under both names are returned once, from the current name
+	require.Equal(t, []*Segment{
+		{
+			Fpath: filepath.Join(dir, "oldname", "2015-05-19_22-15-25-000000.mp4"),
+			Start: time.Date(2015, 5, 19, 22, 15, 25, 0, time.Local),
+		},
+		{
+			Fpath: filepath.Join(dir, "newname", "2015-05-20_22-15-25-000000.mp4"),
+			Start: time.Date(2015, 5, 20, 22, 15, 25, 0, time.Local),
+		},
+		{
+			Fpath: filepath.Join(dir, "newname", "2015-05-21_22-15-25-000000.mp4"),
+			Start: time.Date(2015, 5, 21, 22, 15, 25, 0, time.Local),
+		},
+	}, segments)
+
+	require.Equal(t, filepath.Join(dir, "oldname/%Y-%m-%d_%H-%M-%S-%f.mp4"),
+		SegmentPathFormat(pathConf, "newname", segments[0].Fpath))
+	require.Equal(t, filepath.Join(dir, "newname/%Y-%m-%d_%H-%M-%S-%f.mp4"),
+		SegmentPathFormat(pathConf, "newname", segments[1].Fpath))
+
+	err = os.RemoveAll(filepath.Join(dir, "newname"))
+	require.NoError(t, err)
+
+	// recordings of previous names are listed under the current name
+	paths := FindAllPathsWithSegments(map[string]*conf.Path{
+		"newname": pathConf,
+	})
+	require.Equal(t, []string{"newname"}, paths)
+}
+
+func TestFindSegmentsAllFormats(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	for _, fname := range []string{
+		"2015-05-19_22-15-25-000000.ts",
+		"2015-05-20_22-15-25-000000.mp4",
+	} {
+		err = os.WriteFile(filepath.Join(dir, "mypath", fname), []byte{1}, 0o644)
+		require.NoError(t, err)
+	}
+
+	pathConf := &conf.Path{
+		Name:         "mypath",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	// segments in other formats are ignored
+	segments, err := FindSegments(pathConf, "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []*Segment{
+		{
+			Fpath:  filepath.Join(dir, "mypath", "2015-05-20_22-15-25-000000.mp4"),
+			Start:  time.Date(2015, 5, 20, 22, 15, 25, 0, time.Local),
+			Format: conf.RecordFormatFMP4,
+		},
+	}, segments)
+
+	segments, err = FindSegmentsAllFormats(pathConf, "mypath", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []*Segment{
+		{
+			Fpath:  filepath.Join(dir, "mypath", "2015-05-19_22-15-25-000000.ts"),
+			Start:  time.Date(2015, 5, 19, 22, 15, 25, 0, time.Local),
+			Format: conf.RecordFormatMPEGTS,
+		},
+		{
+			Fpath:  filepath.Join(dir, "mypath", "2015-05-20_22-15-25-000000.mp4"),
+			Start:  time.Date(2015, 5, 20, 22, 15, 25, 0, time.Local),
+			Format: conf.RecordFormatFMP4,
+		},
+	}, segments)
+}
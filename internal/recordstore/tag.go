@@ -0,0 +1,140 @@
+package recordstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/google/uuid"
+)
+
+// ErrTagNotFound is returned when a tag is not found.
+var ErrTagNotFound = errors.New("tag not found")
+
+// tags are stored in a file placed in the recording directory,
+// next to segments.
+const tagsFileName = ".tags.json"
+
+var tagsMutex sync.Mutex
+
+// Tag is a key-value pair attached to a time range of a path.
+type Tag struct {
+	ID      uuid.UUID `json:"id"`
+	Path    string    `json:"path"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Key     string    `json:"key"`
+	Value   string    `json:"value"`
+	Created time.Time `json:"created"`
+}
+
+// Matches checks whether the tag has the given key and, if value is not empty, the given value.
+func (t *Tag) Matches(key string, value string) bool {
+	return t.Key == key && (value == "" || t.Value == value)
+}
+
+func tagsFilePath(pathConf *conf.Path, pathName string) string {
+	recordPath := strings.ReplaceAll(pathConf.RecordPath, "%path", pathName)
+	recordPath, _ = filepath.Abs(recordPath)
+	return filepath.Join(CommonPath(recordPath), tagsFileName)
+}
+
+// the tags file may be shared by multiple paths.
+func readTagsFile(fpath string) ([]*Tag, error) {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var tags []*Tag
+	err = json.Unmarshal(byts, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func writeTagsFile(fpath string, tags []*Tag) error {
+	byts, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fpath, byts)
+}
+
+// FindTags returns all tags of a path.
+func FindTags(pathConf *conf.Path, pathName string) ([]*Tag, error) {
+	tagsMutex.Lock()
+	defer tagsMutex.Unlock()
+
+	all, err := readTagsFile(tagsFilePath(pathConf, pathName))
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []*Tag{}
+	for _, t := range all {
+		if t.Path == pathName {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags, nil
+}
+
+// AddTag adds a tag.
+func AddTag(pathConf *conf.Path, tag *Tag) error {
+	tagsMutex.Lock()
+	defer tagsMutex.Unlock()
+
+	fpath := tagsFilePath(pathConf, tag.Path)
+
+	tags, err := readTagsFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	return writeTagsFile(fpath, append(tags, tag))
+}
+
+// RemoveTag removes a tag.
+func RemoveTag(pathConf *conf.Path, pathName string, id uuid.UUID) error {
+	tagsMutex.Lock()
+	defer tagsMutex.Unlock()
+
+	fpath := tagsFilePath(pathConf, pathName)
+
+	tags, err := readTagsFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	for i, t := range tags {
+		if t.Path == pathName && t.ID == id {
+			return writeTagsFile(fpath, append(tags[:i], tags[i+1:]...))
+		}
+	}
+
+	return ErrTagNotFound
+}
+
+// TagsOverlapping returns the tags that overlap with a time range.
+func TagsOverlapping(tags []*Tag, start time.Time, end time.Time) []*Tag {
+	out := []*Tag{}
+	for _, t := range tags {
+		if t.Start.Before(end) && t.End.After(start) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
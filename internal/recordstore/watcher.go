@@ -0,0 +1,371 @@
+package recordstore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventType is the type of a segment event.
+type EventType int
+
+// event types.
+const (
+	EventSegmentCreated EventType = iota
+	EventSegmentCompleted
+	EventSegmentDeleted
+)
+
+// String implements fmt.Stringer.
+func (t EventType) String() string {
+	switch t {
+	case EventSegmentCreated:
+		return "created"
+	case EventSegmentCompleted:
+		return "completed"
+	}
+	return "deleted"
+}
+
+// Event is a segment event.
+type Event struct {
+	Type     EventType
+	PathName string
+	Segment  *Segment
+	Duration time.Duration // only for EventSegmentCompleted
+}
+
+// Watcher emits events when segments are created, completed or deleted.
+// Segments written by this instance are reported by recorders,
+// while segments written or deleted by others are detected through the file system.
+// The file system is watched only while there is at least one subscriber.
+type Watcher struct {
+	PathConfs map[string]*conf.Path
+	Parent    logger.Writer
+
+	mutex       sync.Mutex
+	inner       *fsnotify.Watcher
+	known       map[string]struct{}
+	subscribers map[int]func(*Event)
+	nextID      int
+	terminate   chan struct{}
+	done        chan struct{}
+}
+
+// Initialize initializes a Watcher.
+func (w *Watcher) Initialize() {
+	w.known = make(map[string]struct{})
+	w.subscribers = make(map[int]func(*Event))
+}
+
+// Close closes a Watcher.
+func (w *Watcher) Close() {
+	w.stop()
+}
+
+// Log implements logger.Writer.
+func (w *Watcher) Log(level logger.Level, format string, args ...interface{}) {
+	w.Parent.Log(level, "[record watcher] "+format, args...)
+}
+
+// ReloadPathConfs is called by core.Core.
+func (w *Watcher) ReloadPathConfs(pathConfs map[string]*conf.Path) {
+	w.mutex.Lock()
+	w.PathConfs = pathConfs
+	w.mutex.Unlock()
+
+	w.watchRoots()
+}
+
+// Subscribe registers a callback that is called at every event.
+// It returns a function that removes the callback.
+func (w *Watcher) Subscribe(cb func(*Event)) func() {
+	w.mutex.Lock()
+
+	id := w.nextID
+	w.nextID++
+	w.subscribers[id] = cb
+
+	start := len(w.subscribers) == 1
+	w.mutex.Unlock()
+
+	if start {
+		w.start()
+	}
+
+	return func() {
+		w.mutex.Lock()
+		delete(w.subscribers, id)
+		stop := len(w.subscribers) == 0
+		w.mutex.Unlock()
+
+		if stop {
+			w.stop()
+		}
+	}
+}
+
+func (w *Watcher) start() {
+	inner, err := fsnotify.NewWatcher()
+	if err != nil {
+		// events are still reported by recorders
+		w.Log(logger.Warn, "unable to watch the file system: %v", err)
+		return
+	}
+
+	w.mutex.Lock()
+	if w.inner != nil || len(w.subscribers) == 0 {
+		w.mutex.Unlock()
+		inner.Close() //nolint:errcheck
+		return
+	}
+	w.inner = inner
+	w.terminate = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run(inner, w.terminate, w.done)
+	w.mutex.Unlock()
+
+	w.watchRoots()
+}
+
+func (w *Watcher) stop() {
+	w.mutex.Lock()
+	if w.inner == nil {
+		w.mutex.Unlock()
+		return
+	}
+	terminate, done := w.terminate, w.done
+	w.inner = nil
+	w.known = make(map[string]struct{})
+	w.mutex.Unlock()
+
+	close(terminate)
+	<-done
+}
+
+func (w *Watcher) hasSubscribers() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return len(w.subscribers) != 0
+}
+
+// SegmentCreated is called by recorders when a segment is created.
+func (w *Watcher) SegmentCreated(pathName string, fpath string) {
+	if !w.hasSubscribers() {
+		return
+	}
+
+	fpath, _ = filepath.Abs(fpath)
+
+	// the segment may be placed in a directory that didn't exist before
+	w.watchRoots()
+
+	w.emit(pathName, fpath, EventSegmentCreated, 0)
+}
+
+// SegmentCompleted is called by recorders when a segment is complete.
+func (w *Watcher) SegmentCompleted(pathName string, fpath string, duration time.Duration) {
+	if !w.hasSubscribers() {
+		return
+	}
+
+	fpath, _ = filepath.Abs(fpath)
+	w.emit(pathName, fpath, EventSegmentCompleted, duration)
+}
+
+func recordRoot(pathConf *conf.Path) string {
+	recordPath := pathConf.RecordPath
+	if pathConf.Regexp == nil {
+		recordPath = strings.ReplaceAll(recordPath, "%path", pathConf.Name)
+	}
+
+	recordPath, _ = filepath.Abs(recordPath)
+	return CommonPath(recordPath)
+}
+
+// watchRoots adds watches to directories of all paths that are recording.
+// Directories that do not exist yet are added once segments are created.
+func (w *Watcher) watchRoots() {
+	w.mutex.Lock()
+	inner := w.inner
+	if inner == nil {
+		w.mutex.Unlock()
+		return
+	}
+
+	roots := make(map[string]struct{})
+	for _, pathConf := range w.PathConfs {
+		if pathConf.Record {
+			roots[recordRoot(pathConf)] = struct{}{}
+		}
+	}
+	w.mutex.Unlock()
+
+	for root := range roots {
+		w.watchRecursive(inner, root, false)
+	}
+}
+
+// watchRecursive adds watches to a directory and its subdirectories.
+// When emitFiles is true, segments that are already present are reported,
+// since they may have been created before the watch was added.
+func (w *Watcher) watchRecursive(inner *fsnotify.Watcher, dir string, emitFiles bool) {
+	filepath.WalkDir(dir, func(fpath string, info fs.DirEntry, err error) error { //nolint:errcheck
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			inner.Add(fpath) //nolint:errcheck
+		} else if emitFiles {
+			if pathName, _, ok := w.decode(fpath); ok {
+				w.emit(pathName, fpath, EventSegmentCreated, 0)
+			}
+		}
+
+		return nil
+	})
+}
+
+// decode finds the path of a segment file.
+func (w *Watcher) decode(fpath string) (string, *Segment, bool) {
+	if isTemporaryFile(fpath) {
+		return "", nil, false
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, pathConf := range w.PathConfs {
+		recordPath := PathAddExtension(pathConf.RecordPath, pathConf.RecordFormat)
+		if pathConf.Regexp == nil {
+			recordPath = strings.ReplaceAll(recordPath, "%path", pathConf.Name)
+		}
+		recordPath, _ = filepath.Abs(recordPath)
+
+		var pa Path
+		if !pa.Decode(recordPath, fpath) {
+			continue
+		}
+
+		if pathConf.Regexp == nil {
+			return pathConf.Name, &Segment{Fpath: fpath, Start: pa.Start, Format: pathConf.RecordFormat}, true
+		}
+
+		if conf.IsValidPathName(pa.Path) == nil && pathConf.Regexp.FindStringSubmatch(pa.Path) != nil {
+			return pa.Path, &Segment{Fpath: fpath, Start: pa.Start, Format: pathConf.RecordFormat}, true
+		}
+	}
+
+	return "", nil, false
+}
+
+func (w *Watcher) emit(pathName string, fpath string, typ EventType, duration time.Duration) {
+	var start time.Time
+	if decodedPathName, seg, ok := w.decode(fpath); ok {
+		pathName = decodedPathName
+		start = seg.Start
+	}
+
+	w.mutex.Lock()
+
+	if typ == EventSegmentCreated {
+		// segments created by recorders are reported twice: by recorders and by the file system.
+		if _, ok := w.known[fpath]; ok {
+			w.mutex.Unlock()
+			return
+		}
+		w.known[fpath] = struct{}{}
+	}
+
+	subscribers := make([]func(*Event), 0, len(w.subscribers))
+	for _, cb := range w.subscribers {
+		subscribers = append(subscribers, cb)
+	}
+
+	w.mutex.Unlock()
+
+	w.Log(logger.Debug, "segment %s of path '%s' %v", fpath, pathName, typ)
+
+	e := &Event{
+		Type:     typ,
+		PathName: pathName,
+		Segment: &Segment{
+			Fpath: fpath,
+			Start: start,
+		},
+		Duration: duration,
+	}
+
+	for _, cb := range subscribers {
+		cb(e)
+	}
+}
+
+// forget removes a file, or all files of a directory, from known segments.
+func (w *Watcher) forget(fpath string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	prefix := fpath + string(os.PathSeparator)
+	for known := range w.known {
+		if known == fpath || strings.HasPrefix(known, prefix) {
+			delete(w.known, known)
+		}
+	}
+}
+
+func (w *Watcher) handleFSEvent(inner *fsnotify.Watcher, event fsnotify.Event) {
+	fpath, _ := filepath.Abs(event.Name)
+
+	switch {
+	case event.Has(fsnotify.Create):
+		fi, err := os.Stat(fpath)
+		if err != nil {
+			return
+		}
+
+		if fi.IsDir() {
+			w.watchRecursive(inner, fpath, true)
+			return
+		}
+
+		if pathName, _, ok := w.decode(fpath); ok {
+			w.emit(pathName, fpath, EventSegmentCreated, 0)
+		}
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// known segments are forgotten even when they can't be decoded anymore
+		// (i.e. after a configuration reload) or when their directory is removed.
+		w.forget(fpath)
+
+		if pathName, _, ok := w.decode(fpath); ok {
+			w.emit(pathName, fpath, EventSegmentDeleted, 0)
+		}
+	}
+}
+
+func (w *Watcher) run(inner *fsnotify.Watcher, terminate chan struct{}, done chan struct{}) {
+	defer close(done)
+	defer inner.Close() //nolint:errcheck
+
+	for {
+		select {
+		case event := <-inner.Events:
+			w.handleFSEvent(inner, event)
+
+		case err := <-inner.Errors:
+			w.Log(logger.Warn, "%v", err)
+
+		case <-terminate:
+			return
+		}
+	}
+}
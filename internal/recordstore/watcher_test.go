@@ -0,0 +1,138 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w := &Watcher{
+		PathConfs: map[string]*conf.Path{
+			"~^.*$": {
+				Name:         "~^.*$",
+				Regexp:       regexp.MustCompile("^.*$"),
+				Record:       true,
+				RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat: conf.RecordFormatFMP4,
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	w.Initialize()
+	defer w.Close()
+
+	events := make(chan *Event, 10)
+	unsubscribe := w.Subscribe(func(e *Event) {
+		events <- e
+	})
+	defer unsubscribe()
+
+	next := func() *Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not received")
+			return nil
+		}
+	}
+
+	fpath := filepath.Join(dir, "mypath", "2015-05-19_22-15-25-000427.mp4")
+
+	// segment created by another instance
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(fpath, []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	e := next()
+	require.Equal(t, EventSegmentCreated, e.Type)
+	require.Equal(t, "mypath", e.PathName)
+	require.Equal(t, fpath, e.Segment.Fpath)
+	require.Equal(t, time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local), e.Segment.Start)
+
+	// temporary files are ignored
+	err = os.WriteFile(filepath.Join(dir, "mypath", "tmp.tmp"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	// already reported
+	w.SegmentCreated("mypath", fpath)
+
+	w.SegmentCompleted("mypath", fpath, 2*time.Second)
+
+	e = next()
+	require.Equal(t, EventSegmentCompleted, e.Type)
+	require.Equal(t, "mypath", e.PathName)
+	require.Equal(t, 2*time.Second, e.Duration)
+
+	err = os.Remove(fpath)
+	require.NoError(t, err)
+
+	e = next()
+	require.Equal(t, EventSegmentDeleted, e.Type)
+	require.Equal(t, "mypath", e.PathName)
+	require.Equal(t, fpath, e.Segment.Fpath)
+
+	// segments of a removed directory are forgotten
+	fpath = filepath.Join(dir, "mypath", "2015-05-19_22-15-27-000427.mp4")
+	w.SegmentCreated("mypath", fpath)
+
+	e = next()
+	require.Equal(t, EventSegmentCreated, e.Type)
+
+	err = os.RemoveAll(filepath.Join(dir, "mypath"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		return len(w.known) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatcherSubscribers(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w := &Watcher{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:         "mypath",
+				Record:       true,
+				RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat: conf.RecordFormatFMP4,
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	w.Initialize()
+	defer w.Close()
+
+	// the file system is not watched until there are subscribers
+	w.SegmentCreated("mypath", filepath.Join(dir, "mypath", "2015-05-19_22-15-25-000427.mp4"))
+	require.Nil(t, w.inner)
+	require.Empty(t, w.known)
+
+	unsubscribe1 := w.Subscribe(func(*Event) {})
+	unsubscribe2 := w.Subscribe(func(*Event) {})
+	require.NotNil(t, w.inner)
+
+	unsubscribe1()
+	require.NotNil(t, w.inner)
+
+	unsubscribe2()
+	require.Nil(t, w.inner)
+}
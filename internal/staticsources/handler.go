@@ -16,6 +16,7 @@ import (
 	ssrtmp "github.com/bluenviron/mediamtx/internal/staticsources/rtmp"
 	ssrtsp "github.com/bluenviron/mediamtx/internal/staticsources/rtsp"
 	sssrt "github.com/bluenviron/mediamtx/internal/staticsources/srt"
+	sstestpattern "github.com/bluenviron/mediamtx/internal/staticsources/testpattern"
 	ssudp "github.com/bluenviron/mediamtx/internal/staticsources/udp"
 	sswebrtc "github.com/bluenviron/mediamtx/internal/staticsources/webrtc"
 	"github.com/bluenviron/mediamtx/internal/stream"
@@ -137,6 +138,11 @@ func (s *Handler) Initialize() {
 			Parent:            s,
 		}
 
+	case s.Conf.Source == "testPattern":
+		s.instance = &sstestpattern.Source{
+			Parent: s,
+		}
+
 	default:
 		panic("should not happen")
 	}
@@ -0,0 +1,207 @@
+package testpattern
+
+import (
+	"math/bits"
+)
+
+// H264 is generated without an encoder, by storing raw samples into I_PCM macroblocks.
+// Specification: ITU-T Rec. H.264, 7.3 Syntax in tabular form
+
+const (
+	// mb_type of I_PCM macroblocks in I slices.
+	mbTypeIPCM = 25
+)
+
+// 75% color bars, in BT.601 limited range.
+var colorBars = []struct {
+	y  byte
+	cb byte
+	cr byte
+}{
+	{180, 128, 128}, // white
+	{162, 44, 142},  // yellow
+	{131, 156, 44},  // cyan
+	{112, 72, 58},   // green
+	{84, 184, 198},  // magenta
+	{65, 100, 212},  // red
+	{35, 212, 114},  // blue
+	{16, 128, 128},  // black
+}
+
+type bitWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if (w.n % 8) == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if ((v >> i) & 1) != 0 {
+			w.buf[len(w.buf)-1] |= 1 << (7 - (w.n % 8))
+		}
+		w.n++
+	}
+}
+
+func (w *bitWriter) writeFlag(v bool) {
+	if v {
+		w.writeBits(1, 1)
+	} else {
+		w.writeBits(0, 1)
+	}
+}
+
+func (w *bitWriter) writeUE(v uint32) {
+	v++
+	n := bits.Len32(v)
+	w.writeBits(0, n-1)
+	w.writeBits(uint64(v), n)
+}
+
+func (w *bitWriter) writeSE(v int32) {
+	if v > 0 {
+		w.writeUE(uint32(2*v - 1))
+	} else {
+		w.writeUE(uint32(-2 * v))
+	}
+}
+
+func (w *bitWriter) writeAlignmentZeroBits() {
+	for (w.n % 8) != 0 {
+		w.writeBits(0, 1)
+	}
+}
+
+func (w *bitWriter) writeBytes(b []byte) {
+	w.writeAlignmentZeroBits()
+	w.buf = append(w.buf, b...)
+	w.n += len(b) * 8
+}
+
+func (w *bitWriter) writeTrailingBits() {
+	w.writeBits(1, 1)
+	w.writeAlignmentZeroBits()
+}
+
+// emulationPreventionAdd adds emulation prevention bytes to a RBSP.
+// Specification: ITU-T Rec. H.264, 7.4.1 NAL unit semantics
+func emulationPreventionAdd(rbsp []byte) []byte {
+	ret := make([]byte, 0, len(rbsp))
+	zeros := 0
+
+	for _, b := range rbsp {
+		if zeros >= 2 && b <= 3 {
+			ret = append(ret, 3)
+			zeros = 0
+		}
+
+		ret = append(ret, b)
+
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+
+	return ret
+}
+
+func nalu(header byte, w *bitWriter) []byte {
+	return append([]byte{header}, emulationPreventionAdd(w.buf)...)
+}
+
+// generateSPS generates a baseline profile SPS.
+func generateSPS(width int, height int) []byte {
+	w := &bitWriter{}
+	w.writeBits(66, 8)   // profile_idc
+	w.writeBits(0xC0, 8) // constraint_set0_flag, constraint_set1_flag
+	w.writeBits(30, 8)   // level_idc
+	w.writeUE(0)         // seq_parameter_set_id
+	w.writeUE(0)         // log2_max_frame_num_minus4
+	w.writeUE(2)         // pic_order_cnt_type
+	w.writeUE(1)         // max_num_ref_frames
+	w.writeFlag(false)   // gaps_in_frame_num_value_allowed_flag
+	w.writeUE(uint32(width/16 - 1))
+	w.writeUE(uint32(height/16 - 1))
+	w.writeFlag(true)  // frame_mbs_only_flag
+	w.writeFlag(true)  // direct_8x8_inference_flag
+	w.writeFlag(false) // frame_cropping_flag
+	w.writeFlag(false) // vui_parameters_present_flag
+	w.writeTrailingBits()
+
+	return nalu(0x67, w)
+}
+
+// generatePPS generates a PPS with CAVLC and deblocking control.
+func generatePPS() []byte {
+	w := &bitWriter{}
+	w.writeUE(0)       // pic_parameter_set_id
+	w.writeUE(0)       // seq_parameter_set_id
+	w.writeFlag(false) // entropy_coding_mode_flag
+	w.writeFlag(false) // bottom_field_pic_order_in_frame_present_flag
+	w.writeUE(0)       // num_slice_groups_minus1
+	w.writeUE(0)       // num_ref_idx_l0_default_active_minus1
+	w.writeUE(0)       // num_ref_idx_l1_default_active_minus1
+	w.writeFlag(false) // weighted_pred_flag
+	w.writeBits(0, 2)  // weighted_bipred_idc
+	w.writeSE(0)       // pic_init_qp_minus26
+	w.writeSE(0)       // pic_init_qs_minus26
+	w.writeSE(0)       // chroma_qp_index_offset
+	w.writeFlag(true)  // deblocking_filter_control_present_flag
+	w.writeFlag(false) // constrained_intra_pred_flag
+	w.writeFlag(false) // redundant_pic_cnt_present_flag
+	w.writeTrailingBits()
+
+	return nalu(0x68, w)
+}
+
+// generateIDR generates an IDR slice that contains color bars.
+// Consecutive IDRs must have different IDs.
+func generateIDR(width int, height int, idrPicID uint32) []byte {
+	w := &bitWriter{}
+	w.writeUE(0)        // first_mb_in_slice
+	w.writeUE(7)        // slice_type (I, all slices)
+	w.writeUE(0)        // pic_parameter_set_id
+	w.writeBits(0, 4)   // frame_num
+	w.writeUE(idrPicID) // idr_pic_id
+	w.writeFlag(false)  // no_output_of_prior_pics_flag
+	w.writeFlag(false)  // long_term_reference_flag
+	w.writeSE(0)        // slice_qp_delta
+	w.writeUE(1)        // disable_deblocking_filter_idc
+
+	barWidth := width / len(colorBars)
+	luma := make([]byte, 16*16)
+	cb := make([]byte, 8*8)
+	cr := make([]byte, 8*8)
+
+	for mbY := 0; mbY < height/16; mbY++ {
+		for mbX := 0; mbX < width/16; mbX++ {
+			for x := 0; x < 16; x++ {
+				bar := colorBars[min((mbX*16+x)/barWidth, len(colorBars)-1)]
+
+				for y := 0; y < 16; y++ {
+					luma[y*16+x] = bar.y
+				}
+
+				if (x % 2) == 0 {
+					for y := 0; y < 8; y++ {
+						cb[y*8+x/2] = bar.cb
+						cr[y*8+x/2] = bar.cr
+					}
+				}
+			}
+
+			w.writeUE(mbTypeIPCM)
+			w.writeBytes(luma)
+			w.writeBytes(cb)
+			w.writeBytes(cr)
+		}
+	}
+
+	w.writeTrailingBits()
+
+	return nalu(0x65, w)
+}
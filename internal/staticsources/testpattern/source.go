@@ -0,0 +1,136 @@
+// Package testpattern contains the test pattern static source.
+package testpattern
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+const (
+	videoWidth     = 320
+	videoHeight    = 240
+	frameRate      = 5
+	sampleRate     = 48000
+	toneFrequency  = 1000
+	toneAmplitude  = 0.1 // -20 dBFS
+	samplesPerUnit = sampleRate / frameRate
+)
+
+// generateTone generates the samples of a sine wave,
+// in big-endian 16-bit format.
+// Units contain an integer number of periods, therefore they can be repeated.
+func generateTone() []byte {
+	samples := make([]byte, samplesPerUnit*2)
+
+	for i := 0; i < samplesPerUnit; i++ {
+		v := toneAmplitude * math.MaxInt16 * math.Sin(2*math.Pi*toneFrequency*float64(i)/sampleRate)
+		binary.BigEndian.PutUint16(samples[i*2:], uint16(int16(v)))
+	}
+
+	return samples
+}
+
+// Source is a static source that generates color bars and a tone,
+// in order to test the server without cameras.
+type Source struct {
+	Parent defs.StaticSourceParent
+}
+
+// Log implements logger.Writer.
+func (s *Source) Log(level logger.Level, format string, args ...interface{}) {
+	s.Parent.Log(level, "[test pattern source] "+format, args...)
+}
+
+// Run implements StaticSource.
+func (s *Source) Run(params defs.StaticSourceRunParams) error {
+	sps := generateSPS(videoWidth, videoHeight)
+	pps := generatePPS()
+
+	// since all frames are equal, they are generated once
+	aus := [2][][]byte{
+		{sps, pps, generateIDR(videoWidth, videoHeight, 0)},
+		{sps, pps, generateIDR(videoWidth, videoHeight, 1)},
+	}
+	samples := generateTone()
+
+	videoMedia := &description.Media{
+		Type: description.MediaTypeVideo,
+		Formats: []format.Format{&format.H264{
+			PayloadTyp:        96,
+			SPS:               sps,
+			PPS:               pps,
+			PacketizationMode: 1,
+		}},
+	}
+
+	audioMedia := &description.Media{
+		Type: description.MediaTypeAudio,
+		Formats: []format.Format{&format.LPCM{
+			PayloadTyp:   96,
+			BitDepth:     16,
+			SampleRate:   sampleRate,
+			ChannelCount: 1,
+		}},
+	}
+
+	res := s.Parent.SetReady(defs.PathSourceStaticSetReadyReq{
+		Desc:               &description.Session{Medias: []*description.Media{videoMedia, audioMedia}},
+		GenerateRTPPackets: true,
+	})
+	if res.Err != nil {
+		return res.Err
+	}
+
+	defer s.Parent.SetNotReady(defs.PathSourceStaticSetNotReadyReq{})
+
+	s.Log(logger.Info, "generating %dx%d color bars and a %d Hz tone", videoWidth, videoHeight, toneFrequency)
+
+	ticker := time.NewTicker(time.Second / frameRate)
+	defer ticker.Stop()
+
+	for i := int64(0); ; {
+		select {
+		case <-ticker.C:
+			ntp := time.Now()
+
+			res.Stream.WriteUnit(videoMedia, videoMedia.Formats[0], &unit.H264{
+				Base: unit.Base{
+					NTP: ntp,
+					PTS: i * 90000 / frameRate,
+				},
+				AU: aus[i%2],
+			})
+
+			res.Stream.WriteUnit(audioMedia, audioMedia.Formats[0], &unit.LPCM{
+				Base: unit.Base{
+					NTP: ntp,
+					PTS: i * samplesPerUnit,
+				},
+				Samples: samples,
+			})
+
+			i++
+
+		case <-params.ReloadConf:
+
+		case <-params.Context.Done():
+			return nil
+		}
+	}
+}
+
+// APISourceDescribe implements StaticSource.
+func (*Source) APISourceDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "testPatternSource",
+		ID:   "",
+	}
+}
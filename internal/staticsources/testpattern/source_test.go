@@ -0,0 +1,55 @@
+package testpattern
+
+import (
+	"testing"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+func TestEmulationPreventionAdd(t *testing.T) {
+	rbsp := []byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x03, 0x00, 0x00}
+	enc := emulationPreventionAdd(rbsp)
+	require.Equal(t, []byte{0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x01, 0x00, 0x00, 0x03, 0x03, 0x00, 0x00}, enc)
+	require.Equal(t, rbsp, h264.EmulationPreventionRemove(enc))
+}
+
+func TestSource(t *testing.T) {
+	te := test.NewSourceTester(
+		func(p defs.StaticSourceParent) defs.StaticSource {
+			return &Source{
+				Parent: p,
+			}
+		},
+		"testPattern",
+		&conf.Path{},
+	)
+	defer te.Close()
+
+	u := <-te.Unit
+
+	au := u.(*unit.H264).AU
+	require.Len(t, au, 3)
+	require.Equal(t, h264.NALUTypeSPS, h264.NALUType(au[0][0]&0x1F))
+	require.Equal(t, h264.NALUTypePPS, h264.NALUType(au[1][0]&0x1F))
+	require.Equal(t, h264.NALUTypeIDR, h264.NALUType(au[2][0]&0x1F))
+
+	var sps h264.SPS
+	err := sps.Unmarshal(au[0])
+	require.NoError(t, err)
+	require.Equal(t, videoWidth, sps.Width())
+	require.Equal(t, videoHeight, sps.Height())
+
+	// NALU header, slice header and first mb_type, then macroblocks made of
+	// 384 samples preceded by mb_type and alignment bits, then trailing bits
+	idr := h264.EmulationPreventionRemove(au[2])
+	mbCount := (videoWidth / 16) * (videoHeight / 16)
+	require.Equal(t, 1+4+384+(mbCount-1)*(2+384)+1, len(idr))
+	require.Equal(t, byte(0x80), idr[len(idr)-1])
+	require.Equal(t, byte(colorBars[len(colorBars)-1].y), idr[len(idr)-2-128])
+}
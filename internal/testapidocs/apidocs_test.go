@@ -147,9 +147,11 @@ func TestAPIDocs(t *testing.T) {
 				Type:       "object",
 				Properties: make(map[string]openAPIProperty),
 			}
-			ty := reflect.TypeOf(ca.goStruct)
-			for i := range ty.NumField() {
-				sf := ty.Field(i)
+			for _, sf := range reflect.VisibleFields(reflect.TypeOf(ca.goStruct)) {
+				if sf.Anonymous {
+					continue
+				}
+
 				js := sf.Tag.Get("json")
 				if js != "-" && js != "paths" && js != "pathDefaults" && !strings.Contains(js, ",omitempty") {
 					switch {